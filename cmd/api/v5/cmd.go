@@ -38,7 +38,9 @@ func Commands(app *api.App) {
 	Network{}.Commands(app)
 	PProf{}.Commands(app)
 	Esp{}.Commands(app)
-	VxLAN{}.Commands(app)
+	NewVxLAN().Commands(app)
+	Fabric{}.Commands(app)
+	NewGre().Commands(app)
 	State{}.Commands(app)
 	Policy{}.Commands(app)
 }
@@ -0,0 +1,89 @@
+package v5
+
+import (
+	"github.com/danieldin95/openlan/cmd/api"
+	"github.com/danieldin95/openlan/pkg/schema"
+	"github.com/urfave/cli/v2"
+)
+
+type Fabric struct {
+	Cmd
+}
+
+func (u Fabric) Url(prefix, name string) string {
+	if name == "" {
+		return prefix + "/api/fabric"
+	} else {
+		return prefix + "/api/fabric/" + name
+	}
+}
+
+func (u Fabric) Tmpl() string {
+	return `# total {{ len . }}
+{{ps -16 "network"}} {{ps -8 "mode"}} {{ps -16 "name"}} {{ps -16 "remote"}} {{ ps -12 "rx bytes" }} {{ ps -12 "tx bytes" }} {{ ps -12 "rx packages" }} {{ ps -12 "tx packages" }}
+{{- range . }}
+{{- $net := . }}
+{{- range .Tunnels }}
+{{ps -16 $net.Name}} {{ps -8 .Mode}} {{ps -16 .Name}} {{ps -16 .Remote}} {{ pi -12 .RxBytes }} {{ pi -12 .TxBytes }} {{ pi -12 .RxPackages }} {{ pi -12 .TxPackages }}
+{{- end }}
+{{- end }}
+`
+}
+
+func (u Fabric) NetworkTmpl() string {
+	return `# total {{ len . }}
+{{ps -16 "network"}} {{ps -10 "vni"}} {{ps -15 "bridge"}} {{ ps -12 "rx bytes" }} {{ ps -12 "tx bytes" }} {{ ps -12 "rx packages" }} {{ ps -12 "tx packages" }} {{ ps -12 "arp replies" }}
+{{- range . }}
+{{- $net := . }}
+{{- range .Networks }}
+{{ps -16 $net.Name}} {{pi -10 .Vni}} {{ps -15 .Bridge}} {{ pi -12 .Patch.RxBytes }} {{ pi -12 .Patch.TxBytes }} {{ pi -12 .Patch.RxPackages }} {{ pi -12 .Patch.TxPackages }} {{ pi -12 .ArpReplies }}
+{{- end }}
+{{- end }}
+`
+}
+
+func (u Fabric) list(c *cli.Context, tmpl string) error {
+	url := u.Url(c.String("url"), c.String("network"))
+	clt := u.NewHttp(c.String("token"))
+	var items []schema.Fabric
+	if err := clt.GetJSON(url, &items); err != nil {
+		return err
+	}
+	return u.Out(items, c.String("format"), tmpl)
+}
+
+func (u Fabric) List(c *cli.Context) error {
+	return u.list(c, u.Tmpl())
+}
+
+func (u Fabric) ListNetwork(c *cli.Context) error {
+	return u.list(c, u.NetworkTmpl())
+}
+
+func (u Fabric) Commands(app *api.App) {
+	app.Command(&cli.Command{
+		Name:    "fabric",
+		Aliases: []string{"fb"},
+		Usage:   "Fabric statistics",
+		Subcommands: []*cli.Command{
+			{
+				Name:    "list",
+				Usage:   "Display counters of tunnels",
+				Aliases: []string{"ls"},
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "network"},
+				},
+				Action: u.List,
+			},
+			{
+				Name:    "network",
+				Usage:   "Display counters of each vni",
+				Aliases: []string{"net"},
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "network"},
+				},
+				Action: u.ListNetwork,
+			},
+		},
+	})
+}
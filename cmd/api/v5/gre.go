@@ -0,0 +1,130 @@
+package v5
+
+import (
+	"github.com/danieldin95/openlan/cmd/api"
+	"github.com/danieldin95/openlan/pkg/libol"
+	"github.com/danieldin95/openlan/pkg/schema"
+	"github.com/urfave/cli/v2"
+)
+
+type Gre struct {
+	Members
+}
+
+func NewGre() Gre {
+	return Gre{Members{Provider: "gre"}}
+}
+
+func (u Gre) Tmpl() string {
+	return `# total {{ len . }}
+{{ps -16 "network"}} {{ps -8 "mode"}} {{ps -15 "bridge"}} {{ps -16 "name"}} {{ps -10 "key"}} {{ps -16 "local"}} {{ps -16 "remote"}} {{ps -18 "address"}}
+{{- range . }}
+{{- $net := . }}
+{{- range .Members }}
+{{ps -16 $net.Name}} {{ps -8 $net.Mode}} {{ps -15 $net.Bridge}} {{ps -16 .Name}} {{pi -10 .Key}} {{ps -16 .Local}} {{ps -16 .Remote}} {{ps -18 .Address}}
+{{- end }}
+{{- end }}
+`
+}
+
+func (u Gre) List(c *cli.Context) error {
+	url := u.Url(c.String("url"), "")
+	clt := u.NewHttp(c.String("token"))
+	var items []schema.Gre
+	if err := clt.GetJSON(url, &items); err != nil {
+		return err
+	}
+	return u.Out(items, c.String("format"), u.Tmpl())
+}
+
+func (u Gre) Add(c *cli.Context) error {
+	return u.post(c, "", &schema.Gre{Name: c.String("network"), Mode: c.String("mode")})
+}
+
+func (u Gre) AddMember(c *cli.Context) error {
+	member := &schema.GreMember{
+		Name:    c.String("name"),
+		Local:   c.String("local"),
+		Remote:  c.String("remote"),
+		Key:     uint32(c.Uint("key")),
+		Address: c.String("address"),
+	}
+	if member.Remote == "" {
+		return libol.NewErr("remote is empty")
+	}
+	return u.post(c, "/member", member)
+}
+
+func (u Gre) RemoveMember(c *cli.Context) error {
+	member := &schema.GreMember{
+		Name:   c.String("name"),
+		Remote: c.String("remote"),
+	}
+	if member.Remote == "" && member.Name == "" {
+		return libol.NewErr("name or remote is empty")
+	}
+	return u.delete(c, "/member", member)
+}
+
+func (u Gre) Commands(app *api.App) {
+	app.Command(&cli.Command{
+		Name:  "gre",
+		Usage: "GRE configuration",
+		Subcommands: []*cli.Command{
+			{
+				Name:    "list",
+				Usage:   "Display all gre",
+				Aliases: []string{"ls"},
+				Action:  u.List,
+			},
+			{
+				Name:  "add",
+				Usage: "Add a gre network",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "network"},
+					&cli.StringFlag{Name: "mode", Usage: "gretap, gre or ipip"},
+				},
+				Action: u.Add,
+			},
+			{
+				Name:    "remove",
+				Usage:   "Remove a gre network",
+				Aliases: []string{"rm"},
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "network"},
+				},
+				Action: u.Remove,
+			},
+			{
+				Name:  "member",
+				Usage: "GRE member configuration",
+				Subcommands: []*cli.Command{
+					{
+						Name:  "add",
+						Usage: "Add or update a member",
+						Flags: []cli.Flag{
+							&cli.StringFlag{Name: "network"},
+							&cli.StringFlag{Name: "name"},
+							&cli.StringFlag{Name: "local"},
+							&cli.StringFlag{Name: "remote"},
+							&cli.UintFlag{Name: "key"},
+							&cli.StringFlag{Name: "address", Usage: "address of device in l3"},
+						},
+						Action: u.AddMember,
+					},
+					{
+						Name:    "del",
+						Usage:   "Delete a member by name or remote",
+						Aliases: []string{"rm"},
+						Flags: []cli.Flag{
+							&cli.StringFlag{Name: "network"},
+							&cli.StringFlag{Name: "name"},
+							&cli.StringFlag{Name: "remote"},
+						},
+						Action: u.RemoveMember,
+					},
+				},
+			},
+		},
+	})
+}
@@ -0,0 +1,45 @@
+package v5
+
+import (
+	"github.com/danieldin95/openlan/pkg/libol"
+	"github.com/urfave/cli/v2"
+)
+
+// Members is shared by networks of which members are added or
+// deleted at runtime, such as vxlan and gre.
+type Members struct {
+	Cmd
+	Provider string
+}
+
+func (u Members) Url(prefix, name string) string {
+	if name == "" {
+		return prefix + "/api/" + u.Provider
+	} else {
+		return prefix + "/api/" + u.Provider + "/" + name
+	}
+}
+
+func (u Members) post(c *cli.Context, suffix string, data interface{}) error {
+	network := c.String("network")
+	if network == "" {
+		return libol.NewErr("network is empty")
+	}
+	url := u.Url(c.String("url"), network) + suffix
+	clt := u.NewHttp(c.String("token"))
+	return clt.PostJSON(url, data, nil)
+}
+
+func (u Members) delete(c *cli.Context, suffix string, data interface{}) error {
+	network := c.String("network")
+	if network == "" {
+		return libol.NewErr("network is empty")
+	}
+	url := u.Url(c.String("url"), network) + suffix
+	clt := u.NewHttp(c.String("token"))
+	return clt.DeleteJSON(url, data, nil)
+}
+
+func (u Members) Remove(c *cli.Context) error {
+	return u.delete(c, "", nil)
+}
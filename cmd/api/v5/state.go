@@ -21,9 +21,9 @@ func (u State) Url(prefix, name string) string {
 
 func (u State) Tmpl() string {
 	return `# total {{ len . }}
-{{ps -16 "name"}} {{ps -8 "spi"}} {{ ps -16 "source" }} {{ ps -16 "destination" }} {{ ps -12 "rx bytes" }} {{ ps -12 "tx bytes" }} {{ ps -12 "rx packages" }} {{ ps -12 "tx packages" }} 
+{{ps -16 "name"}} {{ps -8 "spi"}} {{ ps -16 "source" }} {{ ps -16 "destination" }} {{ ps -12 "rx bytes" }} {{ ps -12 "tx bytes" }} {{ ps -12 "rx packages" }} {{ ps -12 "tx packages" }} {{ ps -6 "status" }} {{ ps -10 "rtt(us)" }} {{ ps -6 "flaps" }} {{ ps -8 "replay" }} {{ ps -8 "failed" }}
 {{- range . }}
-{{ps -16 .Name}} {{pi -8 .Spi }} {{ ps -16 .Source }} {{ ps -16 .Dest }} {{ pi -12 .RxBytes }} {{ pi -12 .TxBytes }} {{ pi -12 .RxPackages }} {{ pi -12 .TxPackages }}
+{{ps -16 .Name}} {{pi -8 .Spi }} {{ ps -16 .Source }} {{ ps -16 .Dest }} {{ pi -12 .RxBytes }} {{ pi -12 .TxBytes }} {{ pi -12 .RxPackages }} {{ pi -12 .TxPackages }} {{ ps -6 .Status }} {{ pi -10 .Rtt }} {{ pi -6 .Flaps }} {{ pi -8 .Replay }} {{ pi -8 .Failed }}
 {{- end }}
 `
 }
@@ -2,27 +2,27 @@ package v5
 
 import (
 	"github.com/danieldin95/openlan/cmd/api"
+	"github.com/danieldin95/openlan/pkg/libol"
 	"github.com/danieldin95/openlan/pkg/schema"
 	"github.com/urfave/cli/v2"
 )
 
 type VxLAN struct {
-	Cmd
+	Members
 }
 
-func (u VxLAN) Url(prefix, name string) string {
-	if name == "" {
-		return prefix + "/api/vxlan"
-	} else {
-		return prefix + "/api/vxlan/" + name
-	}
+func NewVxLAN() VxLAN {
+	return VxLAN{Members{Provider: "vxlan"}}
 }
 
 func (u VxLAN) Tmpl() string {
 	return `# total {{ len . }}
-{{ps -16 "name"}} {{ps -15 "bridge"}} {{ ps -16 "address" }} {{ps -16 "vni"}} {{ps -16 "local"}} {{ps -22 "remote"}}
+{{ps -16 "network"}} {{ps -15 "bridge"}} {{ps -16 "name"}} {{ps -8 "vni"}} {{ps -16 "local"}} {{ps -22 "remote"}}
 {{- range . }}
-{{ps -16 .UUID}} {{pt .AliveTime | ps -8}} {{ ps -8 .Device}} {{ps -16 .Alias}} {{ps -8 .User}} {{ps -22 .Remote}}
+{{- $net := . }}
+{{- range .Members }}
+{{ps -16 $net.Name}} {{ps -15 $net.Bridge}} {{ps -16 .Name}} {{pi -8 .Vni}} {{ps -16 .Local}} {{ps -22 .Remote}}
+{{- end }}
 {{- end }}
 `
 }
@@ -37,6 +37,36 @@ func (u VxLAN) List(c *cli.Context) error {
 	return u.Out(items, c.String("format"), u.Tmpl())
 }
 
+func (u VxLAN) Add(c *cli.Context) error {
+	return u.post(c, "", &schema.VxLAN{Name: c.String("network"), Mode: c.String("mode")})
+}
+
+func (u VxLAN) AddMember(c *cli.Context) error {
+	member := &schema.VxLANMember{
+		Name:   c.String("name"),
+		Vni:    c.Int("vni"),
+		Local:  c.String("local"),
+		Remote: c.String("remote"),
+		Port:   c.Int("port"),
+		Macs:   c.StringSlice("mac"),
+	}
+	if member.Vni == 0 || member.Remote == "" {
+		return libol.NewErr("vni or remote is empty")
+	}
+	return u.post(c, "/member", member)
+}
+
+func (u VxLAN) RemoveMember(c *cli.Context) error {
+	member := &schema.VxLANMember{
+		Name: c.String("name"),
+		Vni:  c.Int("vni"),
+	}
+	if member.Vni == 0 && member.Name == "" {
+		return libol.NewErr("name or vni is empty")
+	}
+	return u.delete(c, "/member", member)
+}
+
 func (u VxLAN) Commands(app *api.App) {
 	app.Command(&cli.Command{
 		Name:    "vxlan",
@@ -49,6 +79,55 @@ func (u VxLAN) Commands(app *api.App) {
 				Aliases: []string{"ls"},
 				Action:  u.List,
 			},
+			{
+				Name:  "add",
+				Usage: "Add a vxlan network",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "network"},
+					&cli.StringFlag{Name: "mode", Usage: "p2p or fdb"},
+				},
+				Action: u.Add,
+			},
+			{
+				Name:    "remove",
+				Usage:   "Remove a vxlan network",
+				Aliases: []string{"rm"},
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "network"},
+				},
+				Action: u.Remove,
+			},
+			{
+				Name:  "member",
+				Usage: "VxLAN member configuration",
+				Subcommands: []*cli.Command{
+					{
+						Name:  "add",
+						Usage: "Add or update a member",
+						Flags: []cli.Flag{
+							&cli.StringFlag{Name: "network"},
+							&cli.StringFlag{Name: "name"},
+							&cli.IntFlag{Name: "vni"},
+							&cli.StringFlag{Name: "local"},
+							&cli.StringFlag{Name: "remote"},
+							&cli.IntFlag{Name: "port"},
+							&cli.StringSliceFlag{Name: "mac", Usage: "static mac behind remote"},
+						},
+						Action: u.AddMember,
+					},
+					{
+						Name:    "del",
+						Usage:   "Delete a member by name or vni",
+						Aliases: []string{"rm"},
+						Flags: []cli.Flag{
+							&cli.StringFlag{Name: "network"},
+							&cli.StringFlag{Name: "name"},
+							&cli.IntFlag{Name: "vni"},
+						},
+						Action: u.RemoveMember,
+					},
+				},
+			},
 		},
 	})
 }
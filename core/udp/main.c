@@ -36,6 +36,7 @@
 
 #define RUN_DIR   "/var/openlan"
 #define UDP_PORT  4600
+#define IKE_PORT  4601
 
 VLOG_DEFINE_THIS_MODULE(main);
 /* Rate limit for error messages. */
@@ -44,6 +45,7 @@ static struct vlog_rate_limit rl = VLOG_RATE_LIMIT_INIT(5, 5);
 static char *default_db_ = NULL;
 static char *db_remote = NULL;
 static int32_t udp_port = 0;
+static int32_t ike_port = 0;
 
 struct udp_context {
     struct udp_server *srv;
@@ -80,12 +82,14 @@ usage %s [OPTIONS]\n\
 Options:\n\
   --port=PORT             connect to remote udp PORT\n\
                           (default: %d)\n\
+  --ike=PORT              relay ike to switch on loopback PORT\n\
+                          (default: %d)\n\
   --db=DATABASE           connect to database at DATABASE\n\
                           (default: %s)\n\
   -h, --help              display this help message\n\
   -o, --options           list available options\n\
   -V, --version           display version information\n\
-", program_name, program_name, default_db());
+", program_name, program_name, UDP_PORT, IKE_PORT, default_db());
     vlog_usage();
     exit(EXIT_SUCCESS);
 }
@@ -99,6 +103,7 @@ parse_options(int argc, char *argv[])
 
     static struct option long_options[] = {
         {"port", required_argument, NULL, 'p'},
+        {"ike", required_argument, NULL, 'i'},
         {"db", required_argument, NULL, 'd'},
         {"help", no_argument, NULL, 'h'},
         {"version", no_argument, NULL, 'V'},
@@ -124,6 +129,10 @@ parse_options(int argc, char *argv[])
             udp_port = atoi(optarg);
             break;
 
+        case 'i':
+            ike_port = atoi(optarg);
+            break;
+
         case 'h':
             usage();
 
@@ -148,6 +157,9 @@ parse_options(int argc, char *argv[])
     if (!udp_port) {
         udp_port = UDP_PORT;
     }
+    if (!ike_port) {
+        ike_port = IKE_PORT;
+    }
 }
 
 static void
@@ -228,7 +240,7 @@ static void
 pong_run(struct udp_context *ctx)
 {
     int retval;
-    u_int8_t buf[1024];
+    u_int8_t buf[2048];
     struct sockaddr_in from;
 
     struct udp_server *srv = ctx->srv;
@@ -238,6 +250,9 @@ pong_run(struct udp_context *ctx)
     if (retval <= 0) {
         return;
     }
+    if (relay_once(srv, &from, buf, retval, sizeof buf)) {
+        return;
+    }
     const char *remote_addr = inet_ntoa(from.sin_addr);
     char *spi_conn = xasprintf("spi:%d", ntohl(data->spi));
     struct shash_node *node = shash_find(&ctx->links, spi_conn);
@@ -304,6 +319,7 @@ main(int argc, char *argv[])
 
     struct udp_server srv = {
         .port = udp_port,
+        .ike_port = ike_port,
         .socket = -1,
         .send_t = time_msec(),
     };
@@ -91,6 +91,57 @@ recv_ping_once(struct udp_server *srv, struct sockaddr_in *addr, u_int8_t *buf,
     return retval;
 }
 
+static inline bool
+is_relay(u_int8_t *buf, size_t len)
+{
+    struct udp_relay *data = (struct udp_relay *)buf;
+
+    if (len < 12 || data->padding[0] || data->padding[1]) {
+        return false;
+    }
+    return memcmp(data->magic, UDP_RELAY_MAGIC, 4) == 0;
+}
+
+/* relay_once sends message from loopback to peer in it, and from peer
+ * to switch on loopback with address of peer. Returns zero if not relay. */
+int
+relay_once(struct udp_server *srv, struct sockaddr_in *from, u_int8_t *buf, size_t len, size_t size)
+{
+    int retval = 0;
+    struct udp_relay *data = (struct udp_relay *)buf;
+    struct sockaddr_in dst_addr = {
+        .sin_family = AF_INET,
+    };
+
+    if (!is_relay(buf, len)) {
+        return 0;
+    }
+    if ((ntohl(from->sin_addr.s_addr) >> 24) == 127) {
+        if (len < sizeof *data) {
+            return -1;
+        }
+        dst_addr.sin_addr.s_addr = data->address;
+        dst_addr.sin_port = data->port;
+        memmove(buf + 12, buf + sizeof *data, len - sizeof *data);
+        len -= sizeof *data - 12;
+    } else {
+        if (len + sizeof *data - 12 > size) {
+            return -1;
+        }
+        memmove(buf + sizeof *data, buf + 12, len - 12);
+        data->address = from->sin_addr.s_addr;
+        data->port = from->sin_port;
+        len += sizeof *data - 12;
+        dst_addr.sin_addr.s_addr = htonl(INADDR_LOOPBACK);
+        dst_addr.sin_port = htons(srv->ike_port);
+    }
+    retval = sendto(srv->socket, buf, len, 0, (struct sockaddr *)&dst_addr, sizeof dst_addr);
+    if (retval <= 0) {
+        VLOG_WARN_RL(&rl, "%s: could not relay data\n", inet_ntoa(dst_addr.sin_addr));
+    }
+    return retval;
+}
+
 int
 open_socket(struct udp_server *srv)
 {
@@ -11,8 +11,20 @@ struct udp_message {
     u_int32_t seqno;
 };
 
+/* IKE message is relayed between peer and switch on loopback. The
+ * address is of peer, and only carried on loopback. */
+#define UDP_RELAY_MAGIC "OLIK"
+
+struct udp_relay {
+    u_int32_t padding[2];
+    u_int8_t magic[4];
+    u_int32_t address;
+    u_int16_t port;
+} __attribute__((packed));
+
 struct udp_server {
     u_int16_t port;
+    u_int16_t ike_port;
     int32_t socket;
     long long int send_t;
 };
@@ -26,6 +38,7 @@ struct udp_connect {
 };
 
 int send_ping_once(struct udp_connect *);
+int relay_once(struct udp_server *, struct sockaddr_in *, u_int8_t *, size_t, size_t);
 int recv_ping_once(struct udp_server *, struct sockaddr_in *, u_int8_t *, size_t);
 
 int open_socket(struct udp_server *);
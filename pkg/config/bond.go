@@ -0,0 +1,42 @@
+package config
+
+type BondLink struct {
+	Connection string `json:"connection,omitempty"` // default is point's connection.
+	Protocol   string `json:"protocol,omitempty"`   // default is point's protocol.
+}
+
+type Bond struct {
+	Mode  string     `json:"mode,omitempty"` // hash or rr, and rr is unordered.
+	Links []BondLink `json:"links"`
+}
+
+func (b *Bond) Correct(ap *Point) {
+	if b.Mode == "" {
+		b.Mode = "hash"
+	}
+	for i := range b.Links {
+		link := &b.Links[i]
+		if link.Connection == "" {
+			link.Connection = ap.Connection
+		}
+		if link.Protocol == "" {
+			link.Protocol = ap.Protocol
+		}
+		CorrectAddr(&link.Connection, 10002)
+	}
+}
+
+// Points returns configuration of links, and they only forward frames.
+func (b *Bond) Points(ap *Point) []*Point {
+	points := make([]*Point, 0, len(b.Links))
+	for _, link := range b.Links {
+		obj := *ap
+		obj.Connection = link.Connection
+		obj.Protocol = link.Protocol
+		obj.RequestAddr = false
+		obj.Direct = nil
+		obj.Bond = nil
+		points = append(points, &obj)
+	}
+	return points
+}
@@ -17,12 +17,14 @@ func VarDir(name ...string) string {
 }
 
 type Queue struct {
-	SockWr int `json:"swr"` // per frames about 1572(1514+4+20+20+14)bytes
-	SockRd int `json:"srd"` // per frames
-	TapWr  int `json:"twr"` // per frames about 1572((1514+4+20+20+14))bytes
-	TapRd  int `json:"trd"` // per frames
-	VirSnd int `json:"vsd"`
-	VirWrt int `json:"vwr"`
+	SockWr int  `json:"swr"` // per frames about 1572(1514+4+20+20+14)bytes
+	SockRd int  `json:"srd"` // per frames
+	TapWr  int  `json:"twr"` // per frames about 1572((1514+4+20+20+14))bytes
+	TapRd  int  `json:"trd"` // per frames
+	VirSnd int  `json:"vsd"`
+	VirWrt int  `json:"vwr"`
+	SockBt int  `json:"sbt"`           // datagrams per syscall for udp
+	SockOl bool `json:"sol,omitempty"` // gso and gro for udp on linux
 }
 
 var (
@@ -32,6 +34,7 @@ var (
 	QdTrd = 2
 	QdVsd = 1024 * 8
 	QdVWr = 1024 * 4
+	QdSbt = 32
 )
 
 func (q *Queue) Default() {
@@ -53,6 +56,9 @@ func (q *Queue) Default() {
 	if q.VirWrt == 0 {
 		q.VirWrt = QdVWr
 	}
+	if q.SockBt == 0 {
+		q.SockBt = QdSbt
+	}
 	libol.Debug("Queue.Default %v", q)
 }
 
@@ -73,6 +79,19 @@ type Http struct {
 	Public string `json:"public,omitempty" yaml:"publicDir"`
 }
 
+type Compress struct {
+	Algo      string `json:"algo,omitempty"` // only deflate now.
+	Level     int    `json:"level,omitempty"`
+	Threshold int    `json:"threshold,omitempty"`
+}
+
+func GetCompressor(cfg *Compress) *libol.Compressor {
+	if cfg == nil {
+		return nil
+	}
+	return libol.NewCompressor(cfg.Algo, cfg.Level, cfg.Threshold)
+}
+
 type Crypt struct {
 	Algo   string `json:"algo,omitempty" yaml:"algorithm"`
 	Secret string `json:"secret,omitempty"`
@@ -82,6 +101,14 @@ func (c *Crypt) IsZero() bool {
 	return c.Algo == "" && c.Secret == ""
 }
 
+// Feature returns crypt suite to negotiate in login.
+func (c *Crypt) Feature() string {
+	if c == nil || c.IsZero() {
+		return ""
+	}
+	return libol.FeatCrypt + ":" + c.Algo
+}
+
 func (c *Crypt) Default() {
 	if c.Secret != "" && c.Algo == "" {
 		c.Algo = "xor"
@@ -145,15 +172,19 @@ func (c *Cert) GetCertPool() *x509.CertPool {
 }
 
 type Bridge struct {
-	Network  string `json:"network"`
-	Peer     string `json:"peer,omitempty" yaml:"peer,omitempty"`
-	Name     string `json:"name,omitempty" yaml:"name,omitempty"`
-	IPMtu    int    `json:"mtu,omitempty" yaml:"mtu,omitempty"`
-	Address  string `json:"address,omitempty" yaml:"address,omitempty"`
-	Provider string `json:"provider,omitempty" yaml:"provider,omitempty"`
-	Stp      string `json:"stp,omitempty" yaml:"stpState,omitempty"`
-	Delay    int    `json:"delay,omitempty" yaml:"forwardDelay,omitempty"`
-	Mss      int    `json:"tcpMss,omitempty" yaml:"tcpMss,omitempty"`
+	Network   string `json:"network"`
+	Peer      string `json:"peer,omitempty" yaml:"peer,omitempty"`
+	Name      string `json:"name,omitempty" yaml:"name,omitempty"`
+	IPMtu     int    `json:"mtu,omitempty" yaml:"mtu,omitempty"`
+	Address   string `json:"address,omitempty" yaml:"address,omitempty"`
+	Provider  string `json:"provider,omitempty" yaml:"provider,omitempty"`
+	Stp       string `json:"stp,omitempty" yaml:"stpState,omitempty"`
+	Delay     int    `json:"delay,omitempty" yaml:"forwardDelay,omitempty"`
+	Mss       int    `json:"tcpMss,omitempty" yaml:"tcpMss,omitempty"`
+	FlapLimit int    `json:"flapLimit,omitempty" yaml:"flapLimit,omitempty"` // moves of a mac in ten seconds.
+	FlapBlock int    `json:"flapBlock,omitempty" yaml:"flapBlock,omitempty"` // seconds to block port.
+	Storm     int    `json:"storm,omitempty" yaml:"storm,omitempty"`         // bum packets per second of a port.
+	Snooping  string `json:"snooping,omitempty" yaml:"snooping,omitempty"`
 }
 
 func (br *Bridge) Correct() {
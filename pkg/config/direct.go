@@ -0,0 +1,23 @@
+package config
+
+// Direct is configured for point to try punching and sending unicast
+// frames to other points directly, and fallback to relay by switch.
+// Frames on direct paths aren't filtered by acl of switch, so switch
+// doesn't offer peers in a network with acl.
+type Direct struct {
+	Listen   string `json:"listen,omitempty"`
+	Interval int    `json:"interval,omitempty"` // interval(s) for punching.
+	Timeout  int    `json:"timeout,omitempty"`  // path expired if not recv.
+}
+
+func (d *Direct) Correct() {
+	if d.Listen == "" {
+		d.Listen = "0.0.0.0:0"
+	}
+	if d.Interval == 0 {
+		d.Interval = 5
+	}
+	if d.Timeout == 0 {
+		d.Timeout = 6 * d.Interval
+	}
+}
@@ -11,6 +11,8 @@ const (
 	EspAuth  = "8bc736635c0642aebc20ba5420c3e93a"
 	EspCrypt = "4ac161f6635843b8b02c60cc36822515"
 	EspUdp   = 4600
+	EspIke   = 4601 // negotiate keys if auto, on loopback relayed by openudp.
+	EspProbe = 4602 // keepalive through tunnel.
 )
 
 type EspState struct {
@@ -22,6 +24,12 @@ type EspState struct {
 	Encap      string `json:"encap,omitempty" yaml:"encapsulation,omitempty"`
 	Auth       string `json:"auth,omitempty" yaml:"auth,omitempty"`
 	Crypt      string `json:"crypt,omitempty" yaml:"crypt,omitempty"`
+	Auto       bool   `json:"auto,omitempty" yaml:"auto,omitempty"`             // negotiate keys with peer.
+	Secret     string `json:"secret,omitempty" yaml:"secret,omitempty"`         // pre-shared for negotiation.
+	RekeyTime  int    `json:"rekeyTime,omitempty" yaml:"rekeyTime,omitempty"`   // in seconds
+	RekeyBytes int64  `json:"rekeyBytes,omitempty" yaml:"rekeyBytes,omitempty"` // outbound bytes
+	Interval   int    `json:"interval,omitempty" yaml:"interval,omitempty"`     // probe in seconds
+	Multiplier int    `json:"multiplier,omitempty" yaml:"multiplier,omitempty"` // lost probes to down
 }
 
 func (s *EspState) Padding(value string, size int) string {
@@ -42,6 +50,24 @@ func (s *EspState) Correct(obj *EspState) {
 		if s.RemotePort == 0 {
 			s.RemotePort = obj.RemotePort
 		}
+		if !s.Auto {
+			s.Auto = obj.Auto
+		}
+		if s.Secret == "" {
+			s.Secret = obj.Secret
+		}
+		if s.RekeyTime == 0 {
+			s.RekeyTime = obj.RekeyTime
+		}
+		if s.RekeyBytes == 0 {
+			s.RekeyBytes = obj.RekeyBytes
+		}
+		if s.Interval == 0 {
+			s.Interval = obj.Interval
+		}
+		if s.Multiplier == 0 {
+			s.Multiplier = obj.Multiplier
+		}
 	}
 	if s.Local == "" && s.Remote != "" {
 		addr, _ := libol.GetLocalByGw(s.Remote)
@@ -53,6 +79,24 @@ func (s *EspState) Correct(obj *EspState) {
 	if addr, _ := net.LookupIP(s.Remote); len(addr) > 0 {
 		s.RemoteIp = addr[0]
 	}
+	if s.Encap == "" {
+		s.Encap = "udp"
+	}
+	if s.RemotePort == 0 {
+		s.RemotePort = EspUdp
+	}
+	if s.RekeyTime == 0 {
+		s.RekeyTime = 3600
+	}
+	if s.Interval == 0 {
+		s.Interval = 2
+	}
+	if s.Multiplier == 0 {
+		s.Multiplier = 3
+	}
+	if s.Auto { // keys are negotiated with peer.
+		return
+	}
 	if s.Crypt == "" {
 		s.Crypt = s.Auth
 	}
@@ -62,16 +106,18 @@ func (s *EspState) Correct(obj *EspState) {
 	if s.Crypt == "" {
 		s.Crypt = EspCrypt
 	}
-	if s.Encap == "" {
-		s.Encap = "udp"
-	}
-	if s.RemotePort == 0 {
-		s.RemotePort = EspUdp
-	}
 	s.Auth = s.Padding(s.Auth, 32)
 	s.Crypt = s.Padding(s.Crypt, 32)
 }
 
+// CheckAuto returns error if secret isn't given for negotiation.
+func (s *EspState) CheckAuto() error {
+	if s.Secret == "" || s.Secret == EspAuth || s.Secret == EspCrypt {
+		return libol.NewErr("secret notGiven for auto keys")
+	}
+	return nil
+}
+
 type ESPPolicy struct {
 	Source string `json:"source,omitempty"`
 	Dest   string `json:"destination,omitempty"`
@@ -2,25 +2,94 @@ package config
 
 import "fmt"
 
+const (
+	FabricVxLAN  = "vxlan"
+	FabricSTT    = "stt"
+	FabricGeneve = "geneve"
+	FabricGRE    = "gre"
+	FabricGRETap = "gretap"
+)
+
+// GeneveOption maps an option of geneve to tunnel metadata, and it
+// carries metadata of network.
+type GeneveOption struct {
+	Class string `json:"class,omitempty"`
+	Type  int    `json:"type"`
+}
+
+func (c *GeneveOption) Correct() {
+	if c.Class == "" {
+		c.Class = "0xffff"
+	}
+}
+
 type FabricSpecifies struct {
 	Mss      int              `json:"tcpMss,omitempty"`
+	Mtu      int              `json:"mtu,omitempty"` // of underlay
 	Fragment bool             `json:"fragment"`
 	Driver   string           `json:"driver,omitempty"`
+	Geneve   *GeneveOption    `json:"geneve,omitempty"`
 	Name     string           `json:"name"`
 	Tunnels  []*FabricTunnel  `json:"tunnels"`
 	Networks []*FabricNetwork `json:"networks"`
 }
 
+// Overhead returns bytes of tunnel encapsulation including inner
+// ethernet.
+func (c *FabricSpecifies) Overhead() int {
+	switch c.Driver {
+	case FabricSTT:
+		return 20 + 20 + 18 + 14 // ip, tcp-like, stt and ethernet.
+	case FabricGeneve:
+		return 20 + 8 + 8 + 8 + 14 // ip, udp, geneve, an option and ethernet.
+	case FabricGRE, FabricGRETap:
+		return 20 + 8 + 14 // ip, gre with key and ethernet.
+	default:
+		return 20 + 8 + 8 + 14 // ip, udp, vxlan and ethernet.
+	}
+}
+
+// IsNew returns true if driver is added after vxlan and stt.
+func (c *FabricSpecifies) IsNew() bool {
+	switch c.Driver {
+	case FabricGeneve, FabricGRE, FabricGRETap:
+		return true
+	}
+	return false
+}
+
 func (c *FabricSpecifies) Correct() {
+	if c.Driver == "" {
+		c.Driver = FabricVxLAN
+	}
+	// not clamp for vxlan and stt as before, unless mtu is given.
+	if c.Mss == 0 && (c.Mtu > 0 || c.IsNew()) {
+		mtu := c.Mtu
+		if mtu == 0 {
+			mtu = 1500
+		}
+		c.Mss = mtu - c.Overhead() - 40
+	}
+	if c.Driver == FabricGeneve {
+		if c.Geneve == nil {
+			c.Geneve = &GeneveOption{}
+		}
+		c.Geneve.Correct()
+	}
 	for _, network := range c.Networks {
 		network.Correct()
 	}
 	for _, tun := range c.Tunnels {
 		tun.Correct()
 		if tun.DstPort == 0 {
-			if c.Driver == "stt" {
+			switch c.Driver {
+			case FabricSTT:
 				tun.DstPort = 7471
-			} else {
+			case FabricGeneve:
+				tun.DstPort = 6081
+			case FabricGRE, FabricGRETap:
+				// no port for gre.
+			default:
 				tun.DstPort = 4789 // 8472
 			}
 		}
@@ -38,10 +107,12 @@ func (c *FabricTunnel) Correct() {
 }
 
 type FabricNetwork struct {
-	Vni     uint32         `json:"vni"`
-	Bridge  string         `json:"bridge"`
-	Address string         `json:"address"`
-	Outputs []FabricOutput `json:"outputs"`
+	Vni       uint32           `json:"vni"`
+	Metadata  uint32           `json:"metadata,omitempty"` // of tenant by geneve.
+	Bridge    string           `json:"bridge"`
+	Address   string           `json:"address"`
+	Outputs   []FabricOutput   `json:"outputs"`
+	Neighbors []FabricNeighbor `json:"neighbors,omitempty"`
 }
 
 func (c *FabricNetwork) Correct() {
@@ -50,6 +121,12 @@ func (c *FabricNetwork) Correct() {
 	}
 }
 
+// FabricNeighbor is a static entry of arp responder.
+type FabricNeighbor struct {
+	Address string `json:"address"`
+	Mac     string `json:"mac"`
+}
+
 type FabricOutput struct {
 	Vlan      int    `json:"vlan"`
 	Interface string `json:"interface"`
@@ -0,0 +1,41 @@
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestFabricSpecifies_Mss(t *testing.T) {
+	c := &FabricSpecifies{}
+	c.Correct()
+	assert.Equal(t, FabricVxLAN, c.Driver, "be the same.")
+	assert.Equal(t, 0, c.Mss, "not clamp as before.")
+	c.Correct()
+	assert.Equal(t, 0, c.Mss, "not clamp as before.")
+
+	c = &FabricSpecifies{Driver: FabricSTT, Mtu: 1600}
+	c.Correct()
+	assert.Equal(t, 1600-72-40, c.Mss, "be the same.")
+
+	c = &FabricSpecifies{Driver: FabricGeneve}
+	c.Correct()
+	assert.Equal(t, 1500-58-40, c.Mss, "be the same.")
+
+	c = &FabricSpecifies{Driver: FabricGRE, Mss: 1300}
+	c.Correct()
+	assert.Equal(t, 1300, c.Mss, "be the same.")
+}
+
+func TestFabricSpecifies_DstPort(t *testing.T) {
+	c := &FabricSpecifies{
+		Driver: FabricGeneve,
+		Tunnels: []*FabricTunnel{
+			{Remote: "10.0.0.2"},
+			{Remote: "10.0.0.3", DstPort: 6082},
+		},
+	}
+	c.Correct()
+	assert.Equal(t, uint32(6081), c.Tunnels[0].DstPort, "be the same.")
+	assert.Equal(t, uint32(6082), c.Tunnels[1].DstPort, "be the same.")
+	assert.Equal(t, "0xffff", c.Geneve.Class, "be the same.")
+}
@@ -0,0 +1,131 @@
+package config
+
+import (
+	"fmt"
+	"github.com/danieldin95/openlan/pkg/libol"
+	"hash/fnv"
+)
+
+const (
+	GreTap  = "gretap" // ethernet over gre, and attached to bridge.
+	GreTun  = "gre"    // ip over gre.
+	GreIpip = "ipip"   // ip over ip.
+)
+
+type GreMember struct {
+	Name    string `json:"name,omitempty"`
+	Local   string `json:"local,omitempty"`
+	Remote  string `json:"remote"`
+	Key     uint32 `json:"key,omitempty"`
+	Address string `json:"address,omitempty"` // of device in l3.
+	Ttl     uint8  `json:"ttl,omitempty"`
+}
+
+func (m *GreMember) Equal(o *GreMember) bool {
+	return *m == *o
+}
+
+type GreSpecifies struct {
+	Name    string       `json:"name"`
+	Local   string       `json:"local,omitempty"`
+	Mode    string       `json:"mode,omitempty"`
+	Members []*GreMember `json:"members"`
+}
+
+// IsL2 returns true if devices are attached to bridge.
+func (n *GreSpecifies) IsL2() bool {
+	return n.Mode == GreTap
+}
+
+func (n *GreSpecifies) Correct() {
+	if n.Mode == "" {
+		n.Mode = GreTap
+	}
+	for _, m := range n.Members {
+		n.CorrectMember(m)
+	}
+}
+
+func (n *GreSpecifies) CorrectMember(m *GreMember) {
+	if m.Local == "" {
+		m.Local = n.Local
+	}
+	if n.Mode == GreIpip {
+		m.Key = 0 // no key for ipip.
+	}
+	if m.Name == "" {
+		name := "gt"
+		switch n.Mode {
+		case GreTun:
+			name = "gr"
+		case GreIpip:
+			name = "ip"
+		}
+		// hash key and remote, as name of device is less than 16.
+		h := fnv.New32a()
+		_, _ = fmt.Fprintf(h, "%d-%s", m.Key, m.Remote)
+		m.Name = fmt.Sprintf("%s%08x", name, h.Sum32())
+	}
+	if m.Ttl == 0 {
+		m.Ttl = 64
+	}
+}
+
+func (n *GreSpecifies) Empty() bool {
+	return n == nil || len(n.Members) == 0
+}
+
+// AddMembers adds or updates members by name, and an auto named member
+// which is same as another member is refused.
+func (n *GreSpecifies) AddMembers(o MemberSpecifies) error {
+	c, ok := o.(*GreSpecifies)
+	if !ok {
+		return libol.NewErr("%s not gre", n.Name)
+	}
+	if c.Mode != "" && c.Mode != n.Mode {
+		return libol.NewErr("could not change mode %s to %s", n.Mode, c.Mode)
+	}
+	members := append([]*GreMember{}, n.Members...)
+	for _, mem := range c.Members {
+		auto := mem.Name == ""
+		n.CorrectMember(mem)
+		found := false
+		for index, obj := range members {
+			if obj.Name != mem.Name {
+				continue
+			}
+			if auto && (obj.Key != mem.Key || obj.Remote != mem.Remote) {
+				return libol.NewErr("%s already used by %s", mem.Name, obj.Remote)
+			}
+			found = true
+			members[index] = mem
+		}
+		if !found {
+			members = append(members, mem)
+		}
+	}
+	n.Members = members
+	return nil
+}
+
+// DelMembers deletes members by name, or by remote if no name.
+func (n *GreSpecifies) DelMembers(o MemberSpecifies) error {
+	c, ok := o.(*GreSpecifies)
+	if !ok {
+		return libol.NewErr("%s not gre", n.Name)
+	}
+	members := make([]*GreMember, 0, len(n.Members))
+	for _, obj := range n.Members {
+		found := false
+		for _, mem := range c.Members {
+			if obj.Name == mem.Name || (mem.Name == "" && obj.Remote == mem.Remote) {
+				found = true
+			}
+		}
+		if !found {
+			members = append(members, obj)
+		}
+	}
+	n.Members = members
+	return nil
+}
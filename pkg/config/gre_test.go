@@ -0,0 +1,112 @@
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestGreSpecifies_CorrectMember(t *testing.T) {
+	n := &GreSpecifies{Local: "10.0.0.1"}
+	n.Correct()
+	assert.Equal(t, GreTap, n.Mode, "be the same.")
+	assert.True(t, n.IsL2(), "be true.")
+
+	a := &GreMember{Remote: "10.0.0.2", Key: 10}
+	b := &GreMember{Remote: "10.0.0.2", Key: 20}
+	n.CorrectMember(a)
+	n.CorrectMember(b)
+	assert.True(t, strings.HasPrefix(a.Name, "gt"), "be gretap.")
+	assert.True(t, len(a.Name) < 16, "be short.")
+	assert.NotEqual(t, a.Name, b.Name, "be different.")
+	assert.Equal(t, "10.0.0.1", a.Local, "be the same.")
+	assert.Equal(t, uint8(64), a.Ttl, "be the same.")
+
+	n = &GreSpecifies{Mode: GreIpip}
+	c := &GreMember{Remote: "10.0.0.2", Key: 10}
+	n.CorrectMember(c)
+	assert.True(t, strings.HasPrefix(c.Name, "ip"), "be ipip.")
+	assert.Equal(t, uint32(0), c.Key, "no key.")
+	assert.False(t, n.IsL2(), "be false.")
+
+	n = &GreSpecifies{Mode: GreTun}
+	d := &GreMember{Name: "gre-a", Remote: "10.0.0.2"}
+	n.CorrectMember(d)
+	assert.Equal(t, "gre-a", d.Name, "be the same.")
+}
+
+func TestGreSpecifies_AddMembers(t *testing.T) {
+	n := &GreSpecifies{Mode: GreTun}
+	n.Correct()
+	err := n.AddMembers(&GreSpecifies{
+		Members: []*GreMember{
+			{Remote: "10.0.0.2", Key: 10},
+			{Name: "gre-b", Remote: "10.0.0.3", Address: "192.168.1.1/30"},
+		},
+	})
+	assert.Nil(t, err, "be nil.")
+	assert.Equal(t, 2, len(n.Members), "be the same.")
+	auto := n.Members[0].Name
+
+	// update by name.
+	err = n.AddMembers(&GreSpecifies{
+		Members: []*GreMember{{Name: "gre-b", Remote: "10.0.0.4"}},
+	})
+	assert.Nil(t, err, "be nil.")
+	assert.Equal(t, 2, len(n.Members), "be the same.")
+	assert.Equal(t, "10.0.0.4", n.Members[1].Remote, "be the same.")
+
+	// same auto name is updated.
+	err = n.AddMembers(&GreSpecifies{
+		Members: []*GreMember{{Remote: "10.0.0.2", Key: 10, Ttl: 32}},
+	})
+	assert.Nil(t, err, "be nil.")
+	assert.Equal(t, 2, len(n.Members), "be the same.")
+	assert.Equal(t, auto, n.Members[0].Name, "be the same.")
+	assert.Equal(t, uint8(32), n.Members[0].Ttl, "be the same.")
+
+	// auto name is used by another remote.
+	err = n.AddMembers(&GreSpecifies{
+		Members: []*GreMember{{Name: auto, Remote: "10.0.0.5"}},
+	})
+	assert.Nil(t, err, "update by name.")
+	err = n.AddMembers(&GreSpecifies{
+		Members: []*GreMember{{Remote: "10.0.0.2", Key: 10}},
+	})
+	assert.NotNil(t, err, "be refused.")
+	assert.Equal(t, "10.0.0.5", n.Members[0].Remote, "be the same.")
+
+	err = n.AddMembers(&GreSpecifies{Mode: GreIpip})
+	assert.NotNil(t, err, "could not change mode.")
+	assert.Equal(t, GreTun, n.Mode, "be the same.")
+
+	err = n.AddMembers(&VxLANSpecifies{})
+	assert.NotNil(t, err, "not gre.")
+}
+
+func TestGreSpecifies_DelMembers(t *testing.T) {
+	n := &GreSpecifies{}
+	n.Correct()
+	err := n.AddMembers(&GreSpecifies{
+		Members: []*GreMember{
+			{Remote: "10.0.0.2", Key: 10},
+			{Remote: "10.0.0.2", Key: 20},
+			{Name: "gre-c", Remote: "10.0.0.3"},
+		},
+	})
+	assert.Nil(t, err, "be nil.")
+
+	err = n.DelMembers(&GreSpecifies{
+		Members: []*GreMember{{Name: "gre-c"}},
+	})
+	assert.Nil(t, err, "be nil.")
+	assert.Equal(t, 2, len(n.Members), "be the same.")
+
+	// by remote if no name.
+	err = n.DelMembers(&GreSpecifies{
+		Members: []*GreMember{{Remote: "10.0.0.2"}},
+	})
+	assert.Nil(t, err, "be nil.")
+	assert.Equal(t, 0, len(n.Members), "be the same.")
+	assert.True(t, n.Empty(), "be true.")
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"github.com/danieldin95/openlan/pkg/libol"
+)
+
+// Kcp keeps shards and dscp as pointers, and 0 disables fec or dscp.
+type Kcp struct {
+	Mode        string `json:"mode,omitempty"` // normal, fast, fast2 and fast3.
+	SndWnd      int    `json:"sndwnd,omitempty"`
+	RcvWnd      int    `json:"rcvwnd,omitempty"`
+	Mtu         int    `json:"mtu,omitempty"`
+	DataShard   *int   `json:"datashard,omitempty"`
+	ParityShard *int   `json:"parityshard,omitempty"`
+	Dscp        *int   `json:"dscp,omitempty"`
+}
+
+func (k *Kcp) Default() {
+	obj := libol.NewKcpConfig()
+	if k.Mode == "" {
+		k.Mode = obj.Mode
+	}
+	if k.SndWnd == 0 {
+		k.SndWnd = obj.SndWnd
+	}
+	if k.RcvWnd == 0 {
+		k.RcvWnd = obj.RcvWnd
+	}
+	if k.Mtu == 0 {
+		k.Mtu = obj.Mtu
+	}
+	if k.DataShard == nil && k.ParityShard == nil {
+		k.DataShard = &obj.DataShards
+		k.ParityShard = &obj.ParityShards
+	}
+	if k.DataShard == nil {
+		k.DataShard = new(int)
+	}
+	if k.ParityShard == nil {
+		k.ParityShard = new(int)
+	}
+	if k.Dscp == nil {
+		k.Dscp = &obj.Dscp
+	}
+}
+
+// GetKcp returns a new kcp config owned by caller.
+func GetKcp(cfg *Kcp) *libol.KcpConfig {
+	obj := libol.NewKcpConfig()
+	if cfg == nil {
+		return obj
+	}
+	if cfg.Mode != "" {
+		obj.Mode = cfg.Mode
+	}
+	if cfg.SndWnd > 0 {
+		obj.SndWnd = cfg.SndWnd
+	}
+	if cfg.RcvWnd > 0 {
+		obj.RcvWnd = cfg.RcvWnd
+	}
+	if cfg.Mtu > 0 {
+		obj.Mtu = cfg.Mtu
+	}
+	if cfg.DataShard != nil || cfg.ParityShard != nil {
+		obj.DataShards = 0
+		obj.ParityShards = 0
+		if cfg.DataShard != nil {
+			obj.DataShards = *cfg.DataShard
+		}
+		if cfg.ParityShard != nil {
+			obj.ParityShards = *cfg.ParityShard
+		}
+	}
+	if cfg.Dscp != nil {
+		obj.Dscp = *cfg.Dscp
+	}
+	return obj
+}
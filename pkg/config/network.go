@@ -23,6 +23,17 @@ type Network struct {
 	Specifies interface{}   `json:"specifies,omitempty" yaml:"specifies,omitempty"`
 }
 
+// MemberSpecifies is specifies of which members are added or deleted
+// at runtime.
+type MemberSpecifies interface {
+	Correct()
+	// AddMembers adds or updates members of o by name.
+	AddMembers(o MemberSpecifies) error
+	// DelMembers deletes members matched by o.
+	DelMembers(o MemberSpecifies) error
+	Empty() bool
+}
+
 func (n *Network) Correct() {
 	switch n.Provider {
 	case "esp":
@@ -55,6 +66,19 @@ func (n *Network) Correct() {
 			obj.Correct()
 			obj.Name = n.Name
 		}
+	case "gre":
+		spec := n.Specifies
+		if obj, ok := spec.(*GreSpecifies); ok {
+			obj.Correct()
+			obj.Name = n.Name
+			if obj.IsL2() && n.Bridge == nil {
+				n.Bridge = &Bridge{}
+			}
+		}
+		if br := n.Bridge; br != nil {
+			br.Network = n.Name
+			br.Correct()
+		}
 	default:
 		if n.Bridge == nil {
 			n.Bridge = &Bridge{}
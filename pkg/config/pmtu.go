@@ -0,0 +1,29 @@
+package config
+
+const (
+	PmtuProbe = "probe" // only discover it.
+	PmtuMtu   = "mtu"   // and set mtu of tap device.
+	PmtuMss   = "mss"   // and clamp mss of tcp syn.
+)
+
+// Pmtu discovers mtu of path to switch by padded pings.
+type Pmtu struct {
+	Mode     string `json:"mode,omitempty"`     // probe, mtu or mss.
+	Min      int    `json:"min,omitempty"`      // assumed to be passed.
+	Interval int    `json:"interval,omitempty"` // seconds to probe again.
+}
+
+func (p *Pmtu) Correct(ap *Point) {
+	if p.Mode == "" {
+		p.Mode = PmtuMss
+	}
+	if p.Min == 0 {
+		p.Min = 1280
+	}
+	if p.Min > ap.Interface.IPMtu {
+		p.Min = ap.Interface.IPMtu
+	}
+	if p.Interval == 0 {
+		p.Interval = 600
+	}
+}
@@ -20,6 +20,10 @@ type Point struct {
 	Log         Log       `json:"log"`
 	Http        *Http     `json:"http,omitempty"`
 	Crypt       *Crypt    `json:"crypt,omitempty"`
+	Kcp         *Kcp      `json:"kcp,omitempty"`
+	Compress    *Compress `json:"compress,omitempty"`
+	Bond        *Bond     `json:"bond,omitempty"`
+	Pmtu        *Pmtu     `json:"pmtu,omitempty"`
 	PProf       string    `json:"pprof,omitempty"`
 	RequestAddr bool      `json:"requestAddr,omitempty"`
 	ByPass      bool      `json:"bypass,omitempty"`
@@ -29,6 +33,9 @@ type Point struct {
 	Cert        *Cert     `json:"cert,omitempty"`
 	StatusFile  string    `json:"status,omitempty"`
 	PidFile     string    `json:"pid,omitempty"`
+	Direct      *Direct   `json:"direct,omitempty"`
+	Proxy       string    `json:"proxy,omitempty"`
+	Roaming     bool      `json:"roaming,omitempty"`
 }
 
 func DefaultPoint() *Point {
@@ -36,7 +43,7 @@ func DefaultPoint() *Point {
 		Alias:      "",
 		Connection: "xx.openlan.net",
 		Network:    "default",
-		Protocol:   "tcp", // udp, kcp, tcp, tls, ws and wss etc.
+		Protocol:   "tcp", // udp, kcp, tcp, tls, ws, wss and mem etc.
 		Timeout:    60,
 		Log: Log{
 			File:    "./point.log",
@@ -95,6 +102,7 @@ func (ap *Point) Flags() {
 	flag.IntVar(&ap.Log.Verbose, "log:level", obj.Log.Verbose, "Log level value")
 	flag.StringVar(&ap.StatusFile, "status", obj.StatusFile, "File status saved to")
 	flag.StringVar(&ap.PidFile, "pid", obj.PidFile, "Write pid to file")
+	flag.StringVar(&ap.Proxy, "proxy", obj.Proxy, "Upstream proxy, such as: socks5://host:1080")
 }
 
 func (ap *Point) Parse() {
@@ -142,6 +150,9 @@ func (ap *Point) Correct(obj *Point) {
 	if ap.Protocol == "" {
 		ap.Protocol = "tcp"
 	}
+	if ap.Direct != nil {
+		ap.Direct.Correct()
+	}
 }
 
 func (ap *Point) Default() {
@@ -164,6 +175,15 @@ func (ap *Point) Default() {
 	if ap.Crypt != nil {
 		ap.Crypt.Default()
 	}
+	if ap.Kcp != nil {
+		ap.Kcp.Default()
+	}
+	if ap.Bond != nil {
+		ap.Bond.Correct(ap)
+	}
+	if ap.Pmtu != nil {
+		ap.Pmtu.Correct(ap)
+	}
 }
 
 func (ap *Point) Load() error {
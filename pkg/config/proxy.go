@@ -3,6 +3,7 @@ package config
 import (
 	"flag"
 	"github.com/danieldin95/openlan/pkg/libol"
+	"strings"
 )
 
 type ShadowProxy struct {
@@ -15,9 +16,37 @@ type ShadowProxy struct {
 	Protocol   string `json:"protocol,omitempty"`
 }
 
+const (
+	SocksAllow = "allow"
+	SocksDeny  = "deny"
+)
+
+// SocksRule matches destination by cidr, and limited by port if
+// configured. The action is allow or deny.
+type SocksRule struct {
+	Cidr   []string `json:"cidr,omitempty"`
+	Port   []int    `json:"port,omitempty"`
+	Action string   `json:"action"`
+}
+
+func (r *SocksRule) Correct() {
+	if r.Action == "" {
+		r.Action = SocksAllow
+	}
+	correctCidr(r.Cidr)
+}
+
 type SocksProxy struct {
-	Listen string   `json:"listen,omitempty"`
-	Auth   Password `json:"auth,omitempty"`
+	Listen string       `json:"listen,omitempty"`
+	Auth   Password     `json:"auth,omitempty"`
+	Users  []Password   `json:"users,omitempty"`
+	Rules  []*SocksRule `json:"rules,omitempty"`
+}
+
+func (s *SocksProxy) Correct() {
+	for _, r := range s.Rules {
+		r.Correct()
+	}
 }
 
 type HttpProxy struct {
@@ -26,19 +55,118 @@ type HttpProxy struct {
 	Cert   *Cert    `json:"cert,omitempty"`
 }
 
+// TcpCheck is active health check for targets, and target is up
+// after rise times success, and down after fall times failure.
+type TcpCheck struct {
+	Interval int `json:"interval,omitempty"` // in seconds
+	Timeout  int `json:"timeout,omitempty"`  // in seconds
+	Rise     int `json:"rise,omitempty"`
+	Fall     int `json:"fall,omitempty"`
+}
+
+func (c *TcpCheck) Correct() {
+	if c.Interval == 0 {
+		c.Interval = 5
+	}
+	if c.Timeout == 0 {
+		c.Timeout = 2
+	}
+	if c.Rise == 0 {
+		c.Rise = 2
+	}
+	if c.Fall == 0 {
+		c.Fall = 3
+	}
+}
+
+const (
+	TcpRoundRobin = "roundrobin"
+	TcpLeastConn  = "leastconn"
+	TcpSourceHash = "sourcehash"
+)
+
 type TcpProxy struct {
-	Listen string   `json:"listen,omitempty"`
-	Target []string `json:"target,omitempty"`
+	Listen   string         `json:"listen,omitempty"`
+	Target   []string       `json:"target,omitempty"`
+	Weight   map[string]int `json:"weight,omitempty"`   // by target
+	Strategy string         `json:"strategy,omitempty"` // roundrobin, leastconn or sourcehash
+	Check    *TcpCheck      `json:"check,omitempty"`
+	Eject    int            `json:"eject,omitempty"` // in seconds after dial error
+}
+
+func (t *TcpProxy) Correct() {
+	if t.Strategy == "" {
+		t.Strategy = TcpRoundRobin
+	}
+	if t.Eject == 0 {
+		t.Eject = 30
+	}
+	if t.Check != nil {
+		t.Check.Correct()
+	}
+}
+
+// ProxyUpstream is a named upstream for chaining, which is
+// an url of http://, https:// and socks5://, or a shadow server.
+type ProxyUpstream struct {
+	Name   string       `json:"name"`
+	Url    string       `json:"url,omitempty"`
+	Shadow *ShadowProxy `json:"shadow,omitempty"`
+}
+
+const (
+	ProxyDirect = "direct"
+	ProxyReject = "reject"
+)
+
+// ProxyRule matches destination by any of domain suffix, keyword
+// or cidr, and limited by port if configured. The action is
+// direct, reject or name of upstream.
+type ProxyRule struct {
+	Suffix  []string `json:"suffix,omitempty"`
+	Keyword []string `json:"keyword,omitempty"`
+	Cidr    []string `json:"cidr,omitempty"`
+	Port    []int    `json:"port,omitempty"`
+	Action  string   `json:"action"`
+}
+
+func (r *ProxyRule) Correct() {
+	if r.Action == "" {
+		r.Action = ProxyDirect
+	}
+	for i, suffix := range r.Suffix {
+		r.Suffix[i] = strings.ToLower(strings.TrimPrefix(suffix, "."))
+	}
+	for i, keyword := range r.Keyword {
+		r.Keyword[i] = strings.ToLower(keyword)
+	}
+	correctCidr(r.Cidr)
+}
+
+// correctCidr appends prefix for address without it.
+func correctCidr(values []string) {
+	for i, cidr := range values {
+		if strings.Contains(cidr, "/") {
+			continue
+		}
+		if strings.Contains(cidr, ":") {
+			values[i] = cidr + "/128"
+		} else {
+			values[i] = cidr + "/32"
+		}
+	}
 }
 
 type Proxy struct {
-	Conf   string         `json:"-"`
-	Log    Log            `json:"log"`
-	Socks  []*SocksProxy  `json:"socks,omitempty"`
-	Http   []*HttpProxy   `json:"http,omitempty"`
-	Tcp    []*TcpProxy    `json:"tcp,omitempty"`
-	Shadow []*ShadowProxy `json:"shadow,omitempty"`
-	PProf  string         `json:"pprof"`
+	Conf     string           `json:"-"`
+	Log      Log              `json:"log"`
+	Socks    []*SocksProxy    `json:"socks,omitempty"`
+	Http     []*HttpProxy     `json:"http,omitempty"`
+	Tcp      []*TcpProxy      `json:"tcp,omitempty"`
+	Shadow   []*ShadowProxy   `json:"shadow,omitempty"`
+	Upstream []*ProxyUpstream `json:"upstream,omitempty"`
+	Rules    []*ProxyRule     `json:"rules,omitempty"`
+	PProf    string           `json:"pprof"`
 }
 
 func DefaultProxy() *Proxy {
@@ -89,6 +217,15 @@ func (p *Proxy) Correct(obj *Proxy) {
 			h.Cert.Correct()
 		}
 	}
+	for _, s := range p.Socks {
+		s.Correct()
+	}
+	for _, t := range p.Tcp {
+		t.Correct()
+	}
+	for _, r := range p.Rules {
+		r.Correct()
+	}
 }
 
 func (p *Proxy) Default() {
@@ -63,26 +63,30 @@ func (p *Perf) Correct(obj *Perf) {
 }
 
 type Switch struct {
-	File      string     `json:"file"`
-	Alias     string     `json:"alias"`
-	Perf      Perf       `json:"limit,omitempty" yaml:"limit"`
-	Protocol  string     `json:"protocol"` // tcp, tls, udp, kcp, ws and wss.
-	Listen    string     `json:"listen"`
-	Timeout   int        `json:"timeout"`
-	Http      *Http      `json:"http,omitempty"`
-	Log       Log        `json:"log"`
-	Cert      *Cert      `json:"cert,omitempty"`
-	Crypt     *Crypt     `json:"crypt,omitempty"`
-	Network   []*Network `json:"network,omitempty" yaml:"networks"`
-	Acl       []*ACL     `json:"acl,omitempty" yaml:"acl,omitempty"`
-	FireWall  []FlowRule `json:"firewall,omitempty" yaml:"firewall,omitempty"`
-	Inspect   []string   `json:"inspect,omitempty" yaml:"inspect,omitempty"`
-	Queue     Queue      `json:"queue" yaml:"queue"`
-	PassFile  string     `json:"password" yaml:"passwordFile"`
-	Ldap      *LDAP      `json:"ldap,omitempty" yaml:"ldap,omitempty"`
-	AddrPool  string     `json:"pool,omitempty"`
-	ConfDir   string     `json:"-" yaml:"-"`
-	TokenFile string     `json:"-" yaml:"-"`
+	File       string     `json:"file"`
+	Alias      string     `json:"alias"`
+	Perf       Perf       `json:"limit,omitempty" yaml:"limit"`
+	Protocol   string     `json:"protocol"` // tcp, tls, udp, kcp, ws, wss and mem.
+	Listen     string     `json:"listen"`
+	Timeout    int        `json:"timeout"`
+	Http       *Http      `json:"http,omitempty"`
+	Log        Log        `json:"log"`
+	Cert       *Cert      `json:"cert,omitempty"`
+	Crypt      *Crypt     `json:"crypt,omitempty"`
+	Kcp        *Kcp       `json:"kcp,omitempty" yaml:"kcp,omitempty"`
+	Compress   *Compress  `json:"compress,omitempty" yaml:"compress,omitempty"`
+	Network    []*Network `json:"network,omitempty" yaml:"networks"`
+	Acl        []*ACL     `json:"acl,omitempty" yaml:"acl,omitempty"`
+	FireWall   []FlowRule `json:"firewall,omitempty" yaml:"firewall,omitempty"`
+	Inspect    []string   `json:"inspect,omitempty" yaml:"inspect,omitempty"`
+	Queue      Queue      `json:"queue" yaml:"queue"`
+	PassFile   string     `json:"password" yaml:"passwordFile"`
+	Ldap       *LDAP      `json:"ldap,omitempty" yaml:"ldap,omitempty"`
+	AddrPool   string     `json:"pool,omitempty"`
+	Rendezvous string     `json:"rendezvous,omitempty"`
+	Listeners  int        `json:"listeners,omitempty"` // by SO_REUSEPORT, and -1 is per core.
+	ConfDir    string     `json:"-" yaml:"-"`
+	TokenFile  string     `json:"-" yaml:"-"`
 }
 
 func DefaultSwitch() *Switch {
@@ -134,6 +138,9 @@ func (s *Switch) Correct(obj *Switch) {
 		s.Alias = GetAlias()
 	}
 	CorrectAddr(&s.Listen, 10002)
+	if s.Rendezvous != "" {
+		CorrectAddr(&s.Rendezvous, 10004)
+	}
 	if s.Http != nil {
 		CorrectAddr(&s.Http.Listen, 10000)
 	}
@@ -185,6 +192,8 @@ func (s *Switch) LoadNetwork() {
 			obj.Specifies = &VxLANSpecifies{}
 		case "fabric":
 			obj.Specifies = &FabricSpecifies{}
+		case "gre":
+			obj.Specifies = &GreSpecifies{}
 		}
 		if obj.Specifies != nil {
 			if err := libol.UnmarshalLoad(obj, k); err != nil {
@@ -240,6 +249,9 @@ func (s *Switch) Default() {
 	if s.Crypt != nil {
 		s.Crypt.Default()
 	}
+	if s.Kcp != nil {
+		s.Kcp.Default()
+	}
 	queue := &s.Queue
 	queue.Default()
 	s.LoadAcl()
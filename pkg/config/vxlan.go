@@ -2,15 +2,36 @@ package config
 
 import (
 	"fmt"
+	"github.com/danieldin95/openlan/pkg/libol"
+	"hash/fnv"
+)
+
+const (
+	VxLANP2P = "p2p" // a device per member.
+	VxLANFdb = "fdb" // a device per vni, flooding and configured macs by fdb.
 )
 
 type VxLANMember struct {
-	Name    string `json:"name,omitempty"`
-	VNI     int    `json:"vni"`
-	Local   string `json:"local,omitempty"`
-	Remote  string `json:"remote"`
-	Network string `json:"network,omitempty"`
-	Port    int    `json:"port,omitempty"`
+	Name    string   `json:"name,omitempty"`
+	VNI     int      `json:"vni"`
+	Local   string   `json:"local,omitempty"`
+	Remote  string   `json:"remote"`
+	Network string   `json:"network,omitempty"`
+	Port    int      `json:"port,omitempty"`
+	Macs    []string `json:"macs,omitempty"` // static behind remote.
+}
+
+func (m *VxLANMember) Equal(o *VxLANMember) bool {
+	if m.Name != o.Name || m.VNI != o.VNI || m.Local != o.Local ||
+		m.Remote != o.Remote || m.Port != o.Port || len(m.Macs) != len(o.Macs) {
+		return false
+	}
+	for i := range m.Macs {
+		if m.Macs[i] != o.Macs[i] {
+			return false
+		}
+	}
+	return true
 }
 
 func (m *VxLANMember) Correct() {
@@ -22,14 +43,93 @@ func (m *VxLANMember) Correct() {
 type VxLANSpecifies struct {
 	Name    string         `json:"name"`
 	Local   string         `json:"local,omitempty"`
+	Mode    string         `json:"mode,omitempty"`
 	Members []*VxLANMember `json:"members"`
 }
 
 func (n *VxLANSpecifies) Correct() {
+	if n.Mode == "" {
+		n.Mode = VxLANP2P
+	}
 	for _, m := range n.Members {
-		if m.Local == "" {
-			m.Local = n.Local
+		n.CorrectMember(m)
+	}
+}
+
+func (n *VxLANSpecifies) CorrectMember(m *VxLANMember) {
+	if m.Local == "" {
+		m.Local = n.Local
+	}
+	// members of a vni share device, so name by remote.
+	if n.Mode == VxLANFdb && m.Name == "" {
+		m.Name = fmt.Sprintf("vni%d-%s", m.VNI, m.Remote)
+	}
+	m.Correct()
+}
+
+// FdbName returns name of device shared by members of vni, and hashes
+// network and vni, as name of device is less than 16.
+func (n *VxLANSpecifies) FdbName(vni int) string {
+	h := fnv.New32a()
+	_, _ = fmt.Fprintf(h, "%s-%d", n.Name, vni)
+	return fmt.Sprintf("vf%08x", h.Sum32())
+}
+
+func (n *VxLANSpecifies) Empty() bool {
+	return n == nil || len(n.Members) == 0
+}
+
+// AddMembers adds or updates members by name, and an auto named member
+// which is same as another member is refused.
+func (n *VxLANSpecifies) AddMembers(o MemberSpecifies) error {
+	c, ok := o.(*VxLANSpecifies)
+	if !ok {
+		return libol.NewErr("%s not vxlan", n.Name)
+	}
+	if c.Mode != "" {
+		n.Mode = c.Mode
+	}
+	members := append([]*VxLANMember{}, n.Members...)
+	for _, mem := range c.Members {
+		auto := mem.Name == ""
+		n.CorrectMember(mem)
+		found := false
+		for index, obj := range members {
+			if obj.Name != mem.Name {
+				continue
+			}
+			if auto && (obj.VNI != mem.VNI || obj.Remote != mem.Remote) {
+				return libol.NewErr("%s already used by %s", mem.Name, obj.Remote)
+			}
+			found = true
+			members[index] = mem
+		}
+		if !found {
+			members = append(members, mem)
+		}
+	}
+	n.Members = members
+	return nil
+}
+
+// DelMembers deletes members by name, or by vni if no name.
+func (n *VxLANSpecifies) DelMembers(o MemberSpecifies) error {
+	c, ok := o.(*VxLANSpecifies)
+	if !ok {
+		return libol.NewErr("%s not vxlan", n.Name)
+	}
+	members := make([]*VxLANMember, 0, len(n.Members))
+	for _, obj := range n.Members {
+		found := false
+		for _, mem := range c.Members {
+			if obj.Name == mem.Name || (mem.Name == "" && obj.VNI == mem.VNI) {
+				found = true
+			}
+		}
+		if !found {
+			members = append(members, obj)
 		}
-		m.Correct()
 	}
+	n.Members = members
+	return nil
 }
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestVxLANSpecifies_AddMembers(t *testing.T) {
+	n := &VxLANSpecifies{Name: "vx", Local: "10.0.0.1"}
+	n.Correct()
+	assert.Equal(t, VxLANP2P, n.Mode, "be the same.")
+
+	err := n.AddMembers(&VxLANSpecifies{
+		Members: []*VxLANMember{
+			{VNI: 10, Remote: "10.0.0.2"},
+			{VNI: 20, Remote: "10.0.0.3"},
+		},
+	})
+	assert.Nil(t, err, "be nil.")
+	assert.Equal(t, 2, len(n.Members), "be the same.")
+	assert.Equal(t, "vni10", n.Members[0].Name, "be the same.")
+	assert.Equal(t, "10.0.0.1", n.Members[0].Local, "be the same.")
+
+	// update by name.
+	err = n.AddMembers(&VxLANSpecifies{
+		Members: []*VxLANMember{{Name: "vni20", VNI: 20, Remote: "10.0.0.4"}},
+	})
+	assert.Nil(t, err, "be nil.")
+	assert.Equal(t, 2, len(n.Members), "be the same.")
+	assert.Equal(t, "10.0.0.4", n.Members[1].Remote, "be the same.")
+
+	// auto name is same as another remote.
+	err = n.AddMembers(&VxLANSpecifies{
+		Members: []*VxLANMember{{VNI: 10, Remote: "10.0.0.5"}},
+	})
+	assert.NotNil(t, err, "be refused.")
+	assert.Equal(t, "10.0.0.2", n.Members[0].Remote, "be the same.")
+
+	err = n.AddMembers(&GreSpecifies{})
+	assert.NotNil(t, err, "not vxlan.")
+}
+
+func TestVxLANSpecifies_DelMembers(t *testing.T) {
+	n := &VxLANSpecifies{Name: "vx", Mode: VxLANFdb}
+	n.Correct()
+	err := n.AddMembers(&VxLANSpecifies{
+		Members: []*VxLANMember{
+			{VNI: 10, Remote: "10.0.0.2"},
+			{VNI: 10, Remote: "10.0.0.3"},
+			{VNI: 20, Remote: "10.0.0.2"},
+		},
+	})
+	assert.Nil(t, err, "be nil.")
+	assert.Equal(t, 3, len(n.Members), "be the same.")
+	assert.Equal(t, "vni10-10.0.0.3", n.Members[1].Name, "be the same.")
+
+	// by name.
+	err = n.DelMembers(&VxLANSpecifies{
+		Members: []*VxLANMember{{Name: "vni10-10.0.0.3"}},
+	})
+	assert.Nil(t, err, "be nil.")
+	assert.Equal(t, 2, len(n.Members), "be the same.")
+
+	// by vni if no name.
+	err = n.DelMembers(&VxLANSpecifies{
+		Members: []*VxLANMember{{VNI: 10}},
+	})
+	assert.Nil(t, err, "be nil.")
+	assert.Equal(t, 1, len(n.Members), "be the same.")
+	assert.Equal(t, 20, n.Members[0].VNI, "be the same.")
+}
+
+func TestVxLANSpecifies_FdbName(t *testing.T) {
+	a := &VxLANSpecifies{Name: "vx-a"}
+	b := &VxLANSpecifies{Name: "vx-b"}
+	assert.Equal(t, a.FdbName(10), a.FdbName(10), "be the same.")
+	assert.NotEqual(t, a.FdbName(10), a.FdbName(20), "be different.")
+	assert.NotEqual(t, a.FdbName(10), b.FdbName(10), "be different.")
+	assert.True(t, len(a.FdbName(16777215)) < 16, "be short.")
+
+	// auto named member of p2p is not a device of fdb.
+	m := &VxLANMember{VNI: 10, Remote: "10.0.0.2"}
+	a.CorrectMember(m)
+	assert.NotEqual(t, m.Name, a.FdbName(10), "be different.")
+}
@@ -0,0 +1,170 @@
+package libol
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// BondHash keeps frames of a flow on one member.
+	BondHash = "hash"
+	// BondRoundRobin spreads frames without sequencing, so frames of a flow
+	// may arrive out of order if latency of members differs.
+	BondRoundRobin = "rr"
+)
+
+// Bundle spreads frames over multiple connections of one session.
+type Bundle struct {
+	lock    sync.RWMutex
+	mode    string
+	members []SocketClient
+	next    uint32
+}
+
+func NewBundle(mode string) *Bundle {
+	if mode != BondRoundRobin {
+		mode = BondHash
+	}
+	return &Bundle{
+		mode:    mode,
+		members: make([]SocketClient, 0, 4),
+	}
+}
+
+func (b *Bundle) Mode() string {
+	return b.mode
+}
+
+func (b *Bundle) Add(client SocketClient) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for _, c := range b.members {
+		if c == client {
+			return
+		}
+	}
+	b.members = append(b.members, client)
+}
+
+// Remove returns number of left members.
+func (b *Bundle) Remove(client SocketClient) int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for i, c := range b.members {
+		if c == client {
+			b.members = append(b.members[:i], b.members[i+1:]...)
+			break
+		}
+	}
+	return len(b.members)
+}
+
+func (b *Bundle) Members() []SocketClient {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	return append([]SocketClient{}, b.members...)
+}
+
+func (b *Bundle) Len() int {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	return len(b.members)
+}
+
+func (b *Bundle) ready() []SocketClient {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	ready := make([]SocketClient, 0, len(b.members))
+	for _, c := range b.members {
+		if c.IsOk() && c.Have(ClAuth) {
+			ready = append(ready, c)
+		}
+	}
+	return ready
+}
+
+func (b *Bundle) index(frame *FrameMessage, size int) int {
+	if b.mode == BondRoundRobin || frame.IsControl() {
+		return int(atomic.AddUint32(&b.next, 1) % uint32(size))
+	}
+	return int(FlowHash(frame.Frame()[:frame.Size()]) % uint32(size))
+}
+
+// Pick returns a ready member for the frame, and nil if no one.
+func (b *Bundle) Pick(frame *FrameMessage) SocketClient {
+	ready := b.ready()
+	if len(ready) == 0 {
+		return nil
+	}
+	return ready[b.index(frame, len(ready))]
+}
+
+// WriteMsg writes frame by picked member, and fails over to others.
+func (b *Bundle) WriteMsg(frame *FrameMessage) error {
+	ready := b.ready()
+	size := len(ready)
+	if size == 0 {
+		return NewErr("no member ready")
+	}
+	var origin *FrameMessage
+	if size > 1 {
+		// frame is encrypted in place by member, so retry with a copy.
+		origin = frame.Clone()
+		defer origin.Release()
+	}
+	var err error
+	start := b.index(frame, size)
+	for i := 0; i < size; i++ {
+		c := ready[(start+i)%size]
+		msg := frame
+		if i > 0 {
+			msg = origin.Clone()
+		}
+		err = c.WriteMsg(msg)
+		if i > 0 {
+			msg.Release()
+		}
+		if err == nil {
+			return nil
+		}
+		c.Out().Warn("Bundle.WriteMsg: %s", err)
+	}
+	return err
+}
+
+// FlowHash returns hash of addresses and ports in ethernet frame.
+func FlowHash(frame []byte) uint32 {
+	h := fnv.New32a()
+	if len(frame) < 14 {
+		_, _ = h.Write(frame)
+		return h.Sum32()
+	}
+	eth := binary.BigEndian.Uint16(frame[12:14])
+	data := frame[14:]
+	if eth == EthVlan && len(data) >= 4 {
+		eth = binary.BigEndian.Uint16(data[2:4])
+		data = data[4:]
+	}
+	switch {
+	case eth == EthIp4 && len(data) >= 20:
+		hl := int(data[0]&0x0f) * 4
+		_, _ = h.Write(data[9:10])  // protocol
+		_, _ = h.Write(data[12:20]) // source and destination
+		proto := data[9]
+		if (proto == IpTcp || proto == IpUdp) && len(data) >= hl+4 {
+			_, _ = h.Write(data[hl : hl+4]) // ports
+		}
+	case eth == EthIp6 && len(data) >= 40:
+		_, _ = h.Write(data[6:7])  // next header
+		_, _ = h.Write(data[8:40]) // source and destination
+		proto := data[6]
+		if (proto == IpTcp || proto == IpUdp) && len(data) >= 44 {
+			_, _ = h.Write(data[40:44])
+		}
+	default:
+		_, _ = h.Write(frame[:12]) // destination and source.
+	}
+	return h.Sum32()
+}
@@ -0,0 +1,113 @@
+package libol
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+)
+
+func newBundleClient(t *testing.T) (*TcpClient, net.Conn) {
+	a, b := net.Pipe()
+	c := NewTcpClientFromConn(a, &TcpConfig{})
+	c.SetStatus(ClAuth)
+	return c, b
+}
+
+func TestBundle(t *testing.T) {
+	c0, p0 := newBundleClient(t)
+	c1, p1 := newBundleClient(t)
+	defer p0.Close()
+	defer p1.Close()
+
+	b := NewBundle(BondHash)
+	b.Add(c0)
+	b.Add(c1)
+	b.Add(c0)
+	assert.Equal(t, 2, b.Len(), "be the same.")
+
+	frame := newTestFrame([]byte("flow"))
+	first := b.Pick(frame)
+	for i := 0; i < 8; i++ {
+		assert.Equal(t, first, b.Pick(frame), "be the same flow.")
+	}
+
+	rr := NewBundle(BondRoundRobin)
+	rr.Add(c0)
+	rr.Add(c1)
+	assert.NotEqual(t, rr.Pick(frame), rr.Pick(frame), "be round robin.")
+
+	c1.SetStatus(ClUnAuth)
+	assert.Equal(t, c0, b.Pick(frame), "be failed over.")
+	assert.Equal(t, 1, b.Remove(c0), "be the same.")
+	assert.Nil(t, b.Pick(frame), "be nil.")
+	assert.NotNil(t, b.WriteMsg(frame), "be error.")
+}
+
+type bundleMember struct {
+	SocketClient
+	failed bool
+	frames [][]byte
+}
+
+func (c *bundleMember) IsOk() bool {
+	return true
+}
+
+func (c *bundleMember) Have(status SocketStatus) bool {
+	return status == ClAuth
+}
+
+func (c *bundleMember) Out() *SubLogger {
+	return NewSubLogger("member")
+}
+
+func (c *bundleMember) WriteMsg(frame *FrameMessage) error {
+	data := frame.Frame()[:frame.Size()]
+	for i := range data {
+		data[i] ^= 0xff // encrypted in place.
+	}
+	if c.failed {
+		return NewErr("write failed")
+	}
+	c.frames = append(c.frames, append([]byte{}, data...))
+	return nil
+}
+
+func TestBundle_Failover(t *testing.T) {
+	c0 := &bundleMember{failed: true}
+	c1 := &bundleMember{failed: true}
+	b := NewBundle(BondRoundRobin)
+	b.Add(c0)
+	b.Add(c1)
+	frame := newTestFrame([]byte("flow"))
+	plain := append([]byte{}, frame.Frame()[:frame.Size()]...)
+	assert.NotNil(t, b.WriteMsg(frame), "be error.")
+
+	c0.failed = false
+	frame = newTestFrame([]byte("flow"))
+	for i := 0; i < 2; i++ {
+		assert.Nil(t, b.WriteMsg(frame), "be nil.")
+		frame = newTestFrame([]byte("flow"))
+	}
+	assert.Equal(t, 2, len(c0.frames), "be the same.")
+	for _, data := range c0.frames {
+		for i := range data {
+			data[i] ^= 0xff
+		}
+		assert.Equal(t, plain, data, "be the same.")
+	}
+}
+
+func TestFlowHash(t *testing.T) {
+	pkt := make([]byte, 14+20+8)
+	pkt[12], pkt[13] = 0x08, 0x00
+	pkt[14] = 0x45
+	pkt[14+9] = IpUdp
+	copy(pkt[26:34], []byte{10, 0, 0, 1, 10, 0, 0, 2})
+	h0 := FlowHash(pkt)
+	pkt[34] = 0x01 // source port
+	assert.NotEqual(t, h0, FlowHash(pkt), "be different.")
+	pkt[0] = 0x01 // mac not in hash of ip.
+	pkt[34] = 0x00
+	assert.Equal(t, h0, FlowHash(pkt), "be the same.")
+}
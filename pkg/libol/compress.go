@@ -0,0 +1,187 @@
+package libol
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"io"
+	"sync"
+)
+
+const (
+	HlZip        = 0x8000 // flag in size of header if compressed.
+	ZipDeflate   = "deflate"
+	ZipThreshold = 256 // bytes
+)
+
+const (
+	CsZipIn    = "zip-in"    // bytes before compressed.
+	CsZipOut   = "zip-out"   // bytes after compressed.
+	CsZipSkip  = "zip-skip"  // frames not compressed.
+	CsZipRatio = "zip-ratio" // per-mille of out to in.
+	CsUnzipIn  = "unzip-in"
+	CsUnzipOut = "unzip-out"
+)
+
+// sliceWriter writes into fixed buffer, and fails if it's full.
+type sliceWriter struct {
+	buf []byte
+	n   int
+}
+
+func (w *sliceWriter) Write(p []byte) (int, error) {
+	if len(w.buf)-w.n < len(p) {
+		return 0, io.ErrShortBuffer
+	}
+	w.n += copy(w.buf[w.n:], p)
+	return len(p), nil
+}
+
+type Compressor struct {
+	Algo      string
+	Level     int
+	Threshold int // frames smaller than it are not compressed.
+	writers   sync.Pool
+	readers   sync.Pool
+}
+
+func NewCompressor(algo string, level, threshold int) *Compressor {
+	if algo == "" {
+		algo = ZipDeflate
+	}
+	if level == 0 || level < flate.HuffmanOnly || level > flate.BestCompression {
+		level = flate.BestSpeed
+	}
+	if threshold == 0 {
+		threshold = ZipThreshold
+	}
+	c := &Compressor{
+		Algo:      algo,
+		Level:     level,
+		Threshold: threshold,
+	}
+	c.writers.New = func() interface{} {
+		w, _ := flate.NewWriter(nil, c.Level)
+		return w
+	}
+	c.readers.New = func() interface{} {
+		return flate.NewReader(bytes.NewReader(nil))
+	}
+	return c
+}
+
+// Feature returns name to negotiate in login.
+func (c *Compressor) Feature() string {
+	return FeatCompress + ":" + c.Algo
+}
+
+// Compress returns a new frame if compressed, otherwise nil.
+func (c *Compressor) Compress(frame *FrameMessage) *FrameMessage {
+	data := frame.frame[:frame.size]
+	if frame.control || len(data) < c.Threshold || isEncrypted(data) {
+		return nil
+	}
+	zip := AllocFrame(len(data))
+	// not worth to send if it isn't smaller.
+	out := &sliceWriter{buf: zip.frame[:len(data)-1]}
+	w := c.writers.Get().(*flate.Writer)
+	w.Reset(out)
+	_, err := w.Write(data)
+	if err == nil {
+		err = w.Close()
+	}
+	c.writers.Put(w)
+	if err != nil {
+		zip.Release()
+		return nil
+	}
+	zip.size = out.n
+	zip.zip = true
+	return zip
+}
+
+// Decompress returns a new frame with original data.
+func (c *Compressor) Decompress(frame *FrameMessage) (*FrameMessage, error) {
+	r := c.readers.Get().(io.ReadCloser)
+	defer c.readers.Put(r)
+	if err := r.(flate.Resetter).Reset(bytes.NewReader(frame.frame[:frame.size]), nil); err != nil {
+		return nil, err
+	}
+	data := AllocFrame(MaxMsg)
+	n, err := io.ReadFull(r, data.frame)
+	if err == io.ErrUnexpectedEOF || err == io.EOF {
+		err = nil
+	} else if err == nil {
+		err = NewErr("too large frame")
+	}
+	if err != nil {
+		data.Release()
+		return nil, err
+	}
+	data.size = n
+	return data, nil
+}
+
+var defaultCompressor = NewCompressor(ZipDeflate, 0, 0)
+
+// isEncrypted guesses whether payload of ethernet frame is already encrypted
+// or compressed, such as TLS records, QUIC, IPSec and WireGuard.
+func isEncrypted(frame []byte) bool {
+	if len(frame) < 14 {
+		return false
+	}
+	eth := binary.BigEndian.Uint16(frame[12:14])
+	data := frame[14:]
+	if eth == EthVlan && len(data) >= 4 {
+		eth = binary.BigEndian.Uint16(data[2:4])
+		data = data[4:]
+	}
+	proto := uint8(0)
+	switch eth {
+	case EthIp4:
+		if len(data) < 20 {
+			return false
+		}
+		hl := int(data[0]&0x0f) * 4
+		if hl < 20 || len(data) < hl {
+			return false
+		}
+		proto = data[9]
+		data = data[hl:]
+	case EthIp6:
+		if len(data) < 40 {
+			return false
+		}
+		proto = data[6]
+		data = data[40:]
+	default:
+		return false
+	}
+	switch proto {
+	case IpTcp:
+		if len(data) < 20 {
+			return false
+		}
+		hl := int(data[12]>>4) * 4
+		if hl < 20 || len(data) < hl+3 {
+			return false
+		}
+		payload := data[hl:]
+		// tls record: content type, version.
+		return payload[0] >= 0x14 && payload[0] <= 0x17 && payload[1] == 0x03
+	case IpUdp:
+		if len(data) < 8 {
+			return false
+		}
+		src := binary.BigEndian.Uint16(data[0:2])
+		dst := binary.BigEndian.Uint16(data[2:4])
+		for _, port := range []uint16{443, 500, 4500, 51820} {
+			if src == port || dst == port {
+				return true
+			}
+		}
+	case IpEsp:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+package libol
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+)
+
+func newTestFrame(payload []byte) *FrameMessage {
+	frame := AllocFrame(14 + len(payload))
+	eth := make([]byte, 14)
+	eth[12] = 0x08 // not ip.
+	eth[13] = 0x88
+	frame.Append(eth)
+	frame.Append(payload)
+	return frame
+}
+
+func TestCompressor(t *testing.T) {
+	c := NewCompressor(ZipDeflate, 0, 0)
+	small := newTestFrame([]byte("hi"))
+	assert.Nil(t, c.Compress(small), "be nil.")
+
+	text := bytes.Repeat([]byte("GET /index.html HTTP/1.1\r\n"), 40)
+	frame := newTestFrame(text)
+	zip := c.Compress(frame)
+	assert.NotNil(t, zip, "be compressed.")
+	assert.True(t, zip.IsZip(), "be zip.")
+	assert.True(t, zip.Size() < frame.Size(), "be smaller.")
+
+	data, err := c.Decompress(zip)
+	assert.Nil(t, err, "be nil.")
+	assert.Equal(t, frame.Frame()[:frame.Size()], data.Frame()[:data.Size()], "be the same.")
+	zip.Release()
+	data.Release()
+	frame.Release()
+}
+
+func TestCompressorSkipTls(t *testing.T) {
+	pkt := make([]byte, 14+20+20+600)
+	pkt[12], pkt[13] = 0x08, 0x00
+	pkt[14] = 0x45
+	pkt[14+9] = IpTcp
+	pkt[34+12] = 0x50
+	pkt[54], pkt[55] = 0x17, 0x03 // application data.
+	assert.True(t, isEncrypted(pkt), "be encrypted.")
+	pkt[54] = 'G'
+	assert.False(t, isEncrypted(pkt), "be plain.")
+}
+
+func TestStreamSocketZip(t *testing.T) {
+	a, b := net.Pipe()
+	c := NewCompressor(ZipDeflate, 0, 0)
+	snd := NewSocketClient("snd", &StreamMessagerImpl{})
+	snd.SetConnection(a)
+	snd.SetCompressor(c)
+	rcv := NewSocketClient("rcv", &StreamMessagerImpl{})
+	rcv.SetConnection(b)
+
+	text := bytes.Repeat([]byte("openlan "), 128)
+	frame := newTestFrame(text)
+	want := append([]byte{}, frame.Frame()[:frame.Size()]...)
+	go func() {
+		_ = snd.WriteMsg(frame)
+	}()
+	data, err := rcv.ReadMsg()
+	assert.Nil(t, err, "be nil.")
+	assert.Equal(t, want, data.Frame()[:data.Size()], "be the same.")
+	sts := snd.Statistics()
+	assert.True(t, sts[CsZipRatio] > 0 && sts[CsZipRatio] < 1000, "be compressed.")
+	assert.Equal(t, int64(len(want)), rcv.Statistics()[CsUnzipOut], "be the same.")
+}
@@ -0,0 +1,239 @@
+package libol
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const ProxyTimeout = 30 * time.Second
+
+// GetProxyEnv returns proxy from HTTPS_PROXY for the addr, and
+// returns empty if matched in NO_PROXY.
+func GetProxyEnv(addr string) string {
+	req := &http.Request{URL: &url.URL{Scheme: "https", Host: addr}}
+	proxy, err := http.ProxyFromEnvironment(req)
+	if err != nil || proxy == nil {
+		return ""
+	}
+	return proxy.String()
+}
+
+// Dial connects to addr directly if proxy is empty, otherwise
+// tunnels it through http(s):// or socks5:// upstream proxy.
+func Dial(proxy, addr string) (net.Conn, error) {
+	if proxy == "" {
+		return net.Dial("tcp", addr)
+	}
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return nil, err
+	}
+	var conn net.Conn
+	switch u.Scheme {
+	case "http":
+		conn, err = net.DialTimeout("tcp", u.Host, ProxyTimeout)
+	case "https":
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: ProxyTimeout}, "tcp", u.Host, nil)
+	case "socks5":
+		conn, err = net.DialTimeout("tcp", u.Host, ProxyTimeout)
+	default:
+		return nil, NewErr("proxy scheme %s not supported", u.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+	_ = conn.SetDeadline(time.Now().Add(ProxyTimeout))
+	tunnel := conn
+	if u.Scheme == "socks5" {
+		err = socksConnect(conn, u, addr)
+	} else {
+		tunnel, err = httpConnect(conn, u, addr)
+	}
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return tunnel, nil
+}
+
+// DialTls likes Dial, and does tls handshake after connected.
+func DialTls(proxy, addr string, cfg *tls.Config) (net.Conn, error) {
+	if proxy == "" {
+		return tls.Dial("tcp", addr, cfg)
+	}
+	conn, err := Dial(proxy, addr)
+	if err != nil {
+		return nil, err
+	}
+	if cfg == nil {
+		cfg = &tls.Config{}
+	}
+	if cfg.ServerName == "" {
+		cfg = cfg.Clone()
+		cfg.ServerName, _ = GetHostPort(addr)
+	}
+	tlsConn := tls.Client(conn, cfg)
+	if err := tlsConn.Handshake(); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+// RedactProxy hides password of proxy for logging.
+func RedactProxy(proxy string) string {
+	if u, err := url.Parse(proxy); err == nil {
+		return u.Redacted()
+	}
+	return proxy
+}
+
+type bufConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
+
+// RFC-7231 Tunneling TCP based protocols through Web Proxy servers
+func httpConnect(conn net.Conn, u *url.URL, addr string) (net.Conn, error) {
+	req := &http.Request{
+		Method: "CONNECT",
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if u.User != nil {
+		password, _ := u.User.Password()
+		auth := u.User.Username() + ":" + password
+		basic := "Basic " + base64.StdEncoding.EncodeToString([]byte(auth))
+		req.Header.Set("Proxy-Authorization", basic)
+	}
+	if err := req.Write(conn); err != nil {
+		return nil, err
+	}
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		return nil, err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, NewErr("proxy %s: %s", u.Host, resp.Status)
+	}
+	if reader.Buffered() > 0 {
+		return &bufConn{Conn: conn, reader: reader}, nil
+	}
+	return conn, nil
+}
+
+// RFC-1928 SOCKS Protocol Version 5, and RFC-1929 for username.
+func socksConnect(conn net.Conn, u *url.URL, addr string) error {
+	methods := []byte{0x05, 0x01, 0x00}
+	if u.User != nil {
+		methods = []byte{0x05, 0x02, 0x00, 0x02}
+	}
+	if _, err := conn.Write(methods); err != nil {
+		return err
+	}
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if reply[0] != 0x05 {
+		return NewErr("socks %s: wrong version %d", u.Host, reply[0])
+	}
+	switch reply[1] {
+	case 0x00:
+	case 0x02:
+		if u.User == nil {
+			return NewErr("socks %s: required authentication", u.Host)
+		}
+		username := u.User.Username()
+		password, _ := u.User.Password()
+		if len(username) > 255 || len(password) > 255 {
+			return NewErr("socks %s: too long authentication", u.Host)
+		}
+		auth := []byte{0x01, byte(len(username))}
+		auth = append(auth, username...)
+		auth = append(auth, byte(len(password)))
+		auth = append(auth, password...)
+		if _, err := conn.Write(auth); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(conn, reply); err != nil {
+			return err
+		}
+		if reply[1] != 0x00 {
+			return NewErr("socks %s: authentication failed", u.Host)
+		}
+	default:
+		return NewErr("socks %s: no acceptable methods", u.Host)
+	}
+
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 0 || port > 0xffff {
+		return NewErr("socks %s: invalid port %s", u.Host, portStr)
+	}
+	req := []byte{0x05, 0x01, 0x00}
+	if ip := net.ParseIP(host); ip == nil {
+		if len(host) > 255 {
+			return NewErr("socks %s: too long host", u.Host)
+		}
+		req = append(req, 0x03, byte(len(host)))
+		req = append(req, host...)
+	} else if ip4 := ip.To4(); ip4 != nil {
+		req = append(req, 0x01)
+		req = append(req, ip4...)
+	} else {
+		req = append(req, 0x04)
+		req = append(req, ip.To16()...)
+	}
+	req = append(req, 0x00, 0x00)
+	binary.BigEndian.PutUint16(req[len(req)-2:], uint16(port))
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+	// VER, REP, RSV, ATYP
+	resp := make([]byte, 4)
+	if _, err := io.ReadFull(conn, resp); err != nil {
+		return err
+	}
+	if resp[1] != 0x00 {
+		return NewErr("socks %s: connect failed with %d", u.Host, resp[1])
+	}
+	size := 0
+	switch resp[3] {
+	case 0x01:
+		size = net.IPv4len
+	case 0x04:
+		size = net.IPv6len
+	case 0x03:
+		if _, err := io.ReadFull(conn, resp[:1]); err != nil {
+			return err
+		}
+		size = int(resp[0])
+	default:
+		return NewErr("socks %s: unknown address type %d", u.Host, resp[3])
+	}
+	// BND.ADDR and BND.PORT
+	if _, err := io.ReadFull(conn, make([]byte, size+2)); err != nil {
+		return err
+	}
+	return nil
+}
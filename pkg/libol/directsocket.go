@@ -0,0 +1,122 @@
+package libol
+
+import (
+	"github.com/xtaci/kcp-go/v5"
+	"net"
+	"sync"
+)
+
+// DirectSocket is an unconnected udp socket, which used to punch
+// hole and exchange frames with peers directly.
+type DirectSocket struct {
+	message    *PacketMessagerImpl
+	connLock   sync.RWMutex // connection is reset by closer and read by others.
+	connection *net.UDPConn
+	statistics *SafeStrInt64
+	address    string
+	maxSize    int
+	minSize    int
+}
+
+func NewDirectSocket(listen string, block kcp.BlockCrypt) *DirectSocket {
+	return &DirectSocket{
+		message: &PacketMessagerImpl{
+			block:   block,
+			bufSize: MaxFrame,
+		},
+		statistics: NewSafeStrInt64(),
+		address:    listen,
+		maxSize:    MaxFrame,
+		minSize:    15,
+	}
+}
+
+func (d *DirectSocket) Listen() error {
+	addr, err := net.ResolveUDPAddr("udp", d.address)
+	if err != nil {
+		return err
+	}
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		return err
+	}
+	d.setConn(conn)
+	Info("DirectSocket.Listen: udp://%s", conn.LocalAddr())
+	return nil
+}
+
+func (d *DirectSocket) conn() *net.UDPConn {
+	d.connLock.RLock()
+	defer d.connLock.RUnlock()
+	return d.connection
+}
+
+func (d *DirectSocket) setConn(conn *net.UDPConn) {
+	d.connLock.Lock()
+	defer d.connLock.Unlock()
+	d.connection = conn
+}
+
+func (d *DirectSocket) Close() {
+	if conn := d.conn(); conn != nil {
+		_ = conn.Close()
+		Info("DirectSocket.Close: %s", d.address)
+		d.setConn(nil)
+	}
+}
+
+func (d *DirectSocket) IsOk() bool {
+	return d.conn() != nil
+}
+
+func (d *DirectSocket) LocalAddr() string {
+	conn := d.conn()
+	if conn == nil {
+		return ""
+	}
+	return conn.LocalAddr().String()
+}
+
+func (d *DirectSocket) String() string {
+	return d.address
+}
+
+func (d *DirectSocket) WriteMsgTo(frame *FrameMessage, addr *net.UDPAddr) error {
+	conn := d.conn()
+	if conn == nil {
+		d.statistics.Add(CsDropped, 1)
+		return NewErr("%s not okay", d)
+	}
+	data := d.message.encode(frame)
+	if _, err := conn.WriteToUDP(data, addr); err != nil {
+		d.statistics.Add(CsSendError, 1)
+		return err
+	}
+	d.statistics.Add(CsSendOkay, int64(len(data)))
+	return nil
+}
+
+func (d *DirectSocket) ReadMsgFrom() (*FrameMessage, *net.UDPAddr, error) {
+	conn := d.conn()
+	if conn == nil {
+		return nil, nil, NewErr("%s not okay", d)
+	}
+	frame := AllocFrame(d.message.bufSize)
+	n, addr, err := conn.ReadFromUDP(frame.buffer)
+	if err != nil {
+		frame.Release()
+		return nil, nil, err
+	}
+	if err := d.message.decode(frame, n, d.maxSize, d.minSize); err != nil {
+		frame.Release()
+		return nil, addr, NewErr("%s: %s", addr, err)
+	}
+	d.statistics.Add(CsRecvOkay, int64(n))
+	return frame, addr, nil
+}
+
+func (d *DirectSocket) Statistics() map[string]int64 {
+	sts := make(map[string]int64)
+	d.statistics.Copy(sts)
+	return sts
+}
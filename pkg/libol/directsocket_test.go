@@ -0,0 +1,56 @@
+package libol
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/xtaci/kcp-go/v5"
+	"net"
+	"testing"
+)
+
+func TestDirectSocket_WriteRead(t *testing.T) {
+	block, _ := kcp.NewSimpleXORBlockCrypt([]byte("hi, openlan"))
+	a := NewDirectSocket("127.0.0.1:0", block)
+	b := NewDirectSocket("127.0.0.1:0", block)
+	assert.Nil(t, a.Listen(), "listen a")
+	assert.Nil(t, b.Listen(), "listen b")
+	defer a.Close()
+	defer b.Close()
+
+	addr, _ := net.ResolveUDPAddr("udp", b.LocalAddr())
+	m := NewControlFrame(PunchReq, []byte(`{"uuid":"a"}`))
+	assert.Nil(t, a.WriteMsgTo(m, addr), "write to b")
+
+	frame, from, err := b.ReadMsgFrom()
+	assert.Nil(t, err, "read from a")
+	assert.Equal(t, a.LocalAddr(), from.String(), "be the same.")
+	assert.True(t, frame.Decode(), "is control")
+	action, body := frame.CmdAndParams()
+	assert.Equal(t, PunchReq, action, "be the same.")
+	assert.Equal(t, `{"uuid":"a"}`, string(body), "be the same.")
+
+	data := make([]byte, 64)
+	copy(data[:6], EthAll)
+	m = NewFrameMessage(0)
+	m.Append(data)
+	assert.Nil(t, a.WriteMsgTo(m, addr), "write to b")
+	frame, _, err = b.ReadMsgFrom()
+	assert.Nil(t, err, "read from a")
+	assert.False(t, frame.Decode(), "is ethernet")
+	assert.Equal(t, data, frame.Frame(), "be the same.")
+}
+
+func TestDirectSocket_Close(t *testing.T) {
+	a := NewDirectSocket("127.0.0.1:0", nil)
+	assert.Nil(t, a.Listen(), "listen a")
+	done := make(chan error)
+	go func() {
+		_, _, err := a.ReadMsgFrom()
+		done <- err
+	}()
+	a.Close()
+	assert.NotNil(t, <-done, "be closed.")
+	assert.False(t, a.IsOk(), "be closed.")
+	assert.Equal(t, "", a.LocalAddr(), "be the same.")
+	_, _, err := a.ReadMsgFrom()
+	assert.NotNil(t, err, "not okay.")
+}
@@ -0,0 +1,28 @@
+package libol
+
+import (
+	"golang.org/x/sys/unix"
+	"net"
+)
+
+// setDontFrag sets DF bit of datagrams, and ignores pmtu cached by
+// kernel, so padded pings are able to probe mtu of path. If disabled,
+// it's restored to default of kernel.
+func setDontFrag(conn *net.UDPConn, enable bool) error {
+	c, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	value := unix.IP_PMTUDISC_WANT
+	if enable {
+		value = unix.IP_PMTUDISC_PROBE
+	}
+	var opErr error
+	err = c.Control(func(fd uintptr) {
+		opErr = unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_MTU_DISCOVER, value)
+	})
+	if err != nil {
+		return err
+	}
+	return opErr
+}
@@ -0,0 +1,9 @@
+// +build !linux
+
+package libol
+
+import "net"
+
+func setDontFrag(conn *net.UDPConn, enable bool) error {
+	return NewErr("IP_MTU_DISCOVER notSupport")
+}
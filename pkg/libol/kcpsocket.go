@@ -8,9 +8,13 @@ import (
 
 type KcpConfig struct {
 	Block        kcp.BlockCrypt
-	WinSize      int           // default 1024
-	DataShards   int           // default 10
+	Mode         string        // normal, fast, fast2 and fast3
+	SndWnd       int           // default 1024
+	RcvWnd       int           // default 1024
+	Mtu          int           // default 1400
+	DataShards   int           // default 10, and 0 disables fec.
 	ParityShards int           // default 3
+	Dscp         int           // default 46, and 0 not to set.
 	Timeout      time.Duration // ns
 	RdQus        int           // per frames
 	WrQus        int           // per frames
@@ -18,14 +22,77 @@ type KcpConfig struct {
 
 var defaultKcpConfig = KcpConfig{
 	Block:        nil,
-	WinSize:      1024,
+	Mode:         "fast3",
+	SndWnd:       1024,
+	RcvWnd:       1024,
+	Mtu:          1400,
 	DataShards:   10,
 	ParityShards: 3,
+	Dscp:         46,
 	Timeout:      120 * time.Second,
 }
 
+// nodelay, interval, resend and nc of modes.
+var kcpModes = map[string][4]int{
+	"normal": {0, 40, 2, 1},
+	"fast":   {0, 30, 2, 1},
+	"fast2":  {1, 20, 2, 1},
+	"fast3":  {1, 10, 2, 1},
+}
+
+// NewKcpConfig returns a copy of default, and it's owned by caller.
 func NewKcpConfig() *KcpConfig {
-	return &defaultKcpConfig
+	obj := defaultKcpConfig
+	return &obj
+}
+
+func (c *KcpConfig) Correct() {
+	if _, ok := kcpModes[c.Mode]; !ok {
+		if c.Mode != "" {
+			Warn("KcpConfig.Correct: unknown mode %s", c.Mode)
+		}
+		c.Mode = defaultKcpConfig.Mode
+	}
+	if c.SndWnd == 0 {
+		c.SndWnd = defaultKcpConfig.SndWnd
+	}
+	if c.RcvWnd == 0 {
+		c.RcvWnd = defaultKcpConfig.RcvWnd
+	}
+	if c.Mtu == 0 {
+		c.Mtu = defaultKcpConfig.Mtu
+	}
+	if c.DataShards < 0 {
+		c.DataShards = 0
+	}
+	if c.ParityShards < 0 {
+		c.ParityShards = 0
+	}
+	if c.Timeout == 0 {
+		c.Timeout = defaultKcpConfig.Timeout
+	}
+}
+
+// KcpStatistics returns counters of kcp-go, which are process-wide and
+// summed by all servers and clients, so keys are prefixed by kcp-proc.
+// Counters of segments per session are not available in kcp-go, and
+// a session has only its rtt and rto.
+func KcpStatistics() map[string]int64 {
+	snmp := kcp.DefaultSnmp.Copy()
+	return map[string]int64{
+		"kcp-proc-inpkts":     int64(snmp.InPkts),
+		"kcp-proc-outpkts":    int64(snmp.OutPkts),
+		"kcp-proc-insegs":     int64(snmp.InSegs),
+		"kcp-proc-outsegs":    int64(snmp.OutSegs),
+		"kcp-proc-retrans":    int64(snmp.RetransSegs),
+		"kcp-proc-fastretx":   int64(snmp.FastRetransSegs),
+		"kcp-proc-lost":       int64(snmp.LostSegs),
+		"kcp-proc-repeat":     int64(snmp.RepeatSegs),
+		"kcp-proc-fecrecover": int64(snmp.FECRecovered),
+		"kcp-proc-fecerrs":    int64(snmp.FECErrs),
+		"kcp-proc-inerrs":     int64(snmp.InErrs + snmp.InCsumErrors + snmp.KCPInErrors),
+		"kcp-proc-estab":      int64(snmp.CurrEstab),
+	}
 }
 
 type KcpServer struct {
@@ -38,19 +105,21 @@ func setConn(conn *kcp.UDPSession, cfg *KcpConfig) {
 	Info("setConn %s", conn.RemoteAddr())
 	conn.SetStreamMode(true)
 	conn.SetWriteDelay(false)
-	Info("setConn %s to fast3", conn.RemoteAddr())
-	// normal: 0, 40, 2, 1
-	// fast  : 0, 30, 2, 1
-	// fast3 : 1, 10, 2, 1
-	conn.SetNoDelay(1, 10, 2, 1)
-	conn.SetWindowSize(cfg.WinSize, cfg.WinSize)
+	Info("setConn %s to %s", conn.RemoteAddr(), cfg.Mode)
+	mode := kcpModes[cfg.Mode]
+	conn.SetNoDelay(mode[0], mode[1], mode[2], mode[3])
+	conn.SetWindowSize(cfg.SndWnd, cfg.RcvWnd)
+	if !conn.SetMtu(cfg.Mtu) {
+		Warn("setConn %s invalid mtu %d", conn.RemoteAddr(), cfg.Mtu)
+	}
 	conn.SetACKNoDelay(true)
 }
 
 func NewKcpServer(listen string, cfg *KcpConfig) *KcpServer {
 	if cfg == nil {
-		cfg = &defaultKcpConfig
+		cfg = NewKcpConfig()
 	}
+	cfg.Correct()
 	k := &KcpServer{
 		kcpCfg:           cfg,
 		SocketServerImpl: NewSocketServer(listen),
@@ -69,8 +138,10 @@ func (k *KcpServer) Listen() (err error) {
 		k.listener = nil
 		return err
 	}
-	if err := k.listener.SetDSCP(46); err != nil {
-		Warn("KcpServer.SetDSCP %s", err)
+	if k.kcpCfg.Dscp > 0 {
+		if err := k.listener.SetDSCP(k.kcpCfg.Dscp); err != nil {
+			Warn("KcpServer.SetDSCP %s", err)
+		}
 	}
 	Info("KcpServer.Listen: kcp://%s", k.address)
 	return nil
@@ -84,6 +155,14 @@ func (k *KcpServer) Close() {
 	}
 }
 
+func (k *KcpServer) Statistics() map[string]int64 {
+	sts := k.SocketServerImpl.Statistics()
+	for key, value := range KcpStatistics() {
+		sts[key] = value
+	}
+	return sts
+}
+
 func (k *KcpServer) Accept() {
 	Debug("KcpServer.Accept")
 	promise := Promise{
@@ -108,7 +187,7 @@ func (k *KcpServer) Accept() {
 			continue
 		}
 		setConn(conn, k.kcpCfg)
-		k.onClients <- NewKcpClientFromConn(conn, k.kcpCfg)
+		k.onClient(NewKcpClientFromConn(conn, k.kcpCfg))
 	}
 }
 
@@ -121,8 +200,9 @@ type KcpClient struct {
 
 func NewKcpClient(addr string, cfg *KcpConfig) *KcpClient {
 	if cfg == nil {
-		cfg = &defaultKcpConfig
+		cfg = NewKcpConfig()
 	}
+	cfg.Correct()
 	c := &KcpClient{
 		kcpCfg: cfg,
 		SocketClientImpl: NewSocketClient(addr, &StreamMessagerImpl{
@@ -135,10 +215,11 @@ func NewKcpClient(addr string, cfg *KcpConfig) *KcpClient {
 
 func NewKcpClientFromConn(conn net.Conn, cfg *KcpConfig) *KcpClient {
 	if cfg == nil {
-		cfg = &defaultKcpConfig
+		cfg = NewKcpConfig()
 	}
 	addr := conn.RemoteAddr().String()
 	c := &KcpClient{
+		kcpCfg: cfg,
 		SocketClientImpl: NewSocketClient(addr, &StreamMessagerImpl{
 			timeout: cfg.Timeout,
 			bufSize: cfg.RdQus * MaxFrame,
@@ -157,12 +238,14 @@ func (c *KcpClient) Connect() error {
 		c.address,
 		c.kcpCfg.Block,
 		c.kcpCfg.DataShards,
-		c.kcpCfg.DataShards)
+		c.kcpCfg.ParityShards)
 	if err != nil {
 		return err
 	}
-	if err := conn.SetDSCP(46); err != nil {
-		c.out.Warn("KcpClient.SetDSCP: ", err)
+	if c.kcpCfg.Dscp > 0 {
+		if err := conn.SetDSCP(c.kcpCfg.Dscp); err != nil {
+			c.out.Warn("KcpClient.SetDSCP: %s", err)
+		}
 	}
 	setConn(conn, c.kcpCfg)
 	c.SetConnection(conn)
@@ -172,6 +255,21 @@ func (c *KcpClient) Connect() error {
 	return nil
 }
 
+// Statistics returns counters of socket, and rtt and rto in ms of the
+// session, but not its segments as kcp-go only counts in process.
+func (c *KcpClient) Statistics() map[string]int64 {
+	sts := c.SocketClientImpl.Statistics()
+	c.lock.RLock()
+	conn, ok := c.connection.(*kcp.UDPSession)
+	c.lock.RUnlock()
+	if ok {
+		sts["kcp-srtt"] = int64(conn.GetSRTT())
+		sts["kcp-srttvar"] = int64(conn.GetSRTTVar())
+		sts["kcp-rto"] = int64(conn.GetRTO())
+	}
+	return sts
+}
+
 func (c *KcpClient) Close() {
 	c.out.Debug("KcpClient.Close: %v", c.IsOk())
 	c.lock.Lock()
@@ -0,0 +1,41 @@
+package libol
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/xtaci/kcp-go/v5"
+	"testing"
+)
+
+func TestKcpConfig(t *testing.T) {
+	a := NewKcpConfig()
+	b := NewKcpConfig()
+	a.SndWnd = 64
+	assert.NotEqual(t, a.SndWnd, b.SndWnd, "be independent.")
+	assert.Equal(t, 1024, b.SndWnd, "be the same.")
+
+	c := &KcpConfig{Mode: "turbo"}
+	c.Correct()
+	assert.Equal(t, "fast3", c.Mode, "be the same.")
+	assert.Equal(t, 1400, c.Mtu, "be the same.")
+	assert.Equal(t, 0, c.DataShards, "be the same.")
+
+	d := &KcpConfig{Mode: "normal", ParityShards: 0, DataShards: 4}
+	d.Correct()
+	assert.Equal(t, "normal", d.Mode, "be the same.")
+	assert.Equal(t, 4, d.DataShards, "be the same.")
+	assert.Equal(t, 0, d.ParityShards, "be the same.")
+}
+
+func TestKcpClient_Statistics(t *testing.T) {
+	conn, err := kcp.DialWithOptions("127.0.0.1:9", nil, 0, 0)
+	assert.Nil(t, err, "be nil.")
+	c := NewKcpClientFromConn(conn, nil)
+	defer c.Close()
+	sts := c.Statistics()
+	for _, key := range []string{"kcp-srtt", "kcp-srttvar", "kcp-rto"} {
+		_, ok := sts[key]
+		assert.Equal(t, true, ok, key)
+	}
+	_, ok := sts["kcp-proc-insegs"]
+	assert.Equal(t, false, ok, "be process-wide.")
+}
@@ -0,0 +1,136 @@
+package libol
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// version of control protocol, and zero is legacy.
+const ProtoVersion = 2
+
+// features could be negotiated in login.
+const (
+	FeatCompress = "compress"
+	FeatCrypt    = "crypt" // as crypt:<algo>
+	FeatRoute    = "route"
+	FeatRoaming  = "roaming"
+	FeatBond     = "bond"
+)
+
+// LegacyFeatures is enabled for peers without negotiation.
+var LegacyFeatures = []string{FeatRoute}
+
+// codes of login reply.
+const (
+	LoginOkay     = 0
+	LoginInvalid  = 400 // invalid request.
+	LoginWrong    = 401 // wrong user or password.
+	LoginExpired  = 403 // account out of date.
+	LoginNotFound = 404 // network notFound.
+	LoginCrypt    = 406 // crypt not matched.
+	LoginFailed   = 500 // internal error.
+	LoginFull     = 503 // network already full.
+)
+
+type LoginError struct {
+	Code    int
+	Message string
+}
+
+func NewLoginErr(code int, message string, v ...interface{}) *LoginError {
+	return &LoginError{
+		Code:    code,
+		Message: fmt.Sprintf(message, v...),
+	}
+}
+
+func (e *LoginError) Error() string {
+	return e.Message
+}
+
+type LoginReply struct {
+	Code     int      `json:"code"`
+	Message  string   `json:"message"`
+	Version  int      `json:"version"`
+	Features []string `json:"features,omitempty"`
+}
+
+// NewLoginReply returns reply for the error, and features are ignored if failed.
+func NewLoginReply(err error, features []string) *LoginReply {
+	r := &LoginReply{
+		Code:    LoginOkay,
+		Message: "okay",
+		Version: ProtoVersion,
+	}
+	if err == nil {
+		r.Features = features
+		return r
+	}
+	r.Message = err.Error()
+	if e, ok := err.(*LoginError); ok {
+		r.Code = e.Code
+	} else {
+		r.Code = LoginFailed
+	}
+	return r
+}
+
+// DecodeLoginReply supports json and legacy text from lower version.
+func DecodeLoginReply(data []byte) (*LoginReply, error) {
+	r := &LoginReply{}
+	if len(data) > 0 && data[0] == '{' {
+		if err := json.Unmarshal(data, r); err != nil {
+			return nil, err
+		}
+		return r, nil
+	}
+	r.Message = string(data)
+	if strings.HasPrefix(r.Message, "okay") {
+		r.Features = LegacyFeatures
+	} else {
+		r.Code = LoginFailed
+	}
+	return r, nil
+}
+
+func (r *LoginReply) Okay() bool {
+	return r.Code == LoginOkay
+}
+
+func (r *LoginReply) Error() error {
+	if r.Okay() {
+		return nil
+	}
+	return NewLoginErr(r.Code, "%s", r.Message)
+}
+
+// Negotiate returns features supported by both sides in local's order.
+func Negotiate(local, remote []string) []string {
+	both := make([]string, 0, len(local))
+	for _, name := range local {
+		if HasFeature(remote, name) {
+			both = append(both, name)
+		}
+	}
+	return both
+}
+
+// CryptOf returns crypt feature, and empty if not crypted.
+func CryptOf(features []string) string {
+	for _, v := range features {
+		if strings.HasPrefix(v, FeatCrypt+":") {
+			return v
+		}
+	}
+	return ""
+}
+
+func HasFeature(features []string, name string) bool {
+	for _, v := range features {
+		if v == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+package libol
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestLoginReply(t *testing.T) {
+	r, err := DecodeLoginReply([]byte("okay"))
+	assert.Nil(t, err, "be nil.")
+	assert.True(t, r.Okay(), "be okay.")
+	assert.Equal(t, LegacyFeatures, r.Features, "be the same.")
+
+	r, err = DecodeLoginReply([]byte("Auth failed."))
+	assert.Nil(t, err, "be nil.")
+	assert.Equal(t, LoginFailed, r.Code, "be the same.")
+
+	data, _ := json.Marshal(NewLoginReply(NewLoginErr(LoginExpired, "out of date"), nil))
+	r, err = DecodeLoginReply(data)
+	assert.Nil(t, err, "be nil.")
+	assert.Equal(t, LoginExpired, r.Code, "be the same.")
+	assert.Equal(t, "out of date", r.Error().Error(), "be the same.")
+
+	data, _ = json.Marshal(NewLoginReply(nil, []string{FeatRoute}))
+	r, err = DecodeLoginReply(data)
+	assert.Nil(t, err, "be nil.")
+	assert.True(t, r.Okay(), "be okay.")
+	assert.Equal(t, ProtoVersion, r.Version, "be the same.")
+	assert.Equal(t, []string{FeatRoute}, r.Features, "be the same.")
+}
+
+func TestNegotiate(t *testing.T) {
+	local := []string{FeatRoute, "crypt:aes-256", FeatRoaming}
+	remote := []string{FeatRoaming, "crypt:aes-128", FeatRoute}
+	both := Negotiate(local, remote)
+	assert.Equal(t, []string{FeatRoute, FeatRoaming}, both, "be the same.")
+	assert.Equal(t, 0, len(Negotiate(local, nil)), "be the same.")
+	assert.Equal(t, "crypt:aes-256", CryptOf(local), "be the same.")
+	assert.Equal(t, "", CryptOf([]string{FeatRoute}), "be the same.")
+}
@@ -0,0 +1,289 @@
+package libol
+
+import (
+	"fmt"
+	"github.com/xtaci/kcp-go/v5"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type MemConfig struct {
+	Block   kcp.BlockCrypt
+	Timeout time.Duration // ns
+	RdQus   int           // per frames
+	WrQus   int           // per frames
+}
+
+type memAddr string
+
+func (a memAddr) Network() string {
+	return "mem"
+}
+
+func (a memAddr) String() string {
+	return string(a)
+}
+
+// memConn is one side of pipe with unique address.
+type memConn struct {
+	net.Conn
+	local  memAddr
+	remote memAddr
+}
+
+func (c *memConn) LocalAddr() net.Addr {
+	return c.local
+}
+
+func (c *memConn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+// MemListener accepts connections dialed in same process.
+type MemListener struct {
+	address string
+	conns   chan net.Conn
+	done    chan struct{}
+	once    sync.Once
+}
+
+var memListeners = struct {
+	lock  sync.RWMutex
+	data  map[string]*MemListener
+	ports uint32
+}{
+	data: make(map[string]*MemListener, 32),
+}
+
+func MemListen(address string) (*MemListener, error) {
+	memListeners.lock.Lock()
+	defer memListeners.lock.Unlock()
+	if _, ok := memListeners.data[address]; ok {
+		return nil, NewErr("%s already in use", address)
+	}
+	l := &MemListener{
+		address: address,
+		conns:   make(chan net.Conn, 32),
+		done:    make(chan struct{}),
+	}
+	memListeners.data[address] = l
+	return l, nil
+}
+
+func (l *MemListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, NewErr("%s closed", l.address)
+	}
+}
+
+func (l *MemListener) Close() error {
+	l.once.Do(func() {
+		memListeners.lock.Lock()
+		delete(memListeners.data, l.address)
+		memListeners.lock.Unlock()
+		close(l.done)
+	})
+	return nil
+}
+
+func (l *MemListener) Addr() net.Addr {
+	return memAddr(l.address)
+}
+
+// findMem finds listener by address, and by port if listen on any.
+func findMem(address string) *MemListener {
+	memListeners.lock.RLock()
+	defer memListeners.lock.RUnlock()
+	if l, ok := memListeners.data[address]; ok {
+		return l
+	}
+	if i := strings.LastIndex(address, ":"); i >= 0 {
+		port := address[i:]
+		for addr, l := range memListeners.data {
+			if strings.HasSuffix(addr, port) {
+				return l
+			}
+		}
+	}
+	return nil
+}
+
+func MemDial(address string) (net.Conn, error) {
+	l := findMem(address)
+	if l == nil {
+		return nil, NewErr("dial %s: connection refused", address)
+	}
+	port := atomic.AddUint32(&memListeners.ports, 1)
+	local := memAddr(fmt.Sprintf("mem:%d", port))
+	remote := memAddr(l.address)
+	a, b := net.Pipe()
+	client := &memConn{Conn: a, local: local, remote: remote}
+	server := &memConn{Conn: b, local: remote, remote: local}
+	select {
+	case l.conns <- server:
+		return client, nil
+	case <-l.done:
+		_ = a.Close()
+		_ = b.Close()
+		return nil, NewErr("dial %s: connection refused", address)
+	}
+}
+
+// Server Implement
+
+type MemServer struct {
+	*SocketServerImpl
+	memCfg   *MemConfig
+	listener *MemListener
+}
+
+func NewMemServer(listen string, cfg *MemConfig) *MemServer {
+	t := &MemServer{
+		memCfg:           cfg,
+		SocketServerImpl: NewSocketServer(listen),
+	}
+	t.WrQus = cfg.WrQus
+	t.close = t.Close
+	return t
+}
+
+func (t *MemServer) Listen() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.listener != nil {
+		return nil
+	}
+	listener, err := MemListen(t.address)
+	if err != nil {
+		return err
+	}
+	t.listener = listener
+	Info("MemServer.Listen: mem://%s", t.address)
+	return nil
+}
+
+func (t *MemServer) Close() {
+	t.lock.Lock()
+	listener := t.listener
+	t.listener = nil
+	t.lock.Unlock()
+	if listener != nil {
+		_ = listener.Close()
+		Info("MemServer.Close: %s", t.address)
+	}
+}
+
+func (t *MemServer) Accept() {
+	Debug("MemServer.Accept")
+	if err := t.Listen(); err != nil {
+		Warn("MemServer.Accept: %s", err)
+		return
+	}
+	t.lock.RLock()
+	listener := t.listener
+	t.lock.RUnlock()
+	if listener == nil {
+		return
+	}
+	defer t.Close()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		if t.preAccept(conn, err) != nil {
+			continue
+		}
+		t.onClient(NewMemClientFromConn(conn, t.memCfg))
+	}
+}
+
+// Client Implement
+
+type MemClient struct {
+	*SocketClientImpl
+	memCfg *MemConfig
+}
+
+func NewMemClient(addr string, cfg *MemConfig) *MemClient {
+	t := &MemClient{
+		memCfg: cfg,
+		SocketClientImpl: NewSocketClient(addr, &StreamMessagerImpl{
+			block:   cfg.Block,
+			timeout: cfg.Timeout,
+			bufSize: cfg.RdQus * MaxFrame,
+		}),
+	}
+	return t
+}
+
+func NewMemClientFromConn(conn net.Conn, cfg *MemConfig) *MemClient {
+	addr := conn.RemoteAddr().String()
+	t := &MemClient{
+		memCfg: cfg,
+		SocketClientImpl: NewSocketClient(addr, &StreamMessagerImpl{
+			block:   cfg.Block,
+			timeout: cfg.Timeout,
+			bufSize: cfg.RdQus * MaxFrame,
+		}),
+	}
+	t.updateConn(conn)
+	return t
+}
+
+func (t *MemClient) Connect() error {
+	if !t.Retry() {
+		return nil
+	}
+	t.out.Info("MemClient.Connect: mem://%s", t.address)
+	conn, err := MemDial(t.address)
+	if err != nil {
+		return err
+	}
+	t.SetConnection(conn)
+	if t.listener.OnConnected != nil {
+		_ = t.listener.OnConnected(t)
+	}
+	return nil
+}
+
+func (t *MemClient) Close() {
+	t.out.Debug("MemClient.Close: %v", t.IsOk())
+	t.lock.Lock()
+	if t.connection != nil {
+		if t.status != ClTerminal {
+			t.status = ClClosed
+		}
+		t.updateConn(nil)
+		t.private = nil
+		t.lock.Unlock()
+		if t.listener.OnClose != nil {
+			_ = t.listener.OnClose(t)
+		}
+		t.out.Debug("MemClient.Close: %d", t.status)
+	} else {
+		t.lock.Unlock()
+	}
+}
+
+func (t *MemClient) Terminal() {
+	t.SetStatus(ClTerminal)
+	t.Close()
+}
+
+func (t *MemClient) SetStatus(v SocketStatus) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.status != v {
+		if t.listener.OnStatus != nil {
+			t.listener.OnStatus(t, t.status, v)
+		}
+		t.status = v
+	}
+}
@@ -0,0 +1,55 @@
+package libol
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestMemSocket(t *testing.T) {
+	_, err := MemDial("switch:10002")
+	assert.NotNil(t, err, "be refused.")
+
+	cfg := &MemConfig{RdQus: 8, WrQus: 8}
+	server := NewMemServer("0.0.0.0:10002", cfg)
+	assert.Nil(t, server.Listen(), "be nil.")
+	go server.Accept()
+	frames := make(chan string, 2)
+	go server.Loop(ServerListener{
+		OnClient: func(client SocketClient) error {
+			return nil
+		},
+		ReadAt: func(client SocketClient, f *FrameMessage) error {
+			f.Decode()
+			action, params := f.CmdAndParams()
+			frames <- action + string(params)
+			m := NewControlFrame(LoginResp, []byte("okay"))
+			return client.WriteMsg(m)
+		},
+	})
+
+	client := NewMemClient("switch:10002", cfg)
+	assert.Nil(t, client.Connect(), "be nil.")
+	assert.Equal(t, "0.0.0.0:10002", client.RemoteAddr(), "be the same.")
+	m := NewControlFrame(LoginReq, []byte("{}"))
+	assert.Nil(t, client.WriteMsg(m), "be nil.")
+	select {
+	case data := <-frames:
+		assert.Equal(t, LoginReq+"{}", data, "be the same.")
+	case <-time.After(3 * time.Second):
+		t.Fatal("timeout")
+	}
+	reply, err := client.ReadMsg()
+	assert.Nil(t, err, "be nil.")
+	reply.Decode()
+	action, params := reply.CmdAndParams()
+	assert.Equal(t, LoginResp, action, "be the same.")
+	assert.Equal(t, "okay", string(params), "be the same.")
+
+	other := NewMemClient("switch:10002", cfg)
+	assert.Nil(t, other.Connect(), "be nil.")
+	assert.NotEqual(t, client.LocalAddr(), other.LocalAddr(), "be unique.")
+	client.Terminal()
+	other.Terminal()
+	server.Close()
+}
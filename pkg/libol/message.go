@@ -22,16 +22,20 @@ const (
 var MAGIC = []byte{0xff, 0xff}
 
 const (
-	LoginReq     = "logi= "
-	LoginResp    = "logi: "
-	NeighborReq  = "neig= "
-	NeighborResp = "neig: "
-	IpAddrReq    = "ipad= "
-	IpAddrResp   = "ipad: "
-	LeftReq      = "left= "
-	SignReq      = "sign= "
-	PingReq      = "ping= "
-	PongResp     = "pong: "
+	LoginReq      = "logi= "
+	LoginResp     = "logi: "
+	NeighborReq   = "neig= "
+	NeighborResp  = "neig: "
+	IpAddrReq     = "ipad= "
+	IpAddrResp    = "ipad: "
+	LeftReq       = "left= "
+	SignReq       = "sign= "
+	PingReq       = "ping= "
+	PongResp      = "pong: "
+	CandidateReq  = "cand= "
+	CandidateResp = "cand: "
+	PunchReq      = "punc= "
+	PunchResp     = "punc: "
 )
 
 func isControl(data []byte) bool {
@@ -102,6 +106,9 @@ type FrameMessage struct {
 	total   int
 	frame   []byte
 	proto   *FrameProto
+	zip     bool       // compressed if true.
+	ref     int32      // references if from pool.
+	pool    *FramePool // nil if not from pool.
 }
 
 func NewFrameMessage(maxSize int) *FrameMessage {
@@ -187,6 +194,34 @@ func (m *FrameMessage) SetSize(v int) {
 	m.size = v
 }
 
+// Clone returns a copy of frame, and it should be released by caller.
+func (m *FrameMessage) Clone() *FrameMessage {
+	obj := AllocFrame(m.size)
+	copy(obj.buffer, m.buffer[:HlSize+m.size])
+	obj.seq = m.seq
+	obj.control = m.control
+	obj.action = m.action
+	obj.zip = m.zip
+	obj.size = m.size
+	if m.control && len(obj.frame) >= 2*EthDI {
+		obj.params = obj.frame[2*EthDI:]
+	}
+	return obj
+}
+
+// encodeSize returns size with flags in header.
+func (m *FrameMessage) encodeSize() uint16 {
+	size := uint16(m.size)
+	if m.zip {
+		size |= HlZip
+	}
+	return size
+}
+
+func (m *FrameMessage) IsZip() bool {
+	return m.zip
+}
+
 func (m *FrameMessage) Proto() (*FrameProto, error) {
 	if m.proto == nil {
 		m.proto = &FrameProto{Frame: m.frame}
@@ -241,8 +276,10 @@ type Messager interface {
 type StreamMessagerImpl struct {
 	timeout time.Duration // ns for read and write deadline.
 	block   kcp.BlockCrypt
-	buffer  []byte
-	bufSize int // default is (1518 + 20+20+14) * 8
+	conn    net.Conn // pending bytes are read from.
+	buffer  []byte   // pending bytes in rbuf.
+	rbuf    []byte // reused for reading from stream.
+	bufSize int    // default is (1518 + 20+20+14) * 8
 }
 
 func (s *StreamMessagerImpl) Flush() {
@@ -295,7 +332,7 @@ func (s *StreamMessagerImpl) writeX(conn net.Conn, buf []byte) error {
 func (s *StreamMessagerImpl) encode(frame *FrameMessage) {
 	frame.buffer[0] = MAGIC[0]
 	frame.buffer[1] = MAGIC[1]
-	binary.BigEndian.PutUint16(frame.buffer[HlMI:HlLI], uint16(frame.size))
+	binary.BigEndian.PutUint16(frame.buffer[HlMI:HlLI], frame.encodeSize())
 	if s.block != nil {
 		s.block.Encrypt(frame.frame, frame.frame)
 	}
@@ -335,12 +372,10 @@ func (s *StreamMessagerImpl) readX(conn net.Conn, buf []byte) error {
 		Log("StreamMessagerImpl.readX: %s %d", conn.RemoteAddr(), len(buf))
 	}
 	for left > 0 {
-		tmp := make([]byte, left)
-		n, err := s.read(conn, tmp)
+		n, err := s.read(conn, buf[offset:])
 		if err != nil {
 			return err
 		}
-		copy(buf[offset:], tmp)
 		offset += n
 		left -= n
 	}
@@ -359,6 +394,8 @@ func (s *StreamMessagerImpl) decode(tmp []byte, min int) (*FrameMessage, error)
 		return nil, NewErr("wrong magic")
 	}
 	ps := binary.BigEndian.Uint16(tmp[HlMI:HlLI])
+	zip := ps&HlZip != 0
+	ps &^= HlZip
 	fs := int(ps) + HlSize
 	if ts >= fs {
 		s.buffer = tmp[fs:]
@@ -368,13 +405,23 @@ func (s *StreamMessagerImpl) decode(tmp []byte, min int) (*FrameMessage, error)
 		if HasLog(DEBUG) {
 			Debug("StreamMessagerImpl.decode: %d %x", fs, tmp[:fs])
 		}
-		return NewFrameMessageFromBytes(tmp[:fs]), nil
+		// copy out as rbuf is reused by next reading.
+		frame := AllocFrame(int(ps))
+		copy(frame.buffer, tmp[:fs])
+		frame.frame = frame.buffer[HlSize:fs]
+		frame.size = int(ps)
+		frame.zip = zip
+		return frame, nil
 	}
 	return nil, nil
 }
 
 // 430Mib
 func (s *StreamMessagerImpl) Receive(conn net.Conn, max, min int) (*FrameMessage, error) {
+	if s.conn != conn { // pending bytes of older are dropped.
+		s.conn = conn
+		s.buffer = nil
+	}
 	frame, err := s.decode(s.buffer, min)
 	if err != nil {
 		return nil, err
@@ -385,11 +432,12 @@ func (s *StreamMessagerImpl) Receive(conn net.Conn, max, min int) (*FrameMessage
 	if s.bufSize == 0 {
 		s.bufSize = MaxMsg // 1572 * 8
 	}
-	bs := len(s.buffer)
-	tmp := make([]byte, s.bufSize)
-	if bs > 0 {
-		copy(tmp[:bs], s.buffer[:bs])
+	if len(s.rbuf) != s.bufSize {
+		s.rbuf = make([]byte, s.bufSize)
 	}
+	tmp := s.rbuf
+	// move pending bytes to head.
+	bs := copy(tmp, s.buffer)
 	for { // loop forever until socket error or find one message.
 		rn, err := s.read(conn, tmp[bs:])
 		if err != nil {
@@ -418,13 +466,18 @@ func (s *PacketMessagerImpl) Flush() {
 	//TODO
 }
 
-func (s *PacketMessagerImpl) Send(conn net.Conn, frame *FrameMessage) (int, error) {
+func (s *PacketMessagerImpl) encode(frame *FrameMessage) []byte {
 	frame.buffer[0] = MAGIC[0]
 	frame.buffer[1] = MAGIC[1]
-	binary.BigEndian.PutUint16(frame.buffer[HlMI:HlLI], uint16(frame.size))
+	binary.BigEndian.PutUint16(frame.buffer[HlMI:HlLI], frame.encodeSize())
 	if s.block != nil {
 		s.block.Encrypt(frame.frame, frame.frame)
 	}
+	return frame.buffer[:HlSize+frame.size]
+}
+
+func (s *PacketMessagerImpl) Send(conn net.Conn, frame *FrameMessage) (int, error) {
+	data := s.encode(frame)
 	if HasLog(DEBUG) {
 		Debug("PacketMessagerImpl.Send: %s %d %x", conn.RemoteAddr(), frame.size, frame.buffer)
 	}
@@ -434,48 +487,62 @@ func (s *PacketMessagerImpl) Send(conn net.Conn, frame *FrameMessage) (int, erro
 			return 0, err
 		}
 	}
-	if _, err := conn.Write(frame.buffer[:HlSize+frame.size]); err != nil {
+	if _, err := conn.Write(data); err != nil {
 		return 0, err
 	}
 	return frame.size, nil
 }
 
+// decode the n bytes already read into frame.buffer.
+func (s *PacketMessagerImpl) decode(frame *FrameMessage, n, max, min int) error {
+	if n <= 4 {
+		return NewErr("small frame")
+	}
+	if !bytes.Equal(frame.buffer[:HlMI], MAGIC[:HlMI]) {
+		return NewErr("wrong magic")
+	}
+	ps := binary.BigEndian.Uint16(frame.buffer[HlMI:HlLI])
+	zip := ps&HlZip != 0
+	size := int(ps &^ HlZip)
+	if size > max || (!zip && size < min) || HlSize+size > n {
+		return NewErr("wrong size %d", size)
+	}
+	tmp := frame.buffer[HlSize : HlSize+size]
+	if s.block != nil {
+		s.block.Decrypt(tmp, tmp)
+	}
+	frame.size = size
+	frame.frame = tmp
+	frame.zip = zip
+	return nil
+}
+
 func (s *PacketMessagerImpl) Receive(conn net.Conn, max, min int) (*FrameMessage, error) {
 	if s.bufSize == 0 {
 		s.bufSize = MaxMsg
 	}
-	frame := NewFrameMessage(s.bufSize)
+	frame := AllocFrame(s.bufSize)
 	if HasLog(DEBUG) {
 		Debug("PacketMessagerImpl.Receive %s %d", conn.RemoteAddr(), s.timeout)
 	}
 	if s.timeout != 0 {
 		err := conn.SetReadDeadline(time.Now().Add(s.timeout))
 		if err != nil {
+			frame.Release()
 			return nil, err
 		}
 	}
 	n, err := conn.Read(frame.buffer)
 	if err != nil {
+		frame.Release()
 		return nil, err
 	}
 	if HasLog(DEBUG) {
 		Debug("PacketMessagerImpl.Receive: %s %x", conn.RemoteAddr(), frame.buffer[:n])
 	}
-	if n <= 4 {
-		return nil, NewErr("%s: small frame", conn.RemoteAddr())
-	}
-	if !bytes.Equal(frame.buffer[:HlMI], MAGIC[:HlMI]) {
-		return nil, NewErr("%s: wrong magic", conn.RemoteAddr())
-	}
-	size := int(binary.BigEndian.Uint16(frame.buffer[HlMI:HlLI]))
-	if size > max || size < min {
-		return nil, NewErr("%s: wrong size %d", conn.RemoteAddr(), size)
-	}
-	tmp := frame.buffer[HlSize : HlSize+size]
-	if s.block != nil {
-		s.block.Decrypt(tmp, tmp)
+	if err := s.decode(frame, n, max, min); err != nil {
+		frame.Release()
+		return nil, NewErr("%s: %s", conn.RemoteAddr(), err)
 	}
-	frame.size = size
-	frame.frame = tmp
 	return frame, nil
 }
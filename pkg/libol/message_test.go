@@ -0,0 +1,108 @@
+package libol
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+)
+
+// loopConn reads encoded frames repeatedly.
+type loopConn struct {
+	net.Conn
+	data   []byte
+	offset int
+}
+
+func (c *loopConn) Read(b []byte) (int, error) {
+	n := copy(b, c.data[c.offset:])
+	c.offset = (c.offset + n) % len(c.data)
+	return n, nil
+}
+
+func newLoopConn(size, count int) *loopConn {
+	c := &loopConn{}
+	s := &StreamMessagerImpl{}
+	for i := 0; i < count; i++ {
+		frame := NewFrameMessage(size)
+		frame.Append(make([]byte, size))
+		s.encode(frame)
+		c.data = append(c.data, frame.buffer[:HlSize+size]...)
+	}
+	return c
+}
+
+func TestFramePool(t *testing.T) {
+	m := AllocFrame(0)
+	assert.NotNil(t, m.pool, "be not nil.")
+	m.Append([]byte{1, 2, 3})
+	m.Retain()
+	m.Release()
+	assert.Equal(t, int32(1), m.ref, "be the same.")
+	assert.Equal(t, 3, m.Size(), "be the same.")
+	m.Release()
+	assert.Equal(t, int32(0), m.ref, "be the same.")
+
+	n := NewFrameMessage(0)
+	n.Release()
+	assert.Nil(t, n.pool, "be nil.")
+
+	l := AllocFrame(MaxMsg)
+	assert.Equal(t, largeFrames, l.pool, "be the same.")
+	l.Release()
+}
+
+func TestStreamReceive(t *testing.T) {
+	c := newLoopConn(1500, 3)
+	s := &StreamMessagerImpl{}
+	for i := 0; i < 10; i++ {
+		frame, err := s.Receive(c, 1600, 14)
+		assert.Nil(t, err, "be nil.")
+		assert.Equal(t, 1500, frame.Size(), "be the same.")
+		assert.Equal(t, 1500, len(frame.Frame()), "be the same.")
+		frame.Release()
+	}
+}
+
+func BenchmarkNewFrameMessage(b *testing.B) {
+	b.ReportAllocs()
+	b.SetBytes(1500)
+	for i := 0; i < b.N; i++ {
+		m := NewFrameMessage(0)
+		m.SetSize(1500)
+	}
+}
+
+func BenchmarkAllocFrame(b *testing.B) {
+	b.ReportAllocs()
+	b.SetBytes(1500)
+	for i := 0; i < b.N; i++ {
+		m := AllocFrame(0)
+		m.SetSize(1500)
+		m.Release()
+	}
+}
+
+func benchmarkStreamReceive(b *testing.B, release bool) {
+	c := newLoopConn(1500, 8)
+	s := &StreamMessagerImpl{}
+	b.ReportAllocs()
+	b.SetBytes(1500)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		frame, err := s.Receive(c, 1600, 14)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if release {
+			frame.Release()
+		}
+	}
+}
+
+func BenchmarkStreamReceive(b *testing.B) {
+	benchmarkStreamReceive(b, true)
+}
+
+func BenchmarkStreamReceiveNoRelease(b *testing.B) {
+	benchmarkStreamReceive(b, false)
+}
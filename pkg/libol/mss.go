@@ -0,0 +1,74 @@
+package libol
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+const TcpOptMss = 0x02
+
+// ClampMss lowers the mss option of a tcp syn in the ipv4 packet to mss,
+// and returns true if it's changed.
+func ClampMss(packet []byte, mss int) bool {
+	if len(packet) < Ipv4Len || packet[0]>>4 != Ipv4Ver || packet[9] != IpTcp {
+		return false
+	}
+	if binary.BigEndian.Uint16(packet[6:8])&0x1fff != 0 { // not first fragment.
+		return false
+	}
+	ihl := int(packet[0]&0x0f) * 4
+	if ihl < Ipv4Len || len(packet) < ihl+TcpLen {
+		return false
+	}
+	tcp := packet[ihl:]
+	if tcp[13]&TcpSyn == 0 {
+		return false
+	}
+	offset := int(tcp[12]>>4) * 4
+	if offset < TcpLen || offset > len(tcp) {
+		return false
+	}
+	for i := TcpLen; i < offset; {
+		kind := tcp[i]
+		if kind == 0x00 { // end of options.
+			break
+		}
+		if kind == 0x01 { // no-operation.
+			i++
+			continue
+		}
+		if i+1 >= offset {
+			break
+		}
+		size := int(tcp[i+1])
+		if size < 2 || i+size > offset {
+			break
+		}
+		if kind != TcpOptMss || size != 4 {
+			i += size
+			continue
+		}
+		older := binary.BigEndian.Uint16(tcp[i+2 : i+4])
+		if int(older) <= mss {
+			return false
+		}
+		newer := uint16(mss)
+		binary.BigEndian.PutUint16(tcp[i+2:i+4], newer)
+		if i%2 == 1 { // not aligned to word of checksum.
+			older, newer = bits.ReverseBytes16(older), bits.ReverseBytes16(newer)
+		}
+		sum := binary.BigEndian.Uint16(tcp[16:18])
+		binary.BigEndian.PutUint16(tcp[16:18], updateChecksum(sum, older, newer))
+		return true
+	}
+	return false
+}
+
+// updateChecksum adjusts checksum incrementally when a word is
+// changed, see RFC 1624.
+func updateChecksum(sum, older, newer uint16) uint16 {
+	s := uint32(^sum) + uint32(^older) + uint32(newer)
+	s = (s & 0xffff) + (s >> 16)
+	s = (s & 0xffff) + (s >> 16)
+	return ^uint16(s)
+}
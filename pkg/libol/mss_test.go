@@ -0,0 +1,66 @@
+package libol
+
+import (
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func tcpChecksum(packet []byte) uint16 {
+	ihl := int(packet[0]&0x0f) * 4
+	tcp := packet[ihl:]
+	sum := uint32(0)
+	add := func(data []byte) {
+		for i := 0; i+1 < len(data); i += 2 {
+			sum += uint32(binary.BigEndian.Uint16(data[i:]))
+		}
+		if len(data)%2 == 1 {
+			sum += uint32(data[len(data)-1]) << 8
+		}
+	}
+	add(packet[12:20])
+	sum += uint32(IpTcp) + uint32(len(tcp))
+	add(tcp[:16])
+	add(tcp[18:])
+	for sum > 0xffff {
+		sum = (sum & 0xffff) + (sum >> 16)
+	}
+	return ^uint16(sum)
+}
+
+func newTestSyn(opts []byte) []byte {
+	packet := make([]byte, Ipv4Len+TcpLen+len(opts))
+	packet[0] = 0x45
+	binary.BigEndian.PutUint16(packet[2:4], uint16(len(packet)))
+	packet[9] = IpTcp
+	copy(packet[12:16], []byte{192, 168, 1, 10})
+	copy(packet[16:20], []byte{192, 168, 1, 20})
+	tcp := packet[Ipv4Len:]
+	binary.BigEndian.PutUint16(tcp[0:2], 40001)
+	binary.BigEndian.PutUint16(tcp[2:4], 80)
+	tcp[12] = uint8((TcpLen+len(opts))/4) << 4
+	tcp[13] = TcpSyn
+	copy(tcp[TcpLen:], opts)
+	binary.BigEndian.PutUint16(tcp[16:18], tcpChecksum(packet))
+	return packet
+}
+
+func TestClampMss(t *testing.T) {
+	packet := newTestSyn([]byte{0x02, 0x04, 0x05, 0xb4, 0x01, 0x01, 0x04, 0x02})
+	assert.True(t, ClampMss(packet, 1360), "be changed.")
+	mss := binary.BigEndian.Uint16(packet[Ipv4Len+TcpLen+2:])
+	assert.Equal(t, uint16(1360), mss, "be the same.")
+	sum := binary.BigEndian.Uint16(packet[Ipv4Len+16:])
+	assert.Equal(t, tcpChecksum(packet), sum, "be the same.")
+	assert.False(t, ClampMss(packet, 1400), "be smaller.")
+
+	// mss isn't aligned by a nop.
+	packet = newTestSyn([]byte{0x01, 0x02, 0x04, 0x05, 0xb4, 0x00, 0x00, 0x00})
+	assert.True(t, ClampMss(packet, 1200), "be changed.")
+	sum = binary.BigEndian.Uint16(packet[Ipv4Len+16:])
+	assert.Equal(t, tcpChecksum(packet), sum, "be the same.")
+
+	packet = newTestSyn([]byte{0x02, 0x04, 0x05, 0xb4})
+	packet[Ipv4Len+13] = TcpAck
+	assert.False(t, ClampMss(packet, 1200), "not syn.")
+}
@@ -0,0 +1,94 @@
+package libol
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// FramePool caches frame messages with same size of buffer, and a frame
+// got from it should be released once it's written to socket or device.
+type FramePool struct {
+	size int
+	pool sync.Pool
+}
+
+func NewFramePool(size int) *FramePool {
+	p := &FramePool{size: size}
+	p.pool.New = func() interface{} {
+		m := NewFrameMessage(size)
+		m.pool = p
+		return m
+	}
+	return p
+}
+
+func (p *FramePool) Get() *FrameMessage {
+	m := p.pool.Get().(*FrameMessage)
+	m.ref = 1
+	return m
+}
+
+func (p *FramePool) Put(m *FrameMessage) {
+	m.reset()
+	p.pool.Put(m)
+}
+
+func (p *FramePool) Size() int {
+	return p.size
+}
+
+var (
+	// frames read from device.
+	smallFrames = NewFramePool(MaxBuf)
+	// frames read from datagram or stream socket.
+	largeFrames = NewFramePool(MaxMsg)
+)
+
+// AllocFrame gets a frame message from pool which buffer is able to
+// hold size bytes, and allocates a new one if size is too large.
+func AllocFrame(size int) *FrameMessage {
+	if size <= 0 {
+		size = MaxBuf
+	}
+	if size <= smallFrames.size {
+		return smallFrames.Get()
+	}
+	if size <= largeFrames.size {
+		return largeFrames.Get()
+	}
+	return NewFrameMessage(size)
+}
+
+// Retain increases reference of frame, and it must be paired with Release.
+func (m *FrameMessage) Retain() *FrameMessage {
+	if m.pool != nil {
+		atomic.AddInt32(&m.ref, 1)
+	}
+	return m
+}
+
+// Release decreases reference of frame, and puts it back to pool if no
+// one references it. It's safe to release a frame not from pool.
+func (m *FrameMessage) Release() {
+	if m == nil || m.pool == nil {
+		return
+	}
+	ref := atomic.AddInt32(&m.ref, -1)
+	if ref == 0 {
+		m.pool.Put(m)
+	} else if ref < 0 {
+		Warn("FrameMessage.Release: %p released %d", m, ref)
+	}
+}
+
+func (m *FrameMessage) reset() {
+	m.seq = 0
+	m.control = false
+	m.action = ""
+	m.params = nil
+	m.size = 0
+	m.frame = m.buffer[HlSize:]
+	m.total = len(m.frame)
+	m.proto = nil
+	m.zip = false
+}
@@ -0,0 +1,76 @@
+package libol
+
+import (
+	"context"
+	"net"
+	"runtime"
+)
+
+// Listeners returns number of listeners, and one per core if negative.
+func Listeners(n int) int {
+	if n < 0 {
+		n = runtime.NumCPU()
+	}
+	if n == 0 || !canReusePort {
+		n = 1
+	}
+	return n
+}
+
+// ListenTcp opens n listeners on same address by SO_REUSEPORT.
+func ListenTcp(address string, n int) ([]net.Listener, error) {
+	n = Listeners(n)
+	if n == 1 {
+		l, err := net.Listen("tcp", address)
+		if err != nil {
+			return nil, err
+		}
+		return []net.Listener{l}, nil
+	}
+	lc := net.ListenConfig{Control: reusePort}
+	listeners := make([]net.Listener, 0, n)
+	for i := 0; i < n; i++ {
+		l, err := lc.Listen(context.Background(), "tcp", address)
+		if err != nil {
+			for _, obj := range listeners {
+				_ = obj.Close()
+			}
+			return nil, err
+		}
+		// port may be allocated by kernel.
+		address = l.Addr().String()
+		listeners = append(listeners, l)
+	}
+	return listeners, nil
+}
+
+// ListenUdp opens n sockets on same address by SO_REUSEPORT, and kernel
+// spreads datagrams by hash of 4-tuple.
+func ListenUdp(address string, n int) ([]*net.UDPConn, error) {
+	n = Listeners(n)
+	if n == 1 {
+		addr, err := net.ResolveUDPAddr("udp", address)
+		if err != nil {
+			return nil, err
+		}
+		conn, err := net.ListenUDP("udp", addr)
+		if err != nil {
+			return nil, err
+		}
+		return []*net.UDPConn{conn}, nil
+	}
+	lc := net.ListenConfig{Control: reusePort}
+	conns := make([]*net.UDPConn, 0, n)
+	for i := 0; i < n; i++ {
+		pc, err := lc.ListenPacket(context.Background(), "udp", address)
+		if err != nil {
+			for _, obj := range conns {
+				_ = obj.Close()
+			}
+			return nil, err
+		}
+		address = pc.LocalAddr().String()
+		conns = append(conns, pc.(*net.UDPConn))
+	}
+	return conns, nil
+}
@@ -0,0 +1,19 @@
+package libol
+
+import (
+	"golang.org/x/sys/unix"
+	"syscall"
+)
+
+const canReusePort = true
+
+func reusePort(network, address string, c syscall.RawConn) error {
+	var opErr error
+	err := c.Control(func(fd uintptr) {
+		opErr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1)
+	})
+	if err != nil {
+		return err
+	}
+	return opErr
+}
@@ -0,0 +1,11 @@
+// +build !linux
+
+package libol
+
+import "syscall"
+
+const canReusePort = false
+
+func reusePort(network, address string, c syscall.RawConn) error {
+	return NewErr("SO_REUSEPORT notSupport")
+}
@@ -0,0 +1,51 @@
+package libol
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestListenReusePort(t *testing.T) {
+	listeners, err := ListenTcp("127.0.0.1:0", 2)
+	assert.Nil(t, err, "be nil.")
+	assert.Equal(t, Listeners(2), len(listeners), "be the same.")
+	for _, l := range listeners {
+		assert.Equal(t, listeners[0].Addr().String(), l.Addr().String(), "be the same.")
+		_ = l.Close()
+	}
+	conns, err := ListenUdp("127.0.0.1:0", 2)
+	assert.Nil(t, err, "be nil.")
+	assert.Equal(t, Listeners(2), len(conns), "be the same.")
+	for _, c := range conns {
+		_ = c.Close()
+	}
+}
+
+func TestTcpServerShards(t *testing.T) {
+	server := NewTcpServer("127.0.0.1:0", &TcpConfig{Listeners: 2, RdQus: 8, WrQus: 8})
+	assert.Nil(t, server.Listen(), "be nil.")
+	address := server.listeners[0].Addr().String()
+	go server.Accept()
+	online := make(chan string, 8)
+	go server.Loop(ServerListener{
+		OnClient: func(client SocketClient) error {
+			online <- client.String()
+			return nil
+		},
+	})
+	for i := 0; i < 4; i++ {
+		conn, err := net.Dial("tcp", address)
+		assert.Nil(t, err, "be nil.")
+		defer conn.Close()
+	}
+	for i := 0; i < 4; i++ {
+		select {
+		case <-online:
+		case <-time.After(3 * time.Second):
+			t.Fatal("timeout")
+		}
+	}
+	assert.Equal(t, 4, server.TotalClient(), "be the same.")
+}
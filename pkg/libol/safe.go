@@ -115,6 +115,12 @@ func (sm *SafeStrMap) Len() int {
 	return len(sm.data)
 }
 
+func (sm *SafeStrMap) Full() bool {
+	sm.lock.RLock()
+	defer sm.lock.RUnlock()
+	return sm.size != 0 && len(sm.data) >= sm.size
+}
+
 func (sm *SafeStrMap) add(k string, v interface{}) error {
 	if sm.size != 0 && len(sm.data) >= sm.size {
 		return NewErr("SafeStrMap.Set already full")
@@ -1,8 +1,10 @@
 package libol
 
 import (
+	"hash/fnv"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -74,11 +76,13 @@ type SocketClient interface {
 	Statistics() map[string]int64
 	SetListener(listener ClientListener)
 	SetTimeout(v int64)
+	SetCompressor(c *Compressor)
 	Out() *SubLogger
 }
 
 type StreamSocket struct {
 	message    Messager
+	connLock   sync.RWMutex // connection is reset by closer and read by others.
 	connection net.Conn
 	statistics *SafeStrInt64
 	maxSize    int
@@ -87,13 +91,25 @@ type StreamSocket struct {
 	remoteAddr string
 	localAddr  string
 	address    string
+	compressor atomic.Value // *Compressor to compress frames if not nil.
+}
+
+func (t *StreamSocket) Compressor() *Compressor {
+	if c, ok := t.compressor.Load().(*Compressor); ok {
+		return c
+	}
+	return nil
 }
 
 func (t *StreamSocket) LocalAddr() string {
+	t.connLock.RLock()
+	defer t.connLock.RUnlock()
 	return t.localAddr
 }
 
 func (t *StreamSocket) RemoteAddr() string {
+	t.connLock.RLock()
+	defer t.connLock.RUnlock()
 	return t.remoteAddr
 }
 
@@ -101,12 +117,32 @@ func (t *StreamSocket) String() string {
 	return t.address
 }
 
+func (t *StreamSocket) conn() net.Conn {
+	t.connLock.RLock()
+	defer t.connLock.RUnlock()
+	return t.connection
+}
+
+func (t *StreamSocket) setConn(conn net.Conn) {
+	t.connLock.Lock()
+	defer t.connLock.Unlock()
+	t.connection = conn
+	if conn != nil {
+		t.localAddr = conn.LocalAddr().String()
+		t.remoteAddr = conn.RemoteAddr().String()
+	} else {
+		t.localAddr = ""
+		t.remoteAddr = ""
+	}
+}
+
 func (t *StreamSocket) IsOk() bool {
-	return t.connection != nil
+	return t.conn() != nil
 }
 
 func (t *StreamSocket) WriteMsg(frame *FrameMessage) error {
-	if !t.IsOk() {
+	conn := t.conn()
+	if conn == nil {
 		t.statistics.Add(CsDropped, 1)
 		return NewErr("%s not okay", t)
 	}
@@ -117,7 +153,17 @@ func (t *StreamSocket) WriteMsg(frame *FrameMessage) error {
 	if t.message == nil { // default is stream message
 		t.message = &StreamMessagerImpl{}
 	}
-	size, err := t.message.Send(t.connection, frame)
+	if c := t.Compressor(); c != nil && !frame.IsControl() {
+		if zip := c.Compress(frame); zip != nil {
+			t.statistics.Add(CsZipIn, int64(frame.size))
+			t.statistics.Add(CsZipOut, int64(zip.size))
+			defer zip.Release()
+			frame = zip
+		} else {
+			t.statistics.Add(CsZipSkip, 1)
+		}
+	}
+	size, err := t.message.Send(conn, frame)
 	if err != nil {
 		t.statistics.Add(CsSendError, 1)
 		return err
@@ -130,18 +176,33 @@ func (t *StreamSocket) ReadMsg() (*FrameMessage, error) {
 	if HasLog(LOG) {
 		Log("StreamSocket.ReadMsg: %s", t)
 	}
-	if !t.IsOk() {
+	conn := t.conn()
+	if conn == nil {
 		return nil, NewErr("%s not okay", t)
 	}
 	if t.message == nil { // default is stream message
 		t.message = &StreamMessagerImpl{}
 	}
-	frame, err := t.message.Receive(t.connection, t.maxSize, t.minSize)
+	frame, err := t.message.Receive(conn, t.maxSize, t.minSize)
 	if err != nil {
 		return nil, err
 	}
 	size := len(frame.frame)
 	t.statistics.Add(CsRecvOkay, int64(size))
+	if frame.zip {
+		c := t.Compressor()
+		if c == nil {
+			c = defaultCompressor
+		}
+		data, err := c.Decompress(frame)
+		frame.Release()
+		if err != nil {
+			return nil, NewErr("%s decompress %s", t, err)
+		}
+		t.statistics.Add(CsUnzipIn, int64(size))
+		t.statistics.Add(CsUnzipOut, int64(data.size))
+		frame = data
+	}
 	return frame, nil
 }
 
@@ -239,6 +300,9 @@ func (s *SocketClientImpl) Have(state SocketStatus) bool {
 func (s *SocketClientImpl) Statistics() map[string]int64 {
 	sts := make(map[string]int64)
 	s.statistics.Copy(sts)
+	if in := sts[CsZipIn]; in > 0 {
+		sts[CsZipRatio] = sts[CsZipOut] * 1000 / in
+	}
 	return sts
 }
 
@@ -246,26 +310,26 @@ func (s *SocketClientImpl) SetListener(listener ClientListener) {
 	s.listener = listener
 }
 
+// SetCompressor enables compression after negotiated, and nil to disable.
+func (s *SocketClientImpl) SetCompressor(c *Compressor) {
+	s.compressor.Store(c)
+}
+
 func (s *SocketClientImpl) SetTimeout(v int64) {
 	s.timeout = v
 }
 
 func (s *SocketClientImpl) updateConn(conn net.Conn) {
 	if conn != nil {
-		s.connection = conn
+		s.setConn(conn)
 		s.connectedTime = time.Now().Unix()
-		s.localAddr = conn.LocalAddr().String()
-		s.remoteAddr = conn.RemoteAddr().String()
 	} else {
-		if s.connection != nil {
-			_ = s.connection.Close()
+		if old := s.conn(); old != nil {
+			_ = old.Close()
 		}
-		s.connection = nil
-		s.localAddr = ""
-		s.remoteAddr = ""
-		s.message.Flush()
+		s.setConn(nil)
 	}
-	s.out.Event("SocketClientImpl.updateConn: %s %s", s.localAddr, s.remoteAddr)
+	s.out.Event("SocketClientImpl.updateConn: %s %s", s.LocalAddr(), s.RemoteAddr())
 }
 
 func (s *SocketClientImpl) SetConnection(conn net.Conn) {
@@ -317,8 +381,7 @@ type SocketServerImpl struct {
 	address    string
 	maxClient  int
 	clients    *SafeStrMap
-	onClients  chan SocketClient
-	offClients chan SocketClient
+	shards     []*serverShard // clients dispatched by hash of address.
 	close      func()
 	timeout    int64 // sec for read and write timeout
 	WrQus      int   // per frames.
@@ -331,12 +394,50 @@ func NewSocketServer(listen string) *SocketServerImpl {
 		statistics: NewSafeStrInt64(),
 		maxClient:  128,
 		clients:    NewSafeStrMap(1024),
+		shards:     []*serverShard{newServerShard()},
+		WrQus:      1024,
+	}
+}
+
+type serverShard struct {
+	onClients  chan SocketClient
+	offClients chan SocketClient
+}
+
+func newServerShard() *serverShard {
+	return &serverShard{
 		onClients:  make(chan SocketClient, 1024),
 		offClients: make(chan SocketClient, 1024),
-		WrQus:      1024,
 	}
 }
 
+// SetShards sets number of loops to dispatch clients, and it should be
+// called before Loop.
+func (t *SocketServerImpl) SetShards(n int) {
+	if n <= 0 {
+		n = 1
+	}
+	shards := make([]*serverShard, n)
+	for i := range shards {
+		shards[i] = newServerShard()
+	}
+	t.shards = shards
+}
+
+// shard returns owner of client, and on and off of it are in same loop.
+func (t *SocketServerImpl) shard(client SocketClient) *serverShard {
+	if len(t.shards) == 1 {
+		return t.shards[0]
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(client.String()))
+	return t.shards[h.Sum32()%uint32(len(t.shards))]
+}
+
+func (t *SocketServerImpl) onClient(client SocketClient) {
+	t.shard(client).onClients <- client
+}
+
 func (t *SocketServerImpl) ListClient() <-chan SocketClient {
 	list := make(chan SocketClient, 32)
 	Go(func() {
@@ -357,7 +458,7 @@ func (t *SocketServerImpl) TotalClient() int {
 func (t *SocketServerImpl) OffClient(client SocketClient) {
 	Warn("SocketServerImpl.OffClient %s", client)
 	if client != nil {
-		t.offClients <- client
+		t.shard(client).offClients <- client
 	}
 }
 
@@ -388,13 +489,21 @@ func (t *SocketServerImpl) doOffClient(call ServerListener, client SocketClient)
 }
 
 func (t *SocketServerImpl) Loop(call ServerListener) {
-	Debug("SocketServerImpl.Loop")
+	Debug("SocketServerImpl.Loop: %d shards", len(t.shards))
 	defer t.close()
+	for _, s := range t.shards[1:] {
+		shard := s
+		Go(func() { t.loop(call, shard) })
+	}
+	t.loop(call, t.shards[0])
+}
+
+func (t *SocketServerImpl) loop(call ServerListener, shard *serverShard) {
 	for {
 		select {
-		case client := <-t.onClients:
+		case client := <-shard.onClients:
 			t.doOnClient(call, client)
-		case client := <-t.offClients:
+		case client := <-shard.offClients:
 			t.doOffClient(call, client)
 		}
 	}
@@ -408,7 +517,10 @@ func (t *SocketServerImpl) Read(client SocketClient, ReadAt ReadClient) {
 		for {
 			select {
 			case frame := <-queue:
-				if err := ReadAt(client, frame); err != nil {
+				err := ReadAt(client, frame)
+				// frame is done after read.
+				frame.Release()
+				if err != nil {
 					Error("SocketServerImpl.Read: readAt %s", err)
 					return
 				}
@@ -422,6 +534,7 @@ func (t *SocketServerImpl) Read(client SocketClient, ReadAt ReadClient) {
 		if err != nil || frame.size <= 0 {
 			if frame != nil {
 				Error("SocketServerImpl.Read: %s %d", client, frame.size)
+				frame.Release()
 			} else {
 				Error("SocketServerImpl.Read: %s %s", client, err)
 			}
@@ -465,14 +578,17 @@ func (t *SocketServerImpl) SetTimeout(v int64) {
 // pre-process when accept connection,
 // and allowed accept new connection, will return nil.
 func (t *SocketServerImpl) preAccept(conn net.Conn, err error) error {
+	// called by multiple accept loops.
+	t.lock.Lock()
+	last := t.error
+	t.error = err
+	t.lock.Unlock()
 	if err != nil {
-		if t.error == nil || t.error.Error() != err.Error() {
+		if last == nil || last.Error() != err.Error() {
 			Warn("SocketServerImpl.preAccept: %s", err)
 		}
-		t.error = err
 		return err
 	}
-	t.error = nil
 	addr := conn.RemoteAddr()
 	Debug("SocketServerImpl.preAccept: %s", addr)
 	t.statistics.Add(SsAccept, 1)
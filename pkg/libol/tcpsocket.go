@@ -8,19 +8,21 @@ import (
 )
 
 type TcpConfig struct {
-	Tls     *tls.Config
-	Block   kcp.BlockCrypt
-	Timeout time.Duration // ns
-	RdQus   int           // per frames
-	WrQus   int           // per frames
+	Tls       *tls.Config
+	Block     kcp.BlockCrypt
+	Timeout   time.Duration // ns
+	RdQus     int           // per frames
+	WrQus     int           // per frames
+	Proxy     string        // upstream proxy for client
+	Listeners int           // listeners by SO_REUSEPORT, and per core if negative.
 }
 
 // Server Implement
 
 type TcpServer struct {
 	*SocketServerImpl
-	tcpCfg   *TcpConfig
-	listener net.Listener
+	tcpCfg    *TcpConfig
+	listeners []net.Listener
 }
 
 func NewTcpServer(listen string, cfg *TcpConfig) *TcpServer {
@@ -30,33 +32,42 @@ func NewTcpServer(listen string, cfg *TcpConfig) *TcpServer {
 	}
 	t.WrQus = cfg.WrQus
 	t.close = t.Close
+	t.SetShards(Listeners(cfg.Listeners))
 	return t
 }
 
-func (t *TcpServer) Listen() (err error) {
+func (t *TcpServer) Listen() error {
+	if !t.isClosed() {
+		return nil
+	}
+	listeners, err := ListenTcp(t.address, t.tcpCfg.Listeners)
+	if err != nil {
+		return err
+	}
 	if t.tcpCfg.Tls != nil {
-		t.listener, err = tls.Listen("tcp", t.address, t.tcpCfg.Tls)
-		if err != nil {
-			t.listener = nil
-			return err
+		for i, l := range listeners {
+			listeners[i] = tls.NewListener(l, t.tcpCfg.Tls)
 		}
-		Info("TcpServer.Listen: tls://%s", t.address)
+		Info("TcpServer.Listen: tls://%s with %d", t.address, len(listeners))
 	} else {
-		t.listener, err = net.Listen("tcp", t.address)
-		if err != nil {
-			t.listener = nil
-			return err
-		}
-		Info("TcpServer.Listen: tcp://%s", t.address)
+		Info("TcpServer.Listen: tcp://%s with %d", t.address, len(listeners))
 	}
+	t.lock.Lock()
+	t.listeners = listeners
+	t.lock.Unlock()
 	return nil
 }
 
 func (t *TcpServer) Close() {
-	if t.listener != nil {
-		_ = t.listener.Close()
+	t.lock.Lock()
+	listeners := t.listeners
+	t.listeners = nil
+	t.lock.Unlock()
+	for _, l := range listeners {
+		_ = l.Close()
+	}
+	if len(listeners) > 0 {
 		Info("TcpServer.Close: %s", t.address)
-		t.listener = nil
 	}
 }
 
@@ -75,18 +86,39 @@ func (t *TcpServer) Accept() {
 		return nil
 	})
 	defer t.Close()
+	t.lock.RLock()
+	listeners := t.listeners
+	t.lock.RUnlock()
+	if len(listeners) == 0 {
+		return
+	}
+	// accept loop per listener.
+	for _, l := range listeners[1:] {
+		listener := l
+		Go(func() { t.accept(listener) })
+	}
+	t.accept(listeners[0])
+}
+
+func (t *TcpServer) accept(listener net.Listener) {
 	for {
-		if t.listener == nil {
-			return
-		}
-		conn, err := t.listener.Accept()
+		conn, err := listener.Accept()
 		if t.preAccept(conn, err) != nil {
+			if t.isClosed() {
+				return
+			}
 			continue
 		}
-		t.onClients <- NewTcpClientFromConn(conn, t.tcpCfg)
+		t.onClient(NewTcpClientFromConn(conn, t.tcpCfg))
 	}
 }
 
+func (t *TcpServer) isClosed() bool {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	return t.listeners == nil
+}
+
 // Client Implement
 
 type TcpClient struct {
@@ -126,12 +158,16 @@ func (t *TcpClient) Connect() error {
 	}
 	var err error
 	var conn net.Conn
+	proxy := t.tcpCfg.Proxy
+	if proxy != "" {
+		t.out.Info("TcpClient.Connect: via %s", RedactProxy(proxy))
+	}
 	if t.tcpCfg.Tls != nil {
 		t.out.Info("TcpClient.Connect: tls://%s", t.address)
-		conn, err = tls.Dial("tcp", t.address, t.tcpCfg.Tls)
+		conn, err = DialTls(proxy, t.address, t.tcpCfg.Tls)
 	} else {
 		t.out.Info("TcpClient.Connect: tcp://%s", t.address)
-		conn, err = net.Dial("tcp", t.address)
+		conn, err = Dial(proxy, t.address)
 	}
 	if err != nil {
 		return err
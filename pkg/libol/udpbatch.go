@@ -0,0 +1,328 @@
+package libol
+
+import (
+	"golang.org/x/net/ipv4"
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	UdpBatch   = 32    // datagrams per syscall.
+	UdpSegMax  = 64    // segments per gso datagram.
+	UdpSegSize = 65000 // bytes per gso datagram.
+)
+
+type udpPacket struct {
+	frame *FrameMessage
+	addr  *net.UDPAddr
+}
+
+// UdpBatcher reads and writes datagrams in batch by recvmmsg and sendmmsg,
+// and one datagram per syscall if size is 1. On linux, gso and gro is used
+// if offload is enabled and supported by kernel.
+type UdpBatcher struct {
+	lock sync.Mutex
+	conn *net.UDPConn
+	pc   *ipv4.PacketConn
+	size int
+	gro  bool // coalesced by kernel if receiving.
+	gso  bool // segmented by kernel if sending.
+	// reading, and only one reader.
+	rMsgs []ipv4.Message
+	rNum  int
+	rIdx  int
+	rOff  int // offset in buffer coalesced by gro.
+	rSeg  int // size of segment coalesced by gro.
+	// writing
+	wQueue chan udpPacket
+	wMsgs  []ipv4.Message
+	wBufs  [][]byte
+	done   chan bool
+	closed bool
+}
+
+func NewUdpBatcher(conn *net.UDPConn, size, bufSize int, offload bool) *UdpBatcher {
+	if size <= 0 {
+		size = UdpBatch
+	}
+	if bufSize <= 0 {
+		bufSize = MaxBuf
+	}
+	b := &UdpBatcher{
+		conn: conn,
+		pc:   ipv4.NewPacketConn(conn),
+		size: size,
+		done: make(chan bool),
+	}
+	if offload {
+		b.gro = setUdpGro(conn)
+		b.gso = udpOOBSize > 0
+		if b.gro {
+			// a buffer coalesced holds many segments.
+			bufSize = UdpSegSize + 1500
+		} else {
+			Warn("NewUdpBatcher: gro notSupport")
+		}
+	}
+	b.rMsgs = make([]ipv4.Message, size)
+	for i := range b.rMsgs {
+		b.rMsgs[i].Buffers = [][]byte{make([]byte, bufSize)}
+		if b.gro {
+			b.rMsgs[i].OOB = make([]byte, udpOOBSize)
+		}
+	}
+	if size > 1 {
+		b.wQueue = make(chan udpPacket, size*8)
+		b.wMsgs = make([]ipv4.Message, size)
+		b.wBufs = make([][]byte, size)
+		Go(b.loop)
+	}
+	return b
+}
+
+func (b *UdpBatcher) fill() error {
+	for i := range b.rMsgs {
+		m := &b.rMsgs[i]
+		m.Buffers[0] = m.Buffers[0][:cap(m.Buffers[0])]
+		if m.OOB != nil {
+			m.OOB = m.OOB[:cap(m.OOB)]
+		}
+	}
+	n, err := b.pc.ReadBatch(b.rMsgs, 0)
+	if err != nil {
+		return err
+	}
+	b.rNum = n
+	b.rIdx = 0
+	b.first()
+	return nil
+}
+
+// first prepares to read from the message at rIdx.
+func (b *UdpBatcher) first() {
+	b.rOff = 0
+	b.rSeg = 0
+	if b.rIdx < b.rNum && b.gro {
+		m := &b.rMsgs[b.rIdx]
+		b.rSeg = getUdpGro(m.OOB[:m.NN])
+	}
+}
+
+// ReadFrom copies a datagram to p, and returns the address from.
+func (b *UdpBatcher) ReadFrom(p []byte) (int, *net.UDPAddr, error) {
+	if b.size <= 1 {
+		return b.conn.ReadFromUDP(p)
+	}
+	for b.rIdx >= b.rNum {
+		if err := b.fill(); err != nil {
+			return 0, nil, err
+		}
+	}
+	m := &b.rMsgs[b.rIdx]
+	addr, _ := m.Addr.(*net.UDPAddr)
+	data := m.Buffers[0][b.rOff:m.N]
+	if b.rSeg > 0 && b.rSeg < len(data) {
+		data = data[:b.rSeg]
+	}
+	n := copy(p, data)
+	b.rOff += len(data)
+	if b.rOff >= m.N {
+		b.rIdx++
+		b.first()
+	}
+	return n, addr, nil
+}
+
+// WriteTo sends p to addr, and addr is nil if conn is connected. The p is
+// free for caller after it returns.
+func (b *UdpBatcher) WriteTo(p []byte, addr *net.UDPAddr) (int, error) {
+	if b.size <= 1 {
+		if addr == nil {
+			return b.conn.Write(p)
+		}
+		return b.conn.WriteToUDP(p, addr)
+	}
+	frame := AllocFrame(len(p))
+	frame.Append(p)
+	select {
+	case b.wQueue <- udpPacket{frame: frame, addr: addr}:
+		return len(p), nil
+	case <-b.done:
+		frame.Release()
+		return 0, NewErr("write to closed")
+	}
+}
+
+func (b *UdpBatcher) loop() {
+	packets := make([]udpPacket, 0, b.size)
+	for {
+		select {
+		case pkt := <-b.wQueue:
+			packets = append(packets[:0], pkt)
+		case <-b.done:
+			return
+		}
+	drain:
+		for len(packets) < b.size {
+			select {
+			case pkt := <-b.wQueue:
+				packets = append(packets, pkt)
+			default:
+				break drain
+			}
+		}
+		if err := b.send(packets); err != nil {
+			Debug("UdpBatcher.loop: %s", err)
+		}
+		for _, pkt := range packets {
+			pkt.frame.Release()
+		}
+	}
+}
+
+// group returns count of packets from start able to send in one segment.
+func (b *UdpBatcher) group(packets []udpPacket, start int) int {
+	if !b.gso {
+		return 1
+	}
+	first := packets[start]
+	seg := first.frame.Size()
+	total := seg
+	i := start + 1
+	for ; i < len(packets) && i-start < UdpSegMax; i++ {
+		pkt := packets[i]
+		size := pkt.frame.Size()
+		if size > seg || total+size > UdpSegSize || !sameUdpAddr(pkt.addr, first.addr) {
+			break
+		}
+		total += size
+		if size < seg { // the last is able to be smaller.
+			i++
+			break
+		}
+	}
+	return i - start
+}
+
+func sameUdpAddr(a, b *net.UDPAddr) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Port == b.Port && a.IP.Equal(b.IP)
+}
+
+func (b *UdpBatcher) send(packets []udpPacket) error {
+	num := 0
+	for i, pkt := range packets {
+		b.wBufs[i] = pkt.frame.Frame()[:pkt.frame.Size()]
+	}
+	for i := 0; i < len(packets); {
+		n := b.group(packets, i)
+		m := &b.wMsgs[num]
+		m.Buffers = b.wBufs[i : i+n]
+		m.OOB = nil
+		if n > 1 {
+			m.OOB = newUdpGso(packets[i].frame.Size())
+		}
+		if addr := packets[i].addr; addr != nil {
+			m.Addr = addr
+		} else {
+			m.Addr = nil
+		}
+		num++
+		i += n
+	}
+	var last error
+	msgs := b.wMsgs[:num]
+	sent := 0 // packets before msgs.
+	for len(msgs) > 0 {
+		n, err := b.pc.WriteBatch(msgs, 0)
+		if n < 0 {
+			n = 0
+		}
+		for _, m := range msgs[:n] {
+			sent += len(m.Buffers)
+		}
+		msgs = msgs[n:]
+		if err == nil || len(msgs) == 0 {
+			continue
+		}
+		// the first of msgs is failed.
+		if b.gso && len(msgs[0].Buffers) > 1 {
+			// kernel may not support, and fallback to no gso for unsent.
+			Warn("UdpBatcher.send: %s, and disable gso", err)
+			b.gso = false
+			return b.send(packets[sent:])
+		}
+		// skip it, such as too big with df, and others are sent.
+		last = err
+		sent += len(msgs[0].Buffers)
+		msgs = msgs[1:]
+	}
+	return last
+}
+
+func (b *UdpBatcher) Close() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.closed {
+		return
+	}
+	b.closed = true
+	close(b.done)
+}
+
+// UdpBatchConn is a connected udp conn, which reads and writes in batch.
+type UdpBatchConn struct {
+	*net.UDPConn
+	batch   *UdpBatcher
+	session atomic.Value // *XdpSession carried if not nil.
+	seq     uint64
+}
+
+func NewUdpBatchConn(conn *net.UDPConn, size int, offload bool) *UdpBatchConn {
+	return &UdpBatchConn{
+		UDPConn: conn,
+		batch:   NewUdpBatcher(conn, size, MaxMsg, offload),
+	}
+}
+
+func (c *UdpBatchConn) Read(p []byte) (int, error) {
+	n, _, err := c.batch.ReadFrom(p)
+	return n, err
+}
+
+// SetSession carries session in datagrams, and it's able to roam to
+// other source address.
+func (c *UdpBatchConn) SetSession(s *XdpSession) error {
+	c.session.Store(s)
+	return nil
+}
+
+func (c *UdpBatchConn) SetDontFrag(enable bool) error {
+	return setDontFrag(c.UDPConn, enable)
+}
+
+func (c *UdpBatchConn) Write(p []byte) (int, error) {
+	s, _ := c.session.Load().(*XdpSession)
+	if s == nil {
+		return c.batch.WriteTo(p, nil)
+	}
+	frame := AllocFrame(XdpHlSize + len(p))
+	buf := frame.Frame()
+	s.Encode(buf, atomic.AddUint64(&c.seq, 1))
+	copy(buf[XdpHlSize:], p)
+	frame.SetSize(XdpHlSize + len(p))
+	n, err := c.batch.WriteTo(frame.Frame()[:frame.Size()], nil)
+	frame.Release()
+	if err != nil {
+		return 0, err
+	}
+	return n - XdpHlSize, nil
+}
+
+func (c *UdpBatchConn) Close() error {
+	c.batch.Close()
+	return c.UDPConn.Close()
+}
@@ -0,0 +1,52 @@
+package libol
+
+import (
+	"net"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	udpSegment = 103 // UDP_SEGMENT
+	udpGro     = 104 // UDP_GRO
+)
+
+var udpOOBSize = syscall.CmsgSpace(4)
+
+// setUdpGro enables gro on conn, and returns false if not supported.
+func setUdpGro(conn *net.UDPConn) bool {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return false
+	}
+	var opErr error
+	err = raw.Control(func(fd uintptr) {
+		opErr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_UDP, udpGro, 1)
+	})
+	return err == nil && opErr == nil
+}
+
+// getUdpGro returns size of segment coalesced, and 0 if not.
+func getUdpGro(oob []byte) int {
+	msgs, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil {
+		return 0
+	}
+	for _, m := range msgs {
+		if m.Header.Level == syscall.IPPROTO_UDP && m.Header.Type == udpGro && len(m.Data) >= 4 {
+			return int(*(*int32)(unsafe.Pointer(&m.Data[0])))
+		}
+	}
+	return 0
+}
+
+// newUdpGso returns control message to send segments of size.
+func newUdpGso(size int) []byte {
+	oob := make([]byte, syscall.CmsgSpace(2))
+	h := (*syscall.Cmsghdr)(unsafe.Pointer(&oob[0]))
+	h.Level = syscall.IPPROTO_UDP
+	h.Type = udpSegment
+	h.SetLen(syscall.CmsgLen(2))
+	*(*uint16)(unsafe.Pointer(&oob[syscall.CmsgLen(0)])) = uint16(size)
+	return oob
+}
@@ -0,0 +1,19 @@
+// +build !linux
+
+package libol
+
+import "net"
+
+var udpOOBSize = 0
+
+func setUdpGro(conn *net.UDPConn) bool {
+	return false
+}
+
+func getUdpGro(oob []byte) int {
+	return 0
+}
+
+func newUdpGso(size int) []byte {
+	return nil
+}
@@ -0,0 +1,167 @@
+package libol
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestXDP_BatchReadWrite(t *testing.T) {
+	testXDPBatch(t, false)
+	testXDPBatch(t, true)
+}
+
+func testXDPBatch(t *testing.T, offload bool) {
+	ln, err := XDPListen("127.0.0.1:0", XDPConfig{Clients: 16, Batch: 8, Offload: offload})
+	assert.Nil(t, err, "listen")
+	defer ln.Close()
+
+	conn, err := net.Dial("udp", ln.Addr().String())
+	assert.Nil(t, err, "dial")
+	c := NewUdpBatchConn(conn.(*net.UDPConn), 8, offload)
+	defer c.Close()
+
+	for i := 0; i < 20; i++ {
+		_, err := c.Write([]byte{byte(i), 0x01, 0x02})
+		assert.Nil(t, err, "be nil.")
+	}
+	s, err := ln.Accept()
+	assert.Nil(t, err, "accept")
+	_ = s.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, 1500)
+	for i := 0; i < 20; i++ {
+		n, err := s.Read(buf)
+		assert.Nil(t, err, "be nil.")
+		assert.Equal(t, []byte{byte(i), 0x01, 0x02}, buf[:n], "be the same.")
+	}
+
+	for i := 0; i < 20; i++ {
+		_, err := s.Write([]byte{byte(i), 0x03})
+		assert.Nil(t, err, "be nil.")
+	}
+	_ = c.SetReadDeadline(time.Now().Add(time.Second))
+	for i := 0; i < 20; i++ {
+		n, err := c.Read(buf)
+		assert.Nil(t, err, "be nil.")
+		assert.Equal(t, []byte{byte(i), 0x03}, buf[:n], "be the same.")
+	}
+}
+
+func TestXDP_Roaming(t *testing.T) {
+	ln, err := XDPListen("127.0.0.1:0", XDPConfig{Clients: 16, Timeout: time.Second})
+	assert.Nil(t, err, "listen")
+	defer ln.Close()
+	x := ln.(*XDP)
+
+	buf := make([]byte, 1500)
+	dial := func() (*UdpBatchConn, net.Conn) {
+		conn, err := net.Dial("udp", ln.Addr().String())
+		assert.Nil(t, err, "dial")
+		return NewUdpBatchConn(conn.(*net.UDPConn), 1, false), conn
+	}
+	read := func(s net.Conn) ([]byte, error) {
+		_ = s.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+		n, err := s.Read(buf)
+		return buf[:n], err
+	}
+	// without session before negotiated.
+	c1, conn1 := dial()
+	defer c1.Close()
+	_, _ = c1.Write([]byte{0x01, 0x00})
+	s, err := ln.Accept()
+	assert.Nil(t, err, "accept")
+	defer s.Close()
+	data, err := read(s)
+	assert.Nil(t, err, "be nil.")
+	assert.Equal(t, []byte{0x01, 0x00}, data, "be the same.")
+	// carried session after bound.
+	sess := NewXdpSession()
+	assert.Nil(t, s.(*XDPConn).SetSession(sess), "be nil.")
+	assert.Nil(t, c1.SetSession(sess), "be nil.")
+	n, err := c1.Write([]byte{0x01, 0x01})
+	assert.Nil(t, err, "be nil.")
+	assert.Equal(t, 2, n, "be the same.")
+	data, err = read(s)
+	assert.Nil(t, err, "be nil.")
+	assert.Equal(t, []byte{0x01, 0x01}, data, "be the same.")
+	assert.Equal(t, conn1.LocalAddr().String(), s.RemoteAddr().String(), "be the same.")
+
+	// forged by other key is refused.
+	c2, _ := dial()
+	_ = c2.SetSession(&XdpSession{Id: sess.Id, Key: make([]byte, XdpKeySize)})
+	c2.seq = 100
+	_, _ = c2.Write([]byte{0x02, 0x00})
+	_, err = read(s)
+	assert.NotNil(t, err, "be not nil.")
+	_ = c2.Close()
+	// replayed from other address is refused.
+	raw, _ := net.Dial("udp", ln.Addr().String())
+	header := make([]byte, XdpHlSize+2)
+	sess.Encode(header, 1)
+	_, _ = raw.Write(header)
+	_, err = read(s)
+	assert.NotNil(t, err, "be not nil.")
+	_ = raw.Close()
+	assert.Equal(t, conn1.LocalAddr().String(), s.RemoteAddr().String(), "be the same.")
+
+	// roamed to new address by newer sequence.
+	c3, conn3 := dial()
+	defer c3.Close()
+	_ = c3.SetSession(sess)
+	c3.seq = c1.seq
+	_, _ = c3.Write([]byte{0x03, 0x00})
+	data, err = read(s)
+	assert.Nil(t, err, "be nil.")
+	assert.Equal(t, []byte{0x03, 0x00}, data, "be the same.")
+	assert.Equal(t, conn3.LocalAddr().String(), s.RemoteAddr().String(), "be the same.")
+
+	sts := x.Statistics()
+	assert.Equal(t, int64(1), sts[XsSessions], "be the same.")
+	assert.Equal(t, int64(1), sts[XsRoamed], "be the same.")
+	assert.Equal(t, int64(2), sts[XsRefused], "be the same.")
+
+	time.Sleep(3500 * time.Millisecond)
+	sts = x.Statistics()
+	assert.Equal(t, int64(0), sts[XsSessions], "be the same.")
+	assert.Equal(t, int64(1), sts[XsExpired], "be the same.")
+	assert.Equal(t, 0, x.roams.Len(), "be the same.")
+}
+
+func TestXdpSession_Parse(t *testing.T) {
+	sess := NewXdpSession()
+	obj, err := ParseXdpSession(sess.String())
+	assert.Nil(t, err, "be nil.")
+	assert.Equal(t, sess, obj, "be the same.")
+	_, err = ParseXdpSession("1234")
+	assert.NotNil(t, err, "be not nil.")
+	_, err = ParseXdpSession("00001234:00")
+	assert.NotNil(t, err, "be not nil.")
+}
+
+func TestUdpBatcher_SendSkip(t *testing.T) {
+	peer, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	assert.Nil(t, err, "be nil.")
+	defer peer.Close()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	assert.Nil(t, err, "be nil.")
+	b := NewUdpBatcher(conn, 8, MaxMsg, false)
+	defer b.Close()
+
+	addr := peer.LocalAddr().(*net.UDPAddr)
+	packets := make([]udpPacket, 0, 3)
+	for _, size := range []int{3, 70000, 4} {
+		frame := AllocFrame(size)
+		frame.Append(make([]byte, size))
+		packets = append(packets, udpPacket{frame: frame, addr: addr})
+	}
+	// too big is skipped, and others are sent.
+	assert.NotNil(t, b.send(packets), "be not nil.")
+	_ = peer.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, 1500)
+	for _, size := range []int{3, 4} {
+		n, err := peer.Read(buf)
+		assert.Nil(t, err, "be nil.")
+		assert.Equal(t, size, n, "be the same.")
+	}
+}
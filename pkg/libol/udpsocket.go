@@ -7,11 +7,14 @@ import (
 )
 
 type UdpConfig struct {
-	Block   kcp.BlockCrypt
-	Timeout time.Duration // ns
-	Clients int
-	RdQus   int // per frames
-	WrQus   int // per frames
+	Block     kcp.BlockCrypt
+	Timeout   time.Duration // ns
+	Clients   int
+	RdQus     int  // per frames
+	WrQus     int  // per frames
+	Batch     int  // datagrams per syscall
+	Offload   bool // gso and gro on linux
+	Listeners int  // sockets by SO_REUSEPORT, and per core if negative.
 }
 
 var defaultUdpConfig = UdpConfig{
@@ -37,11 +40,20 @@ func NewUdpServer(listen string, cfg *UdpConfig) *UdpServer {
 		SocketServerImpl: NewSocketServer(listen),
 	}
 	k.close = k.Close
+	k.SetShards(Listeners(cfg.Listeners))
 	return k
 }
 
 func (k *UdpServer) Listen() (err error) {
-	k.listener, err = XDPListen(k.address, k.udpCfg.Clients, k.udpCfg.RdQus*2)
+	cfg := k.udpCfg
+	k.listener, err = XDPListen(k.address, XDPConfig{
+		Clients:   cfg.Clients,
+		BufSize:   cfg.RdQus * 2,
+		Batch:     cfg.Batch,
+		Offload:   cfg.Offload,
+		Timeout:   cfg.Timeout,
+		Listeners: cfg.Listeners,
+	})
 	if err != nil {
 		k.listener = nil
 		return err
@@ -58,6 +70,16 @@ func (k *UdpServer) Close() {
 	}
 }
 
+func (k *UdpServer) Statistics() map[string]int64 {
+	sts := k.SocketServerImpl.Statistics()
+	if x, ok := k.listener.(*XDP); ok {
+		for key, value := range x.Statistics() {
+			sts[key] = value
+		}
+	}
+	return sts
+}
+
 func (k *UdpServer) Accept() {
 	promise := Promise{
 		First:  2 * time.Second,
@@ -80,7 +102,7 @@ func (k *UdpServer) Accept() {
 		if k.preAccept(conn, err) != nil {
 			continue
 		}
-		k.onClients <- NewUdpClientFromConn(conn, k.udpCfg)
+		k.onClient(NewUdpClientFromConn(conn, k.udpCfg))
 	}
 }
 
@@ -100,7 +122,7 @@ func NewUdpClient(addr string, cfg *UdpConfig) *UdpClient {
 		SocketClientImpl: NewSocketClient(addr, &PacketMessagerImpl{
 			timeout: cfg.Timeout,
 			block:   cfg.Block,
-			bufSize: cfg.RdQus * MaxFrame,
+			bufSize: MaxMsg, // a datagram is a frame.
 		}),
 	}
 	return c
@@ -115,7 +137,7 @@ func NewUdpClientFromConn(conn net.Conn, cfg *UdpConfig) *UdpClient {
 		SocketClientImpl: NewSocketClient(addr, &PacketMessagerImpl{
 			timeout: cfg.Timeout,
 			block:   cfg.Block,
-			bufSize: cfg.RdQus * MaxFrame,
+			bufSize: MaxMsg, // a datagram is a frame.
 		}),
 	}
 	c.updateConn(conn)
@@ -131,6 +153,9 @@ func (c *UdpClient) Connect() error {
 	if err != nil {
 		return err
 	}
+	if udpConn, ok := conn.(*net.UDPConn); ok {
+		conn = NewUdpBatchConn(udpConn, c.udpCfg.Batch, c.udpCfg.Offload)
+	}
 	c.SetConnection(conn)
 	if c.listener.OnConnected != nil {
 		_ = c.listener.OnConnected(c)
@@ -138,6 +163,30 @@ func (c *UdpClient) Connect() error {
 	return nil
 }
 
+// SetSession carries session in datagrams after negotiated in login,
+// as lower versions refuse the header.
+func (c *UdpClient) SetSession(s *XdpSession) error {
+	c.lock.RLock()
+	conn := c.connection
+	c.lock.RUnlock()
+	if obj, ok := conn.(Sessioner); ok {
+		return obj.SetSession(s)
+	}
+	return NewErr("session notSupport")
+}
+
+// SetDontFrag sets DF bit of datagrams only while searching mtu of path,
+// as frames larger than it are dropped silently.
+func (c *UdpClient) SetDontFrag(enable bool) error {
+	c.lock.RLock()
+	conn := c.connection
+	c.lock.RUnlock()
+	if obj, ok := conn.(DontFragger); ok {
+		return obj.SetDontFrag(enable)
+	}
+	return NewErr("dontFrag notSupport")
+}
+
 func (c *UdpClient) Close() {
 	c.out.Debug("UdpClient.Close: %v", c.IsOk())
 	c.lock.Lock()
@@ -40,6 +40,7 @@ type WebConfig struct {
 	Timeout time.Duration // ns
 	RdQus   int           // per frames
 	WrQus   int           // per frames
+	Proxy   string        // upstream proxy for client
 }
 
 // Server Implement
@@ -93,7 +94,7 @@ func (t *WebServer) Accept() {
 		ws.PayloadType = websocket.BinaryFrame
 		wws := &wsConn{ws}
 		client := NewWebClientFromConn(wws, t.webCfg)
-		t.onClients <- client
+		t.onClient(client)
 		<-client.done
 		Info("WebServer.Accept: %s exit", ws.RemoteAddr())
 	})
@@ -193,7 +194,7 @@ func (t *WebClient) Connect() error {
 			return err
 		}
 	}
-	conn, err := websocket.DialConfig(config)
+	conn, err := t.dial(config)
 	if err != nil {
 		return err
 	}
@@ -204,6 +205,30 @@ func (t *WebClient) Connect() error {
 	return nil
 }
 
+func (t *WebClient) dial(config *websocket.Config) (net.Conn, error) {
+	proxy := t.webCfg.Proxy
+	if proxy == "" {
+		return websocket.DialConfig(config)
+	}
+	t.out.Info("WebClient.Connect: via %s", RedactProxy(proxy))
+	var err error
+	var conn net.Conn
+	if t.webCfg.Cert != nil {
+		conn, err = DialTls(proxy, t.address, config.TlsConfig)
+	} else {
+		conn, err = Dial(proxy, t.address)
+	}
+	if err != nil {
+		return nil, err
+	}
+	ws, err := websocket.NewClient(config, conn)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return ws, nil
+}
+
 func (t *WebClient) Close() {
 	t.out.Debug("WebClient.Close: %v", t.IsOk())
 	t.lock.Lock()
@@ -1,64 +1,224 @@
 package libol
 
 import (
+	"crypto/hmac"
+	crand "crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
 	"net"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// XdpMagic leads a datagram carried session, which is able to roam
+// to new source address.
+var XdpMagic = []byte{0xff, 0xfe}
+
+const (
+	XdpHlSize  = 22 // magic, session id, sequence and mac.
+	XdpMacSize = 8
+	XdpKeySize = 16
+	XsSessions = "sessions"
+	XsRoamed   = "roamed"
+	XsExpired  = "expired"
+	XsRefused  = "refused"
+)
+
+// XdpSession is id and key of a session, and header of datagrams is
+// signed by the key, so only its owner is able to roam.
+type XdpSession struct {
+	Id  uint32
+	Key []byte
+}
+
+func NewXdpSession() *XdpSession {
+	key := make([]byte, XdpKeySize)
+	_, _ = crand.Read(key)
+	return &XdpSession{
+		Id:  GenUint32() | 0x01,
+		Key: key,
+	}
+}
+
+// ParseXdpSession decodes session from <id>:<key> in hex.
+func ParseXdpSession(value string) (*XdpSession, error) {
+	values := strings.SplitN(value, ":", 2)
+	if len(values) != 2 {
+		return nil, NewErr("invalid session %s", value)
+	}
+	id, err := strconv.ParseUint(values[0], 16, 32)
+	if err != nil {
+		return nil, err
+	}
+	key, err := hex.DecodeString(values[1])
+	if err != nil {
+		return nil, err
+	}
+	if id == 0 || len(key) < XdpKeySize {
+		return nil, NewErr("invalid session %s", value)
+	}
+	return &XdpSession{Id: uint32(id), Key: key}, nil
+}
+
+func (s *XdpSession) String() string {
+	return fmt.Sprintf("%08x:%x", s.Id, s.Key)
+}
+
+func (s *XdpSession) mac(header []byte) []byte {
+	h := hmac.New(sha256.New, s.Key)
+	h.Write(header[:XdpHlSize-XdpMacSize])
+	return h.Sum(nil)[:XdpMacSize]
+}
+
+// Encode writes header with sequence into buf.
+func (s *XdpSession) Encode(buf []byte, seq uint64) {
+	copy(buf, XdpMagic)
+	binary.BigEndian.PutUint32(buf[2:6], s.Id)
+	binary.BigEndian.PutUint64(buf[6:14], seq)
+	copy(buf[14:XdpHlSize], s.mac(buf))
+}
+
+// Verify returns sequence of header, and false if not signed by it.
+func (s *XdpSession) Verify(header []byte) (uint64, bool) {
+	if len(header) < XdpHlSize || binary.BigEndian.Uint32(header[2:6]) != s.Id {
+		return 0, false
+	}
+	if !hmac.Equal(header[14:XdpHlSize], s.mac(header)) {
+		return 0, false
+	}
+	return binary.BigEndian.Uint64(header[6:14]), true
+}
+
+// Sessioner is a connection able to carry session in datagrams.
+type Sessioner interface {
+	SetSession(s *XdpSession) error
+}
+
+// DontFragger sets DF bit of datagrams to probe mtu of path.
+type DontFragger interface {
+	SetDontFrag(enable bool) error
+}
+
+// xdpSessionId returns session id of datagram, and false if not carried.
+func xdpSessionId(data []byte) (uint32, bool) {
+	if len(data) < XdpHlSize || data[0] != XdpMagic[0] || data[1] != XdpMagic[1] {
+		return 0, false
+	}
+	return binary.BigEndian.Uint32(data[2:6]), true
+}
+
+type XDPConfig struct {
+	Clients   int
+	BufSize   int
+	Batch     int           // datagrams per syscall.
+	Offload   bool          // gso and gro on linux.
+	Timeout   time.Duration // idle to expire a session.
+	Listeners int           // sockets by SO_REUSEPORT, and per core if negative.
+}
+
 type XDP struct {
-	lock       sync.RWMutex
-	bufSize    int
-	connection *net.UDPConn
-	address    *net.UDPAddr
-	sessions   *SafeStrMap
-	accept     chan *XDPConn
+	lock        sync.RWMutex
+	cfg         XDPConfig
+	connections []*net.UDPConn
+	batches     []*UdpBatcher // reader per socket.
+	address     *net.UDPAddr
+	sessions    *SafeStrMap // by address.
+	roams       *SafeStrMap // by session id.
+	accept      chan *XDPConn
+	statistics  *SafeStrInt64
+	done        chan bool
 }
 
-func XDPListen(addr string, clients, bufSize int) (net.Listener, error) {
+func XDPListen(addr string, cfg XDPConfig) (net.Listener, error) {
 	udpAddr, err := net.ResolveUDPAddr("udp", addr)
 	if err != nil {
 		return nil, err
 	}
-	if bufSize == 0 {
-		bufSize = MaxBuf
+	if cfg.BufSize == 0 {
+		cfg.BufSize = MaxBuf
 	}
-	Debug("bufSize: %d", bufSize)
+	Debug("bufSize: %d", cfg.BufSize)
 	x := &XDP{
-		address:  udpAddr,
-		sessions: NewSafeStrMap(clients),
-		accept:   make(chan *XDPConn, 2),
-		bufSize:  bufSize,
+		cfg:        cfg,
+		address:    udpAddr,
+		sessions:   NewSafeStrMap(cfg.Clients),
+		roams:      NewSafeStrMap(cfg.Clients),
+		accept:     make(chan *XDPConn, 2),
+		statistics: NewSafeStrInt64(),
+		done:       make(chan bool),
 	}
-	conn, err := net.ListenUDP("udp", udpAddr)
+	conns, err := ListenUdp(udpAddr.String(), cfg.Listeners)
 	if err != nil {
 		return nil, err
 	}
-	x.connection = conn
-	Go(x.Loop)
+	x.connections = conns
+	// port may be allocated by kernel.
+	x.address, _ = conns[0].LocalAddr().(*net.UDPAddr)
+	for _, conn := range conns {
+		batch := NewUdpBatcher(conn, cfg.Batch, cfg.BufSize, cfg.Offload)
+		x.batches = append(x.batches, batch)
+		Go(func() { x.Loop(batch) })
+	}
+	if cfg.Timeout > 0 {
+		Go(x.Expire)
+	}
 	return x, nil
 }
 
-func (x *XDP) Recv(udpAddr *net.UDPAddr, data []byte) error {
+// Recv dispatches datagram read by batch, and sessions are shared by all
+// sockets, so a roamed session could be received from others.
+func (x *XDP) Recv(batch *UdpBatcher, udpAddr *net.UDPAddr, data *FrameMessage) error {
 	// dispatch to XDPConn and new accept
 	addr := udpAddr.String()
+	if id, ok := xdpSessionId(data.Frame()[:data.Size()]); ok {
+		header := data.Frame()[:XdpHlSize]
+		if obj, ok := x.roams.GetEx(fmt.Sprintf("%08x", id)); ok {
+			conn := obj.(*XDPConn)
+			old, ok := conn.verify(header, udpAddr)
+			if !ok {
+				x.statistics.Add(XsRefused, 1)
+				data.Release()
+				return nil
+			}
+			if old != "" {
+				Info("XDP.Recv: %08x roamed from %s to %s", id, old, addr)
+				x.statistics.Add(XsRoamed, 1)
+				x.sessions.Del(old)
+				_ = x.sessions.Mod(addr, conn)
+			}
+			data.frame = data.frame[XdpHlSize:]
+			data.size -= XdpHlSize
+			conn.toQueue(data)
+			return nil
+		}
+		// not bound, and dispatched by address.
+		data.frame = data.frame[XdpHlSize:]
+		data.size -= XdpHlSize
+	}
 	if obj, ok := x.sessions.GetEx(addr); ok {
 		conn := obj.(*XDPConn)
+		conn.touch()
 		conn.toQueue(data)
 		return nil
 	}
 	conn := &XDPConn{
-		connection: x.connection,
+		batch:      batch,
 		remoteAddr: udpAddr,
 		localAddr:  x.address,
-		readQueue:  make(chan []byte, 1024),
+		readQueue:  make(chan *FrameMessage, 1024),
 		closed:     false,
-		onClose: func(conn *XDPConn) {
-			Info("XDP.Recv: onClose %s", conn)
-			x.sessions.Del(addr)
-		},
+		done:       make(chan bool),
+		activeTime: time.Now().Unix(),
+		onSession:  x.bind,
+		onClose:    x.unbind,
 	}
 	if err := x.sessions.Set(addr, conn); err != nil {
+		data.Release()
 		return NewErr("session.Set: %s", err)
 	}
 	x.accept <- conn
@@ -66,16 +226,80 @@ func (x *XDP) Recv(udpAddr *net.UDPAddr, data []byte) error {
 	return nil
 }
 
+// bind roams conn by session, and session of same id is replaced.
+func (x *XDP) bind(conn *XDPConn, s *XdpSession) error {
+	key := fmt.Sprintf("%08x", s.Id)
+	Info("XDP.bind: %s on %s", key, conn)
+	return x.roams.Mod(key, conn)
+}
+
+// unbind must be called with lock of conn.
+func (x *XDP) unbind(conn *XDPConn) {
+	addr := conn.remoteAddr.String()
+	Info("XDP.unbind: %s", addr)
+	if s := conn.session; s != nil {
+		key := fmt.Sprintf("%08x", s.Id)
+		if v := x.roams.Get(key); v == conn {
+			x.roams.Del(key)
+		}
+	}
+	if v := x.sessions.Get(addr); v == conn {
+		x.sessions.Del(addr)
+	}
+}
+
+// Expire closes sessions idle longer than timeout.
+func (x *XDP) Expire() {
+	interval := x.cfg.Timeout / 4
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-x.done:
+			return
+		case <-ticker.C:
+		}
+		deadline := time.Now().Add(-x.cfg.Timeout).Unix()
+		idles := make([]*XDPConn, 0, 8)
+		x.sessions.Iter(func(k string, v interface{}) {
+			if conn, ok := v.(*XDPConn); ok && conn.ActiveTime() < deadline {
+				idles = append(idles, conn)
+			}
+		})
+		for _, conn := range idles {
+			Info("XDP.Expire: %s", conn)
+			_ = conn.Close()
+			x.statistics.Add(XsExpired, 1)
+		}
+	}
+}
+
+func (x *XDP) Statistics() map[string]int64 {
+	sts := make(map[string]int64, 4)
+	x.statistics.Copy(sts)
+	sts[XsSessions] = int64(x.sessions.Len())
+	return sts
+}
+
 // Loop forever
-func (x *XDP) Loop() {
+func (x *XDP) Loop(batch *UdpBatcher) {
 	for {
-		data := make([]byte, x.bufSize)
-		n, udpAddr, err := x.connection.ReadFromUDP(data)
+		data := AllocFrame(x.cfg.BufSize)
+		n, udpAddr, err := batch.ReadFrom(data.Frame())
 		if err != nil {
+			data.Release()
 			Error("XDP.Loop %s", err)
 			break
 		}
-		if err := x.Recv(udpAddr, data[:n]); err != nil {
+		if udpAddr == nil {
+			data.Release()
+			continue
+		}
+		data.SetSize(n)
+		if err := x.Recv(batch, udpAddr, data); err != nil {
 			Warn("XDP.Loop: %s", err)
 		}
 	}
@@ -92,7 +316,15 @@ func (x *XDP) Close() error {
 	x.lock.Lock()
 	defer x.lock.Unlock()
 
-	_ = x.connection.Close()
+	for i, conn := range x.connections {
+		_ = conn.Close()
+		x.batches[i].Close()
+	}
+	select {
+	case <-x.done:
+	default:
+		close(x.done)
+	}
 	return nil
 }
 
@@ -103,20 +335,73 @@ func (x *XDP) Addr() net.Addr {
 
 type XDPConn struct {
 	lock       sync.RWMutex
-	connection *net.UDPConn
+	batch      *UdpBatcher
 	remoteAddr *net.UDPAddr
 	localAddr  *net.UDPAddr
-	readQueue  chan []byte
+	readQueue  chan *FrameMessage
 	closed     bool
+	done       chan bool
 	readDead   time.Time
 	writeDead  time.Time
+	activeTime int64 // unix time of last received.
+	session    *XdpSession
+	seq        uint64 // latest sequence of session.
+	onSession  func(conn *XDPConn, s *XdpSession) error
 	onClose    func(conn *XDPConn)
 }
 
-func (c *XDPConn) toQueue(b []byte) {
+// SetSession enables to roam by datagrams signed by the session.
+func (c *XDPConn) SetSession(s *XdpSession) error {
+	if c.onSession == nil {
+		return NewErr("session notSupport")
+	}
+	c.lock.Lock()
+	c.session = s
+	c.seq = 0
+	c.lock.Unlock()
+	return c.onSession(c, s)
+}
+
+func (c *XDPConn) touch() {
+	atomic.StoreInt64(&c.activeTime, time.Now().Unix())
+}
+
+// verify checks header signed by session, and returns the old address
+// if roamed. It roams only by a newer sequence, so datagrams replayed
+// or not signed from other address are refused.
+func (c *XDPConn) verify(header []byte, addr *net.UDPAddr) (string, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	same := sameUdpAddr(c.remoteAddr, addr)
+	if same {
+		c.touch()
+	}
+	if c.session == nil {
+		return "", same
+	}
+	seq, ok := c.session.Verify(header)
+	if !ok || seq <= c.seq {
+		return "", same
+	}
+	c.seq = seq
+	if same {
+		return "", true
+	}
+	c.touch()
+	old := c.remoteAddr.String()
+	c.remoteAddr = addr
+	return old, true
+}
+
+func (c *XDPConn) ActiveTime() int64 {
+	return atomic.LoadInt64(&c.activeTime)
+}
+
+func (c *XDPConn) toQueue(b *FrameMessage) {
 	c.lock.RLock()
 	if c.closed {
 		c.lock.RUnlock()
+		b.Release()
 		return
 	} else {
 		c.lock.RUnlock()
@@ -147,11 +432,15 @@ func (c *XDPConn) Read(b []byte) (n int, err error) {
 	select {
 	case <-outChan:
 		return 0, NewErr("read timeout")
+	case <-c.done:
+		return 0, NewErr("read on closed")
 	case d := <-c.readQueue:
 		if timeout != nil {
 			timeout.Stop()
 		}
-		return copy(b, d), nil
+		n := copy(b, d.Frame()[:d.Size()])
+		d.Release()
+		return n, nil
 	}
 }
 
@@ -160,10 +449,10 @@ func (c *XDPConn) Write(b []byte) (n int, err error) {
 	if c.closed {
 		c.lock.RUnlock()
 		return 0, NewErr("write to closed")
-	} else {
-		c.lock.RUnlock()
 	}
-	return c.connection.WriteToUDP(b, c.remoteAddr)
+	addr := c.remoteAddr
+	c.lock.RUnlock()
+	return c.batch.WriteTo(b, addr)
 }
 
 func (c *XDPConn) Close() error {
@@ -175,8 +464,8 @@ func (c *XDPConn) Close() error {
 	if c.onClose != nil {
 		c.onClose(c)
 	}
-	c.connection = nil
 	c.closed = true
+	close(c.done)
 
 	return nil
 }
@@ -186,6 +475,8 @@ func (c *XDPConn) LocalAddr() net.Addr {
 }
 
 func (c *XDPConn) RemoteAddr() net.Addr {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
 	return c.remoteAddr
 }
 
@@ -212,5 +503,5 @@ func (c *XDPConn) SetWriteDeadline(t time.Time) error {
 }
 
 func (c *XDPConn) String() string {
-	return c.remoteAddr.String()
+	return c.RemoteAddr().String()
 }
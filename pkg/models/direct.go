@@ -0,0 +1,65 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	DirectInit      = "init"
+	DirectPunching  = "punching"
+	DirectConnected = "connected"
+	DirectExpired   = "expired"
+)
+
+// DirectPath is status of an udp path between two points.
+type DirectPath struct {
+	Peer      string `json:"peer"`
+	Alias     string `json:"alias"`
+	Endpoint  string `json:"endpoint"`
+	State     string `json:"state"`
+	RxBytes   int64  `json:"rxBytes"`
+	TxBytes   int64  `json:"txBytes"`
+	AliveTime int64  `json:"aliveTime"`
+}
+
+func (p *DirectPath) String() string {
+	return fmt.Sprintf("%s, %s, %s", p.Peer, p.Endpoint, p.State)
+}
+
+// Candidate is advertised by point to switch, and switch forwards it
+// to others in same network for punching.
+type Candidate struct {
+	UUID     string        `json:"uuid"`
+	Alias    string        `json:"alias"`
+	Token    string        `json:"token"`
+	HwAddr   []string      `json:"hwAddr"`
+	Local    []string      `json:"local"`
+	Public   string        `json:"public,omitempty"`
+	Paths    []*DirectPath `json:"paths,omitempty"`
+	UpdateAt int64         `json:"-"`
+}
+
+func (c *Candidate) String() string {
+	return fmt.Sprintf("%s, %s, %s", c.UUID, c.Public, c.Local)
+}
+
+func (c *Candidate) Update() {
+	c.UpdateAt = time.Now().Unix()
+}
+
+// Rendezvous is replied by switch for candidate request.
+type Rendezvous struct {
+	UUID  string       `json:"uuid"`
+	Port  string       `json:"port"`
+	Peers []*Candidate `json:"peers"`
+}
+
+// Punch is sent on direct udp socket to switch for learning public
+// address, or to a peer for probing path.
+type Punch struct {
+	UUID    string `json:"uuid"`
+	Token   string `json:"token"`
+	Address string `json:"address,omitempty"`
+	Time    int64  `json:"time"`
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/danieldin95/openlan/pkg/schema"
 	nl "github.com/vishvananda/netlink"
 	"net"
+	"sync"
 	"time"
 )
 
@@ -35,7 +36,59 @@ func NewEspSchema(e *Esp) schema.Esp {
 
 type EspState struct {
 	*schema.EspState
-	NewTime int64
+	NewTime   int64
+	KeyTime   int64 // negotiated at.
+	NextRekey int64
+	Health    *EspHealth
+}
+
+// EspHealth is probed through tunnel and shared by states of member.
+type EspHealth struct {
+	lock  sync.RWMutex
+	value EspHealthValue
+}
+
+type EspHealthValue struct {
+	Status     string
+	Rtt        int64
+	Flaps      int
+	ChangeTime int64
+}
+
+// Get returns a copy of health under lock.
+func (h *EspHealth) Get() EspHealthValue {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	return h.value
+}
+
+func (h *EspHealth) SetRtt(rtt int64) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.value.Rtt = rtt
+}
+
+// Reset sets status without counting it as a change.
+func (h *EspHealth) Reset(status string) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.value.Status = status
+}
+
+// SetStatus returns true if status is changed.
+func (h *EspHealth) SetStatus(status string) bool {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	v := &h.value
+	if v.Status == status {
+		return false
+	}
+	if v.ChangeTime > 0 {
+		v.Flaps++
+	}
+	v.Status = status
+	v.ChangeTime = time.Now().Unix()
+	return true
 }
 
 func (l *EspState) Update() {
@@ -58,6 +111,8 @@ func (l *EspState) Update() {
 	if xss, err := nl.XfrmStateGet(xs); xss != nil {
 		l.RxBytes = int64(xss.Statistics.Bytes)
 		l.RxPackages = int64(xss.Statistics.Packets)
+		l.Replay = int64(xss.Statistics.Replay)
+		l.Failed = int64(xss.Statistics.Failed)
 	} else {
 		libol.Debug("EspState.Update %s", err)
 	}
@@ -86,6 +141,21 @@ func NewEspStateSchema(e *EspState) schema.EspState {
 		RxBytes:    e.RxBytes,
 		RxPackages: e.RxPackages,
 		AliveTime:  e.AliveTime,
+		NextRekey:  e.NextRekey,
+		Replay:     e.Replay,
+		Failed:     e.Failed,
+	}
+	if e.KeyTime > 0 {
+		se.KeyAge = time.Now().Unix() - e.KeyTime
+	}
+	if e.Health != nil {
+		h := e.Health.Get()
+		se.Status = h.Status
+		se.Rtt = h.Rtt
+		se.Flaps = h.Flaps
+		if h.ChangeTime > 0 {
+			se.StatusTime = time.Now().Unix() - h.ChangeTime
+		}
 	}
 	return se
 }
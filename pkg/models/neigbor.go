@@ -1,12 +1,15 @@
 package models
 
 import (
+	"bytes"
 	"github.com/danieldin95/openlan/pkg/libol"
 	"net"
+	"sync"
 	"time"
 )
 
 type Neighbor struct {
+	lock    sync.RWMutex
 	Network string           `json:"network"`
 	Device  string           `json:"device"`
 	Client  string           `json:"client"`
@@ -14,9 +17,13 @@ type Neighbor struct {
 	IpAddr  net.IP           `json:"ipAddr"`
 	NewTime int64            `json:"newTime"`
 	HitTime int64            `json:"hitTime"`
+	// claimed by others until, and not proxied.
+	Conflict int64 `json:"conflict,omitempty"`
 }
 
 func (e *Neighbor) String() string {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
 	str := e.HwAddr.String()
 	str += ":" + e.IpAddr.String()
 	str += ":" + e.Client
@@ -31,15 +38,55 @@ func NewNeighbor(hwAddr net.HardwareAddr, ipAddr net.IP, client libol.SocketClie
 		NewTime: time.Now().Unix(),
 		HitTime: time.Now().Unix(),
 	}
-	e.Update(client)
+	e.update(client)
 	return
 }
 
+func (e *Neighbor) InConflict() bool {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+	return time.Now().Unix() < e.Conflict
+}
+
+// SetConflict marks neighbor claimed by others until.
+func (e *Neighbor) SetConflict(until int64) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.Conflict = until
+}
+
 func (e *Neighbor) UpTime() int64 {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
 	return time.Now().Unix() - e.HitTime
 }
 
+// Owner returns hardware address, client and network of neighbor.
+func (e *Neighbor) Owner() (net.HardwareAddr, string, string) {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+	return e.HwAddr, e.Client, e.Network
+}
+
+// Hit refreshes neighbor by client, and conflict is cleared if moved.
+func (e *Neighbor) Hit(hwAddr net.HardwareAddr, client libol.SocketClient) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.Client != client.String() || !bytes.Equal(e.HwAddr, hwAddr) {
+		e.Conflict = 0
+	}
+	e.update(client)
+	e.HwAddr = hwAddr
+	e.HitTime = time.Now().Unix()
+}
+
 func (e *Neighbor) Update(client libol.SocketClient) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.update(client)
+}
+
+func (e *Neighbor) update(client libol.SocketClient) {
 	if client == nil {
 		return
 	}
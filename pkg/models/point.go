@@ -3,21 +3,27 @@ package models
 import (
 	"github.com/danieldin95/openlan/pkg/libol"
 	"github.com/danieldin95/openlan/pkg/network"
+	"sync"
 )
 
 type Point struct {
-	UUID     string             `json:"uuid"`
-	Alias    string             `json:"alias"`
-	Network  string             `json:"network"`
-	User     string             `json:"user"`
-	Protocol string             `json:"protocol"`
-	Server   string             `json:"server"`
-	Uptime   int64              `json:"uptime"`
-	Status   string             `json:"status"`
-	IfName   string             `json:"device"`
-	Client   libol.SocketClient `json:"-"`
-	Device   network.Taper      `json:"-"`
-	System   string             `json:"system"`
+	UUID      string             `json:"uuid"`
+	Alias     string             `json:"alias"`
+	Network   string             `json:"network"`
+	User      string             `json:"user"`
+	Protocol  string             `json:"protocol"`
+	Server    string             `json:"server"`
+	Uptime    int64              `json:"uptime"`
+	Status    string             `json:"status"`
+	IfName    string             `json:"device"`
+	Client    libol.SocketClient `json:"-"`
+	Device    network.Taper      `json:"-"`
+	System    string             `json:"system"`
+	candidate *Candidate
+	Bond      string `json:"bond,omitempty"`
+	Mtu       int    `json:"mtu,omitempty"` // effective mtu of path.
+	bundle    *libol.Bundle
+	lock      sync.RWMutex
 }
 
 func NewPoint(c libol.SocketClient, d network.Taper, proto string) (w *Point) {
@@ -45,12 +51,67 @@ func (p *Point) Update() *Point {
 
 func (p *Point) SetUser(user *User) {
 	p.User = user.Name
-	p.UUID = user.UUID
-	if len(p.UUID) > 13 {
-		// too long and using short uuid.
-		p.UUID = p.UUID[:13]
-	}
+	p.UUID = user.ShortUUID()
 	p.Network = user.Network
 	p.System = user.System
 	p.Alias = user.Alias
 }
+
+// SetCandidate replaces candidate, and keeps public address learned by
+// punching if it's the same token.
+func (p *Point) SetCandidate(c *Candidate) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if old := p.candidate; old != nil && old.Token == c.Token {
+		c.Public = old.Public
+	}
+	p.candidate = c
+}
+
+// Candidate returns a snapshot, and it's not modified after.
+func (p *Point) Candidate() *Candidate {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.candidate
+}
+
+// Punched updates public address if token is right, and returns
+// whether it's changed.
+func (p *Point) Punched(token, public string) (bool, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	old := p.candidate
+	if old == nil {
+		return false, libol.NewErr("candidate notFound")
+	}
+	if old.Token != token {
+		return false, libol.NewErr("wrong token")
+	}
+	if old.Public == public {
+		return false, nil
+	}
+	c := *old
+	c.Public = public
+	p.candidate = &c
+	return true, nil
+}
+
+func (p *Point) SetBundle(b *libol.Bundle) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.bundle = b
+}
+
+func (p *Point) Bundle() *libol.Bundle {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.bundle
+}
+
+// Clients returns all connections of this point.
+func (p *Point) Clients() []libol.SocketClient {
+	if b := p.Bundle(); b != nil {
+		return b.Members()
+	}
+	return []libol.SocketClient{p.Client}
+}
@@ -3,12 +3,13 @@ package models
 import (
 	"github.com/danieldin95/openlan/pkg/libol"
 	"github.com/danieldin95/openlan/pkg/schema"
+	"time"
 )
 
 func NewPointSchema(p *Point) schema.Point {
 	client, dev := p.Client, p.Device
 	sts := client.Statistics()
-	return schema.Point{
+	sp := schema.Point{
 		Uptime:    p.Uptime,
 		UUID:      p.UUID,
 		Alias:     p.Alias,
@@ -23,7 +24,28 @@ func NewPointSchema(p *Point) schema.Point {
 		Network:   p.Network,
 		AliveTime: client.AliveTime(),
 		System:    p.System,
+		Mtu:       p.Mtu,
 	}
+	if c := p.Candidate(); c != nil {
+		sp.Direct = NewDirectSchema(c.Paths)
+	}
+	return sp
+}
+
+func NewDirectSchema(paths []*DirectPath) []schema.DirectPath {
+	sd := make([]schema.DirectPath, 0, len(paths))
+	for _, p := range paths {
+		sd = append(sd, schema.DirectPath{
+			Peer:      p.Peer,
+			Alias:     p.Alias,
+			Endpoint:  p.Endpoint,
+			State:     p.State,
+			RxBytes:   p.RxBytes,
+			TxBytes:   p.TxBytes,
+			AliveTime: p.AliveTime,
+		})
+	}
+	return sd
 }
 
 func NewLinkSchema(l *Link) schema.Link {
@@ -45,8 +67,10 @@ func NewLinkSchema(l *Link) schema.Link {
 }
 
 func NewNeighborSchema(n *Neighbor) schema.Neighbor {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
 	return schema.Neighbor{
-		Uptime:  n.UpTime(),
+		Uptime:  time.Now().Unix() - n.HitTime,
 		HwAddr:  n.HwAddr.String(),
 		IpAddr:  n.IpAddr.String(),
 		Client:  n.Client,
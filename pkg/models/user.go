@@ -18,6 +18,11 @@ type User struct {
 	Role     string             `json:"type"` // admin , guest or ldap
 	Last     libol.SocketClient `json:"last"` // lastly accessed by this.
 	Lease    time.Time          `json:"leastTime"`
+	Version  int                `json:"version,omitempty"`
+	Features []string           `json:"features,omitempty"`
+	Bond     string             `json:"bond,omitempty"` // id of bond session.
+	BondMode string             `json:"bondMode,omitempty"`
+	Session  string             `json:"session,omitempty"` // to roam, as <id>:<key>.
 	UpdateAt int64
 }
 
@@ -55,6 +60,14 @@ func (u *User) Update() {
 	u.UpdateAt = time.Now().Unix()
 }
 
+func (u *User) ShortUUID() string {
+	if len(u.UUID) > 13 {
+		// too long and using short uuid.
+		return u.UUID[:13]
+	}
+	return u.UUID
+}
+
 func (u *User) Id() string {
 	return u.Name + "@" + u.Network
 }
@@ -5,35 +5,58 @@ import (
 	"github.com/danieldin95/openlan/pkg/libol"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+const (
+	FlapWindow = 10 // in seconds.
+	FlapLimit  = 5  // moves in a window.
+)
+
 type VirtualBridge struct {
-	ipMtu   int
-	name    string
-	lock    sync.RWMutex
-	ports   map[string]Taper
-	macs    map[string]*MacFdb
-	done    chan bool
-	ticker  *time.Ticker
-	timeout int
-	address string
-	kernel  Taper
-	out     *libol.SubLogger
-	sts     DeviceStats
+	ipMtu     int
+	name      string
+	lock      sync.RWMutex
+	ports     map[string]Taper
+	macs      map[string]*MacFdb
+	done      chan bool
+	ticker    *time.Ticker
+	hello     *time.Ticker
+	timeout   int
+	address   string
+	kernel    Taper
+	out       *libol.SubLogger
+	sts       DeviceStats
+	stp       *Rstp
+	flapLimit int
+	flapBlock int             // seconds to block port, and zero not.
+	blocks    map[Taper]int64 // blocked until.
+	nblock    int32           // size of blocks for data path.
+	storm     *StormControl
+	snoop     *Snooping
+	portSts   map[Taper]*PortStats
 }
 
 func NewVirtualBridge(name string, mtu int) *VirtualBridge {
 	b := &VirtualBridge{
-		name:    name,
-		ipMtu:   mtu,
-		ports:   make(map[string]Taper, 1024),
-		macs:    make(map[string]*MacFdb, 1024),
-		done:    make(chan bool),
-		ticker:  time.NewTicker(5 * time.Second),
-		timeout: 5 * 60,
-		out:     libol.NewSubLogger(name),
+		name:      name,
+		ipMtu:     mtu,
+		ports:     make(map[string]Taper, 1024),
+		macs:      make(map[string]*MacFdb, 1024),
+		done:      make(chan bool),
+		ticker:    time.NewTicker(5 * time.Second),
+		hello:     time.NewTicker(time.Second),
+		timeout:   5 * 60,
+		out:       libol.NewSubLogger(name),
+		flapLimit: FlapLimit,
+		blocks:    make(map[Taper]int64, 32),
+		storm:     NewStormControl(),
+		snoop:     NewSnooping(),
+		portSts:   make(map[Taper]*PortStats, 32),
 	}
+	b.stp = NewRstp(libol.GenEthAddr(6), b.out)
+	b.stp.onFlush = b.Flush
 	Bridges.Add(b)
 	return b
 }
@@ -82,6 +105,7 @@ func (b *VirtualBridge) Close() error {
 		_ = b.kernel.Close()
 	}
 	b.ticker.Stop()
+	b.hello.Stop()
 	b.done <- true
 	return nil
 }
@@ -95,10 +119,14 @@ func (b *VirtualBridge) AddSlave(name string) error {
 	b.lock.Lock()
 	b.ports[name] = tap
 	b.lock.Unlock()
+	// ports are edge until bpdu received, so a point forwards at once.
+	b.stp.AddPort(tap, true)
 	b.out.Info("VirtualBridge.AddSlave: %s", name)
 	libol.Go(func() {
+		// data is reused, as output of port copies it.
+		data := make([]byte, b.ipMtu)
+		m := &Framer{Source: tap}
 		for {
-			data := make([]byte, b.ipMtu)
 			n, err := tap.Recv(data)
 			if err != nil || n == 0 {
 				break
@@ -106,7 +134,7 @@ func (b *VirtualBridge) AddSlave(name string) error {
 			if libol.HasLog(libol.DEBUG) {
 				libol.Debug("VirtualBridge.KernelTap: %s % x", tap.Name(), data[:20])
 			}
-			m := &Framer{Data: data[:n], Source: tap}
+			m.Data = data[:n]
 			_ = b.Input(m)
 		}
 	})
@@ -116,8 +144,14 @@ func (b *VirtualBridge) AddSlave(name string) error {
 func (b *VirtualBridge) DelSlave(name string) error {
 	b.lock.Lock()
 	defer b.lock.Unlock()
-	if _, ok := b.ports[name]; ok {
+	if tap, ok := b.ports[name]; ok {
 		delete(b.ports, name)
+		delete(b.blocks, tap)
+		atomic.StoreInt32(&b.nblock, int32(len(b.blocks)))
+		delete(b.portSts, tap)
+		b.stp.DelPort(tap)
+		b.storm.Del(tap)
+		b.snoop.Del(tap)
 	}
 	b.out.Info("VirtualBridge.DelSlave: %s", name)
 	return nil
@@ -149,10 +183,33 @@ func (b *VirtualBridge) Name() string {
 }
 
 func (b *VirtualBridge) Forward(m *Framer) error {
-	if err := b.UniCast(m); err != nil {
-		_ = b.Flood(m)
+	if err := b.UniCast(m); err == nil {
+		return nil
+	}
+	if !b.storm.Allow(m.Source, time.Now()) {
+		b.drop(m.Source, true)
+		return nil
+	}
+	if outs, ok := b.snoop.Lookup(m); ok {
+		return b.Multicast(m, outs)
+	}
+	return b.Flood(m)
+}
+
+// drop counts a frame dropped from port.
+func (b *VirtualBridge) drop(port Taper, storm bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.sts.Drop++
+	sts, ok := b.portSts[port]
+	if !ok {
+		sts = &PortStats{}
+		b.portSts[port] = sts
+	}
+	sts.Drop++
+	if storm {
+		sts.Storm++
 	}
-	return nil
 }
 
 func (b *VirtualBridge) Expire() error {
@@ -175,10 +232,27 @@ func (b *VirtualBridge) Expire() error {
 			b.out.Event("VirtualBridge.Expire: delete %s", d)
 		}
 	}
+	now := time.Now().Unix()
+	b.snoop.Expire(now)
+	for port, until := range b.blocks {
+		if now >= until {
+			delete(b.blocks, port)
+			b.out.Event("VirtualBridge.Expire: unblock %s", port)
+		}
+	}
+	atomic.StoreInt32(&b.nblock, int32(len(b.blocks)))
 	b.lock.Unlock()
 	return nil
 }
 
+// Flush deletes all learned macs when topology changed.
+func (b *VirtualBridge) Flush() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.out.Event("VirtualBridge.Flush: %d", len(b.macs))
+	b.macs = make(map[string]*MacFdb, 1024)
+}
+
 func (b *VirtualBridge) Start() {
 	libol.Go(func() {
 		for {
@@ -188,6 +262,8 @@ func (b *VirtualBridge) Start() {
 			case t := <-b.ticker.C:
 				b.out.Log("VirtualBridge.Start: Tick at %s", t)
 				_ = b.Expire()
+			case t := <-b.hello.C:
+				b.stp.Tick(t)
 			}
 		}
 	})
@@ -195,10 +271,50 @@ func (b *VirtualBridge) Start() {
 
 func (b *VirtualBridge) Input(m *Framer) error {
 	b.sts.Recv++
+	if IsBpdu(m.Data) && b.stp.Enabled() {
+		if err := b.stp.Recv(m.Source, m.Data); err != nil {
+			b.out.Debug("VirtualBridge.Input: %s %s", m.Source, err)
+		}
+		return nil
+	}
+	if b.isBlocked(m.Source) || !b.stp.Learning(m.Source) {
+		b.drop(m.Source, false)
+		return nil
+	}
 	b.Learn(m)
+	if !b.stp.Forwarding(m.Source) {
+		b.drop(m.Source, false)
+		return nil
+	}
 	return b.Forward(m)
 }
 
+func (b *VirtualBridge) isBlocked(port Taper) bool {
+	if atomic.LoadInt32(&b.nblock) == 0 {
+		return false
+	}
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	until, ok := b.blocks[port]
+	return ok && time.Now().Unix() < until
+}
+
+// isOutput returns true if port is able to send.
+func (b *VirtualBridge) isOutput(port Taper) bool {
+	return !b.isBlocked(port) && b.stp.Forwarding(port)
+}
+
+// SetFlap configures moves of a mac in a window as flapping, and
+// seconds to block the port which mac moved to.
+func (b *VirtualBridge) SetFlap(limit, block int) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if limit > 0 {
+		b.flapLimit = limit
+	}
+	b.flapBlock = block
+}
+
 func (b *VirtualBridge) Eth2Str(addr []byte) string {
 	if len(addr) < 6 {
 		return ""
@@ -243,14 +359,37 @@ func (b *VirtualBridge) AddMac(mac string, fdb *MacFdb) {
 }
 
 func (b *VirtualBridge) UpdateMac(mac string, device Taper) {
-	b.lock.RLock()
-	defer b.lock.RUnlock()
+	b.lock.Lock()
+	defer b.lock.Unlock()
 	if fdb, ok := b.macs[mac]; ok {
-		fdb.Uptime = time.Now().Unix()
+		now := time.Now().Unix()
+		if fdb.Device != device {
+			b.flap(mac, fdb, device, now)
+		}
+		fdb.Uptime = now
 		fdb.Device = device
 	}
 }
 
+// flap must be called with lock.
+func (b *VirtualBridge) flap(mac string, fdb *MacFdb, device Taper, now int64) {
+	if now-fdb.FlapTime > FlapWindow {
+		fdb.FlapTime = now
+		fdb.Flaps = 0
+	}
+	fdb.Flaps++
+	if fdb.Flaps < b.flapLimit {
+		return
+	}
+	b.out.Event("VirtualBridge.Learn: %s flapping between %s and %s", mac, fdb.Device, device)
+	fdb.Flaps = 0
+	if b.flapBlock > 0 {
+		b.out.Warn("VirtualBridge.Learn: block %s for %ds", device, b.flapBlock)
+		b.blocks[device] = now + int64(b.flapBlock)
+		atomic.StoreInt32(&b.nblock, int32(len(b.blocks)))
+	}
+}
+
 func (b *VirtualBridge) ListMac() <-chan *MacFdb {
 	data := make(chan *MacFdb, 32)
 	go func() {
@@ -279,6 +418,9 @@ func (b *VirtualBridge) Flood(m *Framer) error {
 	}
 	b.lock.RUnlock()
 	for _, port := range outs {
+		if !b.isOutput(port) {
+			continue
+		}
 		if b.out.Has(libol.FLOW) {
 			b.out.Flow("VirtualBridge.Flood: %s % x", port, data[:20])
 		}
@@ -290,6 +432,24 @@ func (b *VirtualBridge) Flood(m *Framer) error {
 	return nil
 }
 
+// Multicast sends group traffic to ports joined.
+func (b *VirtualBridge) Multicast(m *Framer, outs []Taper) error {
+	data := m.Data
+	for _, port := range outs {
+		if !b.isOutput(port) {
+			continue
+		}
+		if b.out.Has(libol.FLOW) {
+			b.out.Flow("VirtualBridge.Multicast: %s % x", port, data[:20])
+		}
+		b.sts.Send++
+		if _, err := port.Send(data); err != nil {
+			b.out.Error("VirtualBridge.Multicast: %s %s", port, err)
+		}
+	}
+	return nil
+}
+
 func (b *VirtualBridge) UniCast(m *Framer) error {
 	data := m.Data
 	from := m.Source
@@ -299,13 +459,13 @@ func (b *VirtualBridge) UniCast(m *Framer) error {
 		return errors.New(dest + " notFound")
 	}
 	out := learn.Device
-	if out != from && out.Has(UsUp) { // out should running
+	if out != from && out.Has(UsUp) && b.isOutput(out) { // out should running
 		b.sts.Send++
 		if _, err := out.Send(data); err != nil {
 			b.out.Warn("VirtualBridge.UniCast: %s %s", out, err)
 		}
 	} else {
-		b.sts.Drop++
+		b.drop(from, false)
 	}
 	if b.out.Has(libol.FLOW) {
 		b.out.Flow("VirtualBridge.UniCast: %s to %s % x", from, out, data[:20])
@@ -318,15 +478,42 @@ func (b *VirtualBridge) Mtu() int {
 }
 
 func (b *VirtualBridge) Stp(enable bool) error {
-	return libol.NewErr("operation notSupport")
+	b.stp.Enable(enable)
+	return nil
 }
 
 func (b *VirtualBridge) Delay(value int) error {
-	return libol.NewErr("operation notSupport")
+	b.stp.SetDelay(value)
+	return nil
+}
+
+func (b *VirtualBridge) ListStp() []StpPort {
+	return b.stp.ListPort()
+}
+
+// SetStorm limits broadcast, multicast and unknown unicast received
+// from each port in packets per second, and zero is unlimited.
+func (b *VirtualBridge) SetStorm(pps int) {
+	b.storm.SetRate(pps)
+}
+
+func (b *VirtualBridge) Snooping(enable bool) {
+	b.snoop.Enable(enable)
+}
+
+func (b *VirtualBridge) ListGroup() []McastGroup {
+	return b.snoop.ListGroup()
 }
 
 func (b *VirtualBridge) Stats() DeviceStats {
-	return b.sts
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	sts := b.sts
+	sts.Ports = make(map[string]PortStats, len(b.portSts))
+	for port, obj := range b.portSts {
+		sts.Ports[port.Name()] = *obj
+	}
+	return sts
 }
 
 func (b *VirtualBridge) CallIptables(value int) error {
@@ -11,10 +11,12 @@ const (
 )
 
 type MacFdb struct {
-	Address []byte
-	Device  Taper
-	Uptime  int64
-	NewTime int64
+	Address  []byte
+	Device   Taper
+	Uptime   int64
+	NewTime  int64
+	Flaps    int   // moves in this window.
+	FlapTime int64 // start of window.
 }
 
 type Bridger interface {
@@ -140,6 +140,14 @@ func (f *FireWall) Start() {
 	})
 }
 
+// Install installs rules added after started, and existed rules are
+// skipped.
+func (f *FireWall) Install() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.install()
+}
+
 func (f *FireWall) uninstall() {
 	for _, rule := range f.rules {
 		if ret, err := rule.Opr("-D"); err != nil {
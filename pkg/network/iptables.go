@@ -41,6 +41,7 @@ type IpRule struct {
 	Order    string
 	Match    string
 	TcpFlag  []string
+	Policy   string // match inbound by ipsec or none.
 }
 
 type IpRules []IpRule
@@ -77,6 +78,9 @@ func (ru IpRule) Args() []string {
 			args = append(args, "--dport", ru.DstPort)
 		}
 	}
+	if ru.Policy != "" {
+		args = append(args, "-m", "policy", "--dir", "in", "--pol", ru.Policy)
+	}
 	if ru.Input != "" {
 		args = append(args, "-i", ru.Input)
 	}
@@ -136,6 +140,9 @@ func (ru IpRule) Eq(obj IpRule) bool {
 	if ru.DstPort != obj.DstPort {
 		return false
 	}
+	if ru.Policy != obj.Policy {
+		return false
+	}
 	return true
 }
 
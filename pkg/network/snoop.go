@@ -0,0 +1,284 @@
+package network
+
+import (
+	"encoding/binary"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	IgmpQuery    = 0x11
+	IgmpV1Report = 0x12
+	IgmpV2Report = 0x16
+	IgmpLeave    = 0x17
+	IgmpV3Report = 0x22
+	MldQuery     = 130
+	MldV1Report  = 131
+	MldDone      = 132
+	MldV2Report  = 143
+)
+
+const (
+	SnoopTimeout = 260 // in seconds.
+	SnoopLeave   = 5   // wait reports of others after leave.
+)
+
+// McastGroup is a group and ports joined.
+type McastGroup struct {
+	Address string
+	Ports   map[Taper]int64 // expired at.
+}
+
+// Snooping learns members of multicast groups by igmp and mld, and
+// group traffic goes only to ports joined and routers.
+type Snooping struct {
+	lock    sync.RWMutex
+	enable  bool
+	groups  map[string]*McastGroup
+	routers map[Taper]int64 // ports received query.
+}
+
+func NewSnooping() *Snooping {
+	return &Snooping{
+		groups:  make(map[string]*McastGroup, 32),
+		routers: make(map[Taper]int64, 32),
+	}
+}
+
+func (s *Snooping) Enable(enable bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.enable = enable
+	s.groups = make(map[string]*McastGroup, 32)
+	s.routers = make(map[Taper]int64, 32)
+}
+
+func (s *Snooping) join(group net.IP, port Taper, timeout int64) {
+	key := group.String()
+	g, ok := s.groups[key]
+	if !ok {
+		g = &McastGroup{Address: key, Ports: make(map[Taper]int64, 4)}
+		s.groups[key] = g
+	}
+	g.Ports[port] = time.Now().Unix() + timeout
+}
+
+func (s *Snooping) leave(group net.IP, port Taper) {
+	if g, ok := s.groups[group.String()]; ok {
+		if _, ok := g.Ports[port]; ok {
+			g.Ports[port] = time.Now().Unix() + SnoopLeave
+		}
+	}
+}
+
+// record handles a group record of v3 report.
+func (s *Snooping) record(typ uint8, nSrc int, group net.IP, port Taper) {
+	// to include with no sources is leaving.
+	if (typ == 1 || typ == 3) && nSrc == 0 {
+		s.leave(group, port)
+	} else {
+		s.join(group, port, SnoopTimeout)
+	}
+}
+
+func (s *Snooping) igmp(data []byte, port Taper) {
+	if len(data) < 8 {
+		return
+	}
+	switch data[0] {
+	case IgmpQuery:
+		s.routers[port] = time.Now().Unix() + SnoopTimeout
+	case IgmpV1Report, IgmpV2Report:
+		s.join(net.IP(data[4:8]), port, SnoopTimeout)
+	case IgmpLeave:
+		s.leave(net.IP(data[4:8]), port)
+	case IgmpV3Report:
+		num := int(binary.BigEndian.Uint16(data[6:8]))
+		offset := 8
+		for i := 0; i < num && offset+8 <= len(data); i++ {
+			nSrc := int(binary.BigEndian.Uint16(data[offset+2 : offset+4]))
+			s.record(data[offset], nSrc, net.IP(data[offset+4:offset+8]), port)
+			offset += 8 + nSrc*4 + int(data[offset+1])*4
+		}
+	}
+}
+
+func (s *Snooping) mld(data []byte, port Taper) {
+	if len(data) < 24 {
+		return
+	}
+	switch data[0] {
+	case MldQuery:
+		s.routers[port] = time.Now().Unix() + SnoopTimeout
+	case MldV1Report:
+		s.join(net.IP(data[8:24]), port, SnoopTimeout)
+	case MldDone:
+		s.leave(net.IP(data[8:24]), port)
+	case MldV2Report:
+		num := int(binary.BigEndian.Uint16(data[6:8]))
+		offset := 8
+		for i := 0; i < num && offset+20 <= len(data); i++ {
+			nSrc := int(binary.BigEndian.Uint16(data[offset+2 : offset+4]))
+			s.record(data[offset], nSrc, net.IP(data[offset+4:offset+20]), port)
+			offset += 20 + nSrc*16 + int(data[offset+1])*4
+		}
+	}
+}
+
+// parse returns the group of frame, and true if igmp or mld.
+func (s *Snooping) parse(frame []byte) (net.IP, []byte, bool) {
+	if len(frame) < 14 {
+		return nil, nil, false
+	}
+	switch binary.BigEndian.Uint16(frame[12:14]) {
+	case 0x0800:
+		ip := frame[14:]
+		if len(ip) < 20 {
+			return nil, nil, false
+		}
+		hl := int(ip[0]&0x0f) * 4
+		if len(ip) < hl {
+			return nil, nil, false
+		}
+		return net.IP(ip[16:20]), ip[hl:], ip[9] == 2
+	case 0x86dd:
+		ip := frame[14:]
+		if len(ip) < 40 {
+			return nil, nil, false
+		}
+		next := ip[6]
+		data := ip[40:]
+		// skip hop by hop with router alert.
+		if next == 0 && len(data) >= 8 {
+			size := (int(data[1]) + 1) * 8
+			if len(data) < size {
+				return nil, nil, false
+			}
+			next = data[0]
+			data = data[size:]
+		}
+		return net.IP(ip[24:40]), data, next == 58 && isMld(data)
+	}
+	return nil, nil, false
+}
+
+func isMld(data []byte) bool {
+	if len(data) == 0 {
+		return false
+	}
+	typ := data[0]
+	return (typ >= MldQuery && typ <= MldDone) || typ == MldV2Report
+}
+
+func isLinkLocal(group net.IP) bool {
+	if ip4 := group.To4(); ip4 != nil {
+		return ip4[0] == 224 && ip4[1] == 0 && ip4[2] == 0
+	}
+	return len(group) == 16 && group[0] == 0xff && group[1]&0x0f <= 2
+}
+
+func (s *Snooping) hasQuerier(now int64) bool {
+	for _, expired := range s.routers {
+		if now < expired {
+			return true
+		}
+	}
+	return false
+}
+
+// Lookup learns from igmp or mld, and returns ports should be sent to
+// for group traffic. False is returned if the frame should be flooded,
+// and group traffic is flooded while no querier is present.
+func (s *Snooping) Lookup(m *Framer) ([]Taper, bool) {
+	data := m.Data
+	if len(data) < 14 || data[0]&0x01 == 0 || data[0] == 0xff {
+		return nil, false
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !s.enable {
+		return nil, false
+	}
+	group, payload, control := s.parse(data)
+	if group == nil {
+		return nil, false
+	}
+	if control {
+		if group.To4() != nil {
+			s.igmp(payload, m.Source)
+		} else {
+			s.mld(payload, m.Source)
+		}
+		return nil, false
+	}
+	if !group.IsMulticast() || isLinkLocal(group) {
+		return nil, false
+	}
+	now := time.Now().Unix()
+	// reports are not refreshed without querier, so flood as others.
+	if !s.hasQuerier(now) {
+		return nil, false
+	}
+	ports := make(map[Taper]bool, 8)
+	for port, expired := range s.routers {
+		if port != m.Source && now < expired {
+			ports[port] = true
+		}
+	}
+	if g, ok := s.groups[group.String()]; ok {
+		for port, expired := range g.Ports {
+			if port != m.Source && now < expired {
+				ports[port] = true
+			}
+		}
+	}
+	outs := make([]Taper, 0, len(ports))
+	for port := range ports {
+		outs = append(outs, port)
+	}
+	return outs, true
+}
+
+func (s *Snooping) Expire(now int64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for port, expired := range s.routers {
+		if now >= expired {
+			delete(s.routers, port)
+		}
+	}
+	for key, g := range s.groups {
+		for port, expired := range g.Ports {
+			if now >= expired {
+				delete(g.Ports, port)
+			}
+		}
+		if len(g.Ports) == 0 {
+			delete(s.groups, key)
+		}
+	}
+}
+
+func (s *Snooping) Del(port Taper) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.routers, port)
+	for _, g := range s.groups {
+		delete(g.Ports, port)
+	}
+}
+
+func (s *Snooping) ListGroup() []McastGroup {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	groups := make([]McastGroup, 0, len(s.groups))
+	for _, g := range s.groups {
+		obj := McastGroup{Address: g.Address, Ports: make(map[Taper]int64, len(g.Ports))}
+		for port, expired := range g.Ports {
+			obj.Ports[port] = expired
+		}
+		groups = append(groups, obj)
+	}
+	return groups
+}
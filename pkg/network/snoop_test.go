@@ -0,0 +1,74 @@
+package network
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+func newIgmpFrame(typ uint8, group net.IP) []byte {
+	frame := make([]byte, 14+20+8)
+	copy(frame[0:6], []byte{0x01, 0x00, 0x5e, 0x01, 0x01, 0x01})
+	frame[12], frame[13] = 0x08, 0x00
+	ip := frame[14:]
+	ip[0] = 0x45
+	ip[9] = 2
+	copy(ip[16:20], group.To4())
+	ip[20] = typ
+	copy(ip[24:28], group.To4())
+	return frame
+}
+
+func newUdpFrame(group net.IP) []byte {
+	frame := newIgmpFrame(0, group)
+	frame[14+9] = 17
+	return frame
+}
+
+func TestSnooping_Lookup(t *testing.T) {
+	snoop := NewSnooping()
+	snoop.Enable(true)
+	dev01, _ := NewVirtualTap("", TapConfig{})
+	dev02, _ := NewVirtualTap("", TapConfig{})
+	dev03, _ := NewVirtualTap("", TapConfig{})
+	group := net.ParseIP("239.1.1.1")
+	// no querier and flooded.
+	_, ok := snoop.Lookup(&Framer{Data: newUdpFrame(group), Source: dev02})
+	assert.Equal(t, false, ok, "be the same.")
+	// query and only sent to querier.
+	_, ok = snoop.Lookup(&Framer{Data: newIgmpFrame(IgmpQuery, net.IPv4zero), Source: dev03})
+	assert.Equal(t, false, ok, "be the same.")
+	outs, ok := snoop.Lookup(&Framer{Data: newUdpFrame(group), Source: dev02})
+	assert.Equal(t, true, ok, "be the same.")
+	assert.Equal(t, []Taper{dev03}, outs, "be the same.")
+	// report and flooded.
+	_, ok = snoop.Lookup(&Framer{Data: newIgmpFrame(IgmpV2Report, group), Source: dev01})
+	assert.Equal(t, false, ok, "be the same.")
+	outs, ok = snoop.Lookup(&Framer{Data: newUdpFrame(group), Source: dev02})
+	assert.Equal(t, true, ok, "be the same.")
+	assert.ElementsMatch(t, []Taper{dev01, dev03}, outs, "be the same.")
+	// link local always flooded.
+	_, ok = snoop.Lookup(&Framer{Data: newUdpFrame(net.ParseIP("224.0.0.251")), Source: dev02})
+	assert.Equal(t, false, ok, "be the same.")
+	// expired after leave.
+	_, _ = snoop.Lookup(&Framer{Data: newIgmpFrame(IgmpLeave, group), Source: dev01})
+	snoop.Expire(time.Now().Unix() + SnoopLeave)
+	outs, _ = snoop.Lookup(&Framer{Data: newUdpFrame(group), Source: dev02})
+	assert.Equal(t, []Taper{dev03}, outs, "be the same.")
+	// querier expired and flooded again.
+	snoop.Expire(time.Now().Unix() + SnoopTimeout)
+	_, ok = snoop.Lookup(&Framer{Data: newUdpFrame(group), Source: dev02})
+	assert.Equal(t, false, ok, "be the same.")
+}
+
+func TestStormControl_Allow(t *testing.T) {
+	storm := NewStormControl()
+	storm.SetRate(2)
+	dev01, _ := NewVirtualTap("", TapConfig{})
+	now := time.Now()
+	assert.Equal(t, true, storm.Allow(dev01, now), "be the same.")
+	assert.Equal(t, true, storm.Allow(dev01, now), "be the same.")
+	assert.Equal(t, false, storm.Allow(dev01, now), "be the same.")
+	assert.Equal(t, true, storm.Allow(dev01, now.Add(time.Second)), "be the same.")
+}
@@ -0,0 +1,61 @@
+package network
+
+import (
+	"sync"
+	"time"
+)
+
+type stormBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// StormControl limits broadcast, multicast and unknown unicast
+// received from a port by token bucket in packets per second.
+type StormControl struct {
+	lock    sync.Mutex
+	rate    int // zero is unlimited.
+	buckets map[Taper]*stormBucket
+}
+
+func NewStormControl() *StormControl {
+	return &StormControl{
+		buckets: make(map[Taper]*stormBucket, 32),
+	}
+}
+
+func (s *StormControl) SetRate(pps int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.rate = pps
+	s.buckets = make(map[Taper]*stormBucket, 32)
+}
+
+func (s *StormControl) Allow(port Taper, now time.Time) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.rate <= 0 {
+		return true
+	}
+	b, ok := s.buckets[port]
+	if !ok {
+		b = &stormBucket{tokens: float64(s.rate), last: now}
+		s.buckets[port] = b
+	}
+	b.tokens += now.Sub(b.last).Seconds() * float64(s.rate)
+	if b.tokens > float64(s.rate) {
+		b.tokens = float64(s.rate)
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+func (s *StormControl) Del(port Taper) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.buckets, port)
+}
@@ -0,0 +1,551 @@
+package network
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"github.com/danieldin95/openlan/pkg/libol"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// A simple rstp of 802.1w for the virtual bridge, ports are treated as
+// point to point, and proposal and agreement is used to forward rapidly.
+// Ports are added as edge, and become not edge once bpdu received.
+
+var StpGroup = net.HardwareAddr{0x01, 0x80, 0xc2, 0x00, 0x00, 0x00}
+
+const (
+	StpRoleDisabled   = 0
+	StpRoleAlternate  = 1
+	StpRoleRoot       = 2
+	StpRoleDesignated = 3
+)
+
+const (
+	StpDiscarding = 0
+	StpLearning   = 1
+	StpForwarding = 2
+)
+
+const (
+	BpduTc          = 0x01
+	BpduProposal    = 0x02
+	BpduRoleShift   = 2
+	BpduRoleMask    = 0x0c
+	BpduLearning    = 0x10
+	BpduForwarding  = 0x20
+	BpduAgreement   = 0x40
+	BpduTcAck       = 0x80
+	BpduTypeConfig  = 0x00
+	BpduTypeRst     = 0x02
+	BpduSize        = 36
+	StpPriority     = 0x8000
+	StpPortPriority = 0x80
+	StpPortCost     = 20000
+)
+
+// StpVector is priority vector, and lower is better.
+type StpVector struct {
+	RootId   uint64
+	Cost     uint32
+	BridgeId uint64
+	PortId   uint16
+}
+
+func (v StpVector) Better(o StpVector) bool {
+	if v.RootId != o.RootId {
+		return v.RootId < o.RootId
+	}
+	if v.Cost != o.Cost {
+		return v.Cost < o.Cost
+	}
+	if v.BridgeId != o.BridgeId {
+		return v.BridgeId < o.BridgeId
+	}
+	return v.PortId < o.PortId
+}
+
+func (v StpVector) String() string {
+	return fmt.Sprintf("%016x:%d:%016x:%04x", v.RootId, v.Cost, v.BridgeId, v.PortId)
+}
+
+type Bpdu struct {
+	Type     uint8
+	Flags    uint8
+	Vector   StpVector
+	MaxAge   uint16 // in 1/256 seconds.
+	Hello    uint16
+	FwdDelay uint16
+}
+
+func (b *Bpdu) Role() int {
+	return int(b.Flags&BpduRoleMask) >> BpduRoleShift
+}
+
+// Encode returns an ethernet frame with llc from source.
+func (b *Bpdu) Encode(source []byte) []byte {
+	frame := make([]byte, 60)
+	copy(frame[0:6], StpGroup)
+	copy(frame[6:12], source)
+	binary.BigEndian.PutUint16(frame[12:14], 3+BpduSize)
+	copy(frame[14:17], []byte{0x42, 0x42, 0x03})
+	data := frame[17:]
+	// protocol is zero, and version 2 for rstp.
+	data[2] = 0x02
+	data[3] = BpduTypeRst
+	data[4] = b.Flags
+	binary.BigEndian.PutUint64(data[5:13], b.Vector.RootId)
+	binary.BigEndian.PutUint32(data[13:17], b.Vector.Cost)
+	binary.BigEndian.PutUint64(data[17:25], b.Vector.BridgeId)
+	binary.BigEndian.PutUint16(data[25:27], b.Vector.PortId)
+	binary.BigEndian.PutUint16(data[29:31], b.MaxAge)
+	binary.BigEndian.PutUint16(data[31:33], b.Hello)
+	binary.BigEndian.PutUint16(data[33:35], b.FwdDelay)
+	return frame
+}
+
+func IsBpdu(frame []byte) bool {
+	return len(frame) > 14 && bytes.Equal(frame[:6], StpGroup)
+}
+
+func DecodeBpdu(frame []byte) (*Bpdu, error) {
+	if len(frame) < 17+BpduSize-1 {
+		return nil, libol.NewErr("too short %d", len(frame))
+	}
+	if !bytes.Equal(frame[14:17], []byte{0x42, 0x42, 0x03}) {
+		return nil, libol.NewErr("not llc of stp")
+	}
+	data := frame[17:]
+	if data[0] != 0 || data[1] != 0 {
+		return nil, libol.NewErr("unknown protocol %x%x", data[0], data[1])
+	}
+	b := &Bpdu{Type: data[3], Flags: data[4]}
+	if b.Type != BpduTypeConfig && b.Type != BpduTypeRst {
+		return nil, libol.NewErr("unknown type %d", b.Type)
+	}
+	b.Vector.RootId = binary.BigEndian.Uint64(data[5:13])
+	b.Vector.Cost = binary.BigEndian.Uint32(data[13:17])
+	b.Vector.BridgeId = binary.BigEndian.Uint64(data[17:25])
+	b.Vector.PortId = binary.BigEndian.Uint16(data[25:27])
+	b.MaxAge = binary.BigEndian.Uint16(data[29:31])
+	b.Hello = binary.BigEndian.Uint16(data[31:33])
+	b.FwdDelay = binary.BigEndian.Uint16(data[33:35])
+	if b.Type == BpduTypeConfig {
+		// legacy stp has only tc, and acts as designated.
+		b.Flags = b.Flags&BpduTc | StpRoleDesignated<<BpduRoleShift
+	}
+	return b, nil
+}
+
+type StpPort struct {
+	Device    Taper
+	Id        uint16
+	Cost      uint32
+	Role      int
+	State     int
+	Edge      bool
+	info      *StpVector // received from designated port.
+	infoTime  time.Time
+	stateTime time.Time
+	agreed    bool
+	agree     bool      // send agreement once.
+	tcTime    time.Time // sending tc until.
+}
+
+func (p *StpPort) String() string {
+	return p.Device.Name()
+}
+
+type Rstp struct {
+	lock     sync.Mutex
+	enable   bool
+	enabled  int32        // copy of enable for data path.
+	states   atomic.Value // map[Taper]int copied for data path.
+	mac      []byte
+	bridgeId uint64
+	index    uint16
+	ports    map[Taper]*StpPort
+	root     StpVector
+	rootPort *StpPort
+	hello    time.Duration
+	delay    time.Duration
+	lastTime time.Time // of last hello.
+	flush    bool      // fdb should be flushed.
+	changed  bool      // states should be published.
+	out      *libol.SubLogger
+	onFlush  func()
+}
+
+func NewRstp(mac []byte, out *libol.SubLogger) *Rstp {
+	s := &Rstp{
+		mac:   mac,
+		ports: make(map[Taper]*StpPort, 32),
+		hello: 2 * time.Second,
+		delay: 15 * time.Second,
+		out:   out,
+	}
+	s.bridgeId = uint64(StpPriority)<<48 | uint64(mac[0])<<40 | uint64(mac[1])<<32 |
+		uint64(binary.BigEndian.Uint32(mac[2:6]))
+	s.root = StpVector{RootId: s.bridgeId, BridgeId: s.bridgeId}
+	s.states.Store(map[Taper]int{})
+	return s
+}
+
+func (s *Rstp) Enabled() bool {
+	return atomic.LoadInt32(&s.enabled) == 1
+}
+
+// publish copies states of ports for data path, and must be called
+// with lock after changed.
+func (s *Rstp) publish() {
+	states := make(map[Taper]int, len(s.ports))
+	for dev, p := range s.ports {
+		states[dev] = p.State
+	}
+	s.states.Store(states)
+	if s.enable {
+		atomic.StoreInt32(&s.enabled, 1)
+	} else {
+		atomic.StoreInt32(&s.enabled, 0)
+	}
+}
+
+func (s *Rstp) Enable(enable bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.enable = enable
+	now := time.Now()
+	for _, p := range s.ports {
+		p.info = nil
+		p.Role = StpRoleDisabled
+		p.State = StpForwarding
+		p.stateTime = now
+	}
+	s.rootPort = nil
+	s.root = StpVector{RootId: s.bridgeId, BridgeId: s.bridgeId}
+	if enable {
+		s.reselect(now)
+	}
+	s.publish()
+}
+
+func (s *Rstp) SetDelay(value int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if value > 0 {
+		s.delay = time.Duration(value) * time.Second
+	}
+}
+
+func (s *Rstp) AddPort(dev Taper, edge bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.ports[dev]; ok {
+		return
+	}
+	s.index++
+	p := &StpPort{
+		Device:    dev,
+		Id:        StpPortPriority<<8 | s.index&0x0fff,
+		Cost:      StpPortCost,
+		Edge:      edge,
+		State:     StpForwarding,
+		stateTime: time.Now(),
+	}
+	s.ports[dev] = p
+	if s.enable {
+		s.reselect(time.Now())
+	}
+	s.publish()
+}
+
+func (s *Rstp) DelPort(dev Taper) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.ports[dev]; !ok {
+		return
+	}
+	delete(s.ports, dev)
+	if s.enable {
+		s.reselect(time.Now())
+	}
+	s.publish()
+}
+
+// state is called for each frame, so reads copied states without lock.
+func (s *Rstp) state(dev Taper) int {
+	if !s.Enabled() {
+		return StpForwarding
+	}
+	states := s.states.Load().(map[Taper]int)
+	if state, ok := states[dev]; ok {
+		return state
+	}
+	return StpForwarding
+}
+
+func (s *Rstp) Forwarding(dev Taper) bool {
+	return s.state(dev) == StpForwarding
+}
+
+func (s *Rstp) Learning(dev Taper) bool {
+	return s.state(dev) >= StpLearning
+}
+
+func (s *Rstp) ListPort() []StpPort {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	ports := make([]StpPort, 0, len(s.ports))
+	for _, p := range s.ports {
+		ports = append(ports, *p)
+	}
+	return ports
+}
+
+func (s *Rstp) setState(p *StpPort, state int, now time.Time) {
+	if p.State == state {
+		return
+	}
+	s.out.Event("Rstp.setState: %s %d to %d", p, p.State, state)
+	p.State = state
+	p.stateTime = now
+	s.changed = true
+	if state == StpForwarding && !p.Edge {
+		s.flush = true
+		s.newTc(nil, now)
+	}
+}
+
+// newTc notifies topology change to other ports except from.
+func (s *Rstp) newTc(from *StpPort, now time.Time) {
+	for _, p := range s.ports {
+		if p == from || p.Edge {
+			continue
+		}
+		p.tcTime = now.Add(2 * s.hello)
+	}
+}
+
+func (s *Rstp) setRole(p *StpPort, role int, now time.Time) {
+	if p.Role == role {
+		return
+	}
+	s.out.Event("Rstp.setRole: %s %d to %d", p, p.Role, role)
+	p.Role = role
+	p.agreed = false
+	switch role {
+	case StpRoleRoot:
+		s.setState(p, StpForwarding, now)
+	case StpRoleDesignated:
+		if p.Edge {
+			s.setState(p, StpForwarding, now)
+		} else {
+			s.setState(p, StpDiscarding, now)
+		}
+	default:
+		s.setState(p, StpDiscarding, now)
+	}
+}
+
+// reselect computes root port, and roles of other ports.
+func (s *Rstp) reselect(now time.Time) {
+	best := StpVector{RootId: s.bridgeId, BridgeId: s.bridgeId}
+	var root *StpPort
+	for _, p := range s.ports {
+		if p.info == nil || p.info.BridgeId == s.bridgeId {
+			continue
+		}
+		cand := *p.info
+		cand.Cost += p.Cost
+		if cand.Better(best) || (cand == best && root != nil && p.Id < root.Id) {
+			best = cand
+			root = p
+		}
+	}
+	if best.RootId != s.root.RootId {
+		s.out.Event("Rstp.reselect: root %016x", best.RootId)
+	}
+	s.root = best
+	s.rootPort = root
+	for _, p := range s.ports {
+		if p == root {
+			s.setRole(p, StpRoleRoot, now)
+			continue
+		}
+		designated := s.designated(p)
+		if p.info != nil && p.info.Better(designated) {
+			s.setRole(p, StpRoleAlternate, now)
+		} else {
+			s.setRole(p, StpRoleDesignated, now)
+		}
+	}
+}
+
+func (s *Rstp) designated(p *StpPort) StpVector {
+	return StpVector{
+		RootId:   s.root.RootId,
+		Cost:     s.root.Cost,
+		BridgeId: s.bridgeId,
+		PortId:   p.Id,
+	}
+}
+
+func (s *Rstp) newBpdu(p *StpPort, now time.Time) *Bpdu {
+	b := &Bpdu{
+		Type:     BpduTypeRst,
+		Vector:   s.designated(p),
+		MaxAge:   20 << 8,
+		Hello:    uint16(s.hello/time.Second) << 8,
+		FwdDelay: uint16(s.delay/time.Second) << 8,
+	}
+	b.Flags = uint8(p.Role) << BpduRoleShift
+	switch p.State {
+	case StpLearning:
+		b.Flags |= BpduLearning
+	case StpForwarding:
+		b.Flags |= BpduLearning | BpduForwarding
+	}
+	if p.Role == StpRoleDesignated && p.State != StpForwarding {
+		b.Flags |= BpduProposal
+	}
+	if p.agree {
+		b.Flags |= BpduAgreement
+		p.agree = false
+	}
+	if now.Before(p.tcTime) {
+		b.Flags |= BpduTc
+	}
+	return b
+}
+
+func (s *Rstp) send(p *StpPort, now time.Time) {
+	frame := s.newBpdu(p, now).Encode(s.mac)
+	if _, err := p.Device.Send(frame); err != nil {
+		s.out.Debug("Rstp.send: %s %s", p, err)
+	}
+}
+
+// Recv handles bpdu from a device, and returns error if invalid.
+func (s *Rstp) Recv(dev Taper, frame []byte) error {
+	b, err := DecodeBpdu(frame)
+	if err != nil {
+		return err
+	}
+	s.lock.Lock()
+	if !s.enable {
+		s.lock.Unlock()
+		return nil
+	}
+	p, ok := s.ports[dev]
+	if !ok {
+		s.lock.Unlock()
+		return libol.NewErr("%s notFound", dev)
+	}
+	now := time.Now()
+	if p.Edge {
+		s.out.Info("Rstp.Recv: %s is not edge", p)
+		p.Edge = false
+	}
+	if b.Role() == StpRoleDesignated {
+		info := b.Vector
+		p.info = &info
+		p.infoTime = now
+	}
+	if b.Flags&BpduAgreement != 0 && p.Role == StpRoleDesignated {
+		p.agreed = true
+		s.setState(p, StpForwarding, now)
+	}
+	if b.Flags&BpduTc != 0 {
+		s.flush = true
+		s.newTc(p, now)
+	}
+	s.reselect(now)
+	if p.Role == StpRoleRoot && b.Flags&BpduProposal != 0 {
+		// sync: others are blocked until agreed by downstream.
+		for _, o := range s.ports {
+			if o != p && o.Role == StpRoleDesignated && !o.Edge && !o.agreed {
+				s.setState(o, StpDiscarding, now)
+			}
+		}
+		p.agree = true
+		s.send(p, now)
+	}
+	s.doPublish()
+	s.lock.Unlock()
+	s.doFlush()
+	return nil
+}
+
+// doPublish publishes states if changed, and must be called with lock.
+func (s *Rstp) doPublish() {
+	if s.changed {
+		s.changed = false
+		s.publish()
+	}
+}
+
+func (s *Rstp) doFlush() {
+	s.lock.Lock()
+	flush := s.flush
+	s.flush = false
+	s.lock.Unlock()
+	if flush && s.onFlush != nil {
+		s.onFlush()
+	}
+}
+
+// Tick ages information, moves state of designated ports, and sends
+// bpdu each hello time.
+func (s *Rstp) Tick(now time.Time) {
+	s.tick(now)
+	s.doFlush()
+}
+
+func (s *Rstp) tick(now time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !s.enable {
+		return
+	}
+	defer s.doPublish()
+	changed := false
+	for _, p := range s.ports {
+		if p.info != nil && now.Sub(p.infoTime) > 3*s.hello {
+			s.out.Event("Rstp.Tick: %s info expired", p)
+			p.info = nil
+			changed = true
+		}
+	}
+	if changed {
+		s.reselect(now)
+	}
+	for _, p := range s.ports {
+		if p.Role != StpRoleDesignated {
+			continue
+		}
+		if now.Sub(p.stateTime) >= s.delay {
+			switch p.State {
+			case StpDiscarding:
+				s.setState(p, StpLearning, now)
+			case StpLearning:
+				s.setState(p, StpForwarding, now)
+			}
+		}
+	}
+	if now.Sub(s.lastTime) < s.hello {
+		return
+	}
+	s.lastTime = now
+	// edge sends also, so a loop through it is found by own bpdu.
+	for _, p := range s.ports {
+		if p.Role == StpRoleAlternate {
+			continue
+		}
+		if p.Role == StpRoleDesignated || now.Before(p.tcTime) {
+			s.send(p, now)
+		}
+	}
+}
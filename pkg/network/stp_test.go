@@ -0,0 +1,95 @@
+package network
+
+import (
+	"github.com/danieldin95/openlan/pkg/libol"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestBpdu_Encode(t *testing.T) {
+	b := &Bpdu{
+		Type:  BpduTypeRst,
+		Flags: StpRoleDesignated<<BpduRoleShift | BpduProposal,
+		Vector: StpVector{
+			RootId:   0x8000000000000001,
+			Cost:     20000,
+			BridgeId: 0x8000000000000002,
+			PortId:   0x8001,
+		},
+		Hello: 2 << 8,
+	}
+	frame := b.Encode([]byte{0, 0, 0, 0, 0, 2})
+	assert.Equal(t, true, IsBpdu(frame), "be the same.")
+	o, err := DecodeBpdu(frame)
+	assert.Nil(t, err, "be nil.")
+	assert.Equal(t, b.Vector, o.Vector, "be the same.")
+	assert.Equal(t, StpRoleDesignated, o.Role(), "be the same.")
+	assert.Equal(t, b.Hello, o.Hello, "be the same.")
+}
+
+func TestRstp_Alternate(t *testing.T) {
+	out := libol.NewSubLogger("test")
+	root := NewRstp([]byte{0, 0, 0, 0, 0, 1}, out)
+	peer := NewRstp([]byte{0, 0, 0, 0, 0, 2}, out)
+	rp01, _ := NewVirtualTap("", TapConfig{})
+	pp01, _ := NewVirtualTap("", TapConfig{})
+	pp02, _ := NewVirtualTap("", TapConfig{})
+	root.AddPort(rp01, false)
+	peer.AddPort(pp01, false)
+	peer.AddPort(pp02, false)
+	root.Enable(true)
+	peer.Enable(true)
+	// two links from the same port of root to peer.
+	now := time.Now()
+	p := root.ports[rp01]
+	frame := root.newBpdu(p, now).Encode(root.mac)
+	assert.Nil(t, peer.Recv(pp01, frame), "be nil.")
+	assert.Nil(t, peer.Recv(pp02, frame), "be nil.")
+	assert.Equal(t, StpRoleRoot, peer.ports[pp01].Role, "be the same.")
+	assert.Equal(t, StpRoleAlternate, peer.ports[pp02].Role, "be the same.")
+	assert.Equal(t, true, peer.Forwarding(pp01), "be the same.")
+	assert.Equal(t, false, peer.Forwarding(pp02), "be the same.")
+	// expired and to be designated.
+	peer.Tick(now.Add(10 * time.Second))
+	assert.Equal(t, StpRoleDesignated, peer.ports[pp02].Role, "be the same.")
+}
+
+func TestRstp_Edge(t *testing.T) {
+	out := libol.NewSubLogger("test")
+	s := NewRstp([]byte{0, 0, 0, 0, 0, 1}, out)
+	flush := 0
+	s.onFlush = func() { flush++ }
+	dev01, _ := NewVirtualTap("", TapConfig{})
+	dev02, _ := NewVirtualTap("", TapConfig{})
+	s.Enable(true)
+	s.AddPort(dev01, true)
+	s.AddPort(dev02, true)
+	now := time.Now()
+	s.Tick(now)
+	assert.Equal(t, true, s.Forwarding(dev01), "be the same.")
+	assert.Equal(t, true, s.Forwarding(dev02), "be the same.")
+	assert.Equal(t, 0, flush, "be the same.")
+	// own bpdu of dev01 looped back to dev02.
+	frame := s.newBpdu(s.ports[dev01], now).Encode(s.mac)
+	assert.Nil(t, s.Recv(dev02, frame), "be nil.")
+	assert.Equal(t, false, s.ports[dev02].Edge, "be the same.")
+	assert.Equal(t, StpRoleAlternate, s.ports[dev02].Role, "be the same.")
+	assert.Equal(t, true, s.Forwarding(dev01), "be the same.")
+	assert.Equal(t, false, s.Forwarding(dev02), "be the same.")
+}
+
+func TestVirtualBridge_Flap(t *testing.T) {
+	br := NewVirtualBridge("br-flap", 1500)
+	br.SetFlap(2, 60)
+	dev01, _ := NewVirtualTap("", TapConfig{})
+	dev02, _ := NewVirtualTap("", TapConfig{})
+	frame := make([]byte, 64)
+	copy(frame[6:12], []byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x05})
+	br.Learn(&Framer{Data: frame, Source: dev01})
+	br.Learn(&Framer{Data: frame, Source: dev02})
+	assert.Equal(t, false, br.isBlocked(dev02), "be the same.")
+	br.Learn(&Framer{Data: frame, Source: dev01})
+	assert.Equal(t, true, br.isBlocked(dev01), "be the same.")
+	assert.Equal(t, false, br.isBlocked(dev02), "be the same.")
+}
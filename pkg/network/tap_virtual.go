@@ -8,9 +8,9 @@ import (
 type VirtualTap struct {
 	lock   sync.RWMutex
 	kernC  int
-	kernQ  chan []byte
+	kernQ  chan *libol.FrameMessage
 	virtC  int
-	virtQ  chan []byte
+	virtQ  chan *libol.FrameMessage
 	master Bridger
 	tenant string
 	flags  uint
@@ -22,7 +22,7 @@ type VirtualTap struct {
 
 func NewVirtualTap(tenant string, c TapConfig) (*VirtualTap, error) {
 	name := c.Name
-	if name == "" {
+	if name == "" || name == "auto" {
 		name = Taps.GenName()
 	}
 	tap := &VirtualTap{
@@ -84,22 +84,40 @@ func (t *VirtualTap) Write(p []byte) (int, error) {
 		return 0, nil
 	}
 	t.virtC++
-	t.virtQ <- p
+	t.virtQ <- t.copyIn(p)
 	return len(p), nil
 }
 
+// copyIn saves p to frame from pool, and p is free for caller after it.
+func (t *VirtualTap) copyIn(p []byte) *libol.FrameMessage {
+	m := libol.AllocFrame(len(p))
+	m.Append(p)
+	return m
+}
+
+// copyOut copies frame to p, and releases it.
+func (t *VirtualTap) copyOut(p []byte, m *libol.FrameMessage) int {
+	if m == nil {
+		return 0
+	}
+	n := copy(p, m.Frame()[:m.Size()])
+	m.Release()
+	return n
+}
+
 func (t *VirtualTap) Read(p []byte) (int, error) {
 	t.lock.Lock()
 	if !t.hasFlags(UsUp) {
 		t.lock.Unlock()
 		return 0, libol.NewErr("notUp")
 	}
+	queue := t.kernQ // closed and reset by down.
 	t.lock.Unlock()
-	data := <-t.kernQ
+	m := <-queue
 	t.lock.Lock()
 	t.kernC--
 	t.lock.Unlock()
-	return copy(p, data), nil
+	return t.copyOut(p, m), nil
 }
 
 func (t *VirtualTap) Recv(p []byte) (int, error) {
@@ -108,12 +126,13 @@ func (t *VirtualTap) Recv(p []byte) (int, error) {
 		t.lock.Unlock()
 		return 0, libol.NewErr("notUp")
 	}
+	queue := t.virtQ // closed and reset by down.
 	t.lock.Unlock()
-	data := <-t.virtQ
+	m := <-queue
 	t.lock.Lock()
 	t.virtC--
 	t.lock.Unlock()
-	return copy(p, data), nil
+	return t.copyOut(p, m), nil
 }
 
 func (t *VirtualTap) Send(p []byte) (int, error) {
@@ -131,7 +150,7 @@ func (t *VirtualTap) Send(p []byte) (int, error) {
 		return 0, nil
 	}
 	t.kernC++
-	t.kernQ <- p
+	t.kernQ <- t.copyIn(p)
 	return len(p), nil
 }
 
@@ -172,9 +191,9 @@ func (t *VirtualTap) Up() {
 	defer t.lock.Unlock()
 	if !t.hasFlags(UsUp) {
 		t.kernC = 0
-		t.kernQ = make(chan []byte, t.cfg.KernBuf)
+		t.kernQ = make(chan *libol.FrameMessage, t.cfg.KernBuf)
 		t.virtC = 0
-		t.virtQ = make(chan []byte, t.cfg.VirBuf)
+		t.virtQ = make(chan *libol.FrameMessage, t.cfg.VirBuf)
 		t.setFlags(UsUp)
 	}
 }
@@ -13,9 +13,15 @@ const (
 )
 
 type DeviceStats struct {
-	Send int64 `json:"send"`
-	Recv int64 `json:"recv"`
-	Drop int64 `json:"drop"`
+	Send  int64                `json:"send"`
+	Recv  int64                `json:"recv"`
+	Drop  int64                `json:"drop"`
+	Ports map[string]PortStats `json:"ports,omitempty"`
+}
+
+type PortStats struct {
+	Drop  int64 `json:"drop"`
+	Storm int64 `json:"storm"` // dropped by storm control.
 }
 
 type Taper interface {
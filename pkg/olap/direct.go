@@ -0,0 +1,362 @@
+package olap
+
+import (
+	"encoding/json"
+	"github.com/danieldin95/openlan/pkg/config"
+	"github.com/danieldin95/openlan/pkg/libol"
+	"github.com/danieldin95/openlan/pkg/models"
+	"net"
+	"sync"
+	"time"
+)
+
+type DirectWorkerListener struct {
+	ReadAt func(frame *libol.FrameMessage) error
+}
+
+type DirectPeer struct {
+	candidate *models.Candidate
+	endpoints []*net.UDPAddr // candidates for punching.
+	remote    *net.UDPAddr   // endpoint of connected path.
+	state     string
+	connTime  int64
+	lastTime  int64 // last time received from peer.
+	rxBytes   int64
+	txBytes   int64
+}
+
+func (p *DirectPeer) Path() *models.DirectPath {
+	path := &models.DirectPath{
+		Peer:    p.candidate.UUID,
+		Alias:   p.candidate.Alias,
+		State:   p.state,
+		RxBytes: p.rxBytes,
+		TxBytes: p.txBytes,
+	}
+	if p.remote != nil {
+		path.Endpoint = p.remote.String()
+	}
+	if p.state == models.DirectConnected {
+		path.AliveTime = time.Now().Unix() - p.connTime
+	}
+	return path
+}
+
+type DirectWorker struct {
+	// private
+	lock     sync.Mutex
+	listener DirectWorkerListener
+	socket   *libol.DirectSocket
+	cfg      *config.Direct
+	uuid     string
+	token    string
+	server   *net.UDPAddr // rendezvous of switch.
+	public   string       // learned by rendezvous.
+	peers    map[string]*DirectPeer
+	hwAddrs  map[string]*DirectPeer // ethernet address of peers.
+	locals   map[string]int64       // ethernet address from local device.
+	done     chan bool
+	ticker   *time.Ticker
+	out      *libol.SubLogger
+}
+
+func NewDirectWorker(c *config.Point) *DirectWorker {
+	cfg := c.Direct
+	return &DirectWorker{
+		socket:  libol.NewDirectSocket(cfg.Listen, config.GetBlock(c.Crypt)),
+		cfg:     cfg,
+		token:   libol.GenRandom(32),
+		peers:   make(map[string]*DirectPeer, 32),
+		hwAddrs: make(map[string]*DirectPeer, 1024),
+		locals:  make(map[string]int64, 64),
+		done:    make(chan bool, 2),
+		ticker:  time.NewTicker(time.Duration(cfg.Interval) * time.Second),
+		out:     libol.NewSubLogger(c.Id()),
+	}
+}
+
+func (d *DirectWorker) Initialize() {
+	d.out.Info("DirectWorker.Initialize")
+	if err := d.socket.Listen(); err != nil {
+		d.out.Error("DirectWorker.Initialize: %s", err)
+	}
+}
+
+func (d *DirectWorker) Start() {
+	d.out.Info("DirectWorker.Start")
+	libol.Go(d.Read)
+	libol.Go(d.Loop)
+}
+
+func (d *DirectWorker) Stop() {
+	d.out.Info("DirectWorker.Stop")
+	d.done <- true
+	d.ticker.Stop()
+	d.socket.Close()
+}
+
+func (d *DirectWorker) localAddrs() []string {
+	addrs := make([]string, 0, 4)
+	_, port := libol.GetHostPort(d.socket.LocalAddr())
+	ifAddrs, err := net.InterfaceAddrs()
+	if err != nil || port == "" {
+		return addrs
+	}
+	for _, addr := range ifAddrs {
+		inet, ok := addr.(*net.IPNet)
+		if !ok || inet.IP.IsLoopback() || inet.IP.To4() == nil {
+			continue
+		}
+		addrs = append(addrs, inet.IP.String()+":"+port)
+	}
+	return addrs
+}
+
+// Candidate to advise switch.
+func (d *DirectWorker) Candidate() *models.Candidate {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	c := &models.Candidate{
+		Token:  d.token,
+		HwAddr: make([]string, 0, len(d.locals)),
+		Local:  d.localAddrs(),
+		Public: d.public,
+		Paths:  make([]*models.DirectPath, 0, len(d.peers)),
+	}
+	for addr := range d.locals {
+		c.HwAddr = append(c.HwAddr, net.HardwareAddr(addr).String())
+	}
+	for _, peer := range d.peers {
+		c.Paths = append(c.Paths, peer.Path())
+	}
+	return c
+}
+
+func (d *DirectWorker) Paths() []*models.DirectPath {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	paths := make([]*models.DirectPath, 0, len(d.peers))
+	for _, peer := range d.peers {
+		paths = append(paths, peer.Path())
+	}
+	return paths
+}
+
+func (d *DirectWorker) resolve(addrs ...string) []*net.UDPAddr {
+	udpAddrs := make([]*net.UDPAddr, 0, len(addrs))
+	for _, addr := range addrs {
+		if addr == "" {
+			continue
+		}
+		if udpAddr, err := net.ResolveUDPAddr("udp", addr); err == nil {
+			udpAddrs = append(udpAddrs, udpAddr)
+		}
+	}
+	return udpAddrs
+}
+
+// OnRendezvous update peers from switch, and host is address of switch.
+func (d *DirectWorker) OnRendezvous(host string, r *models.Rendezvous) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if r.UUID != "" {
+		d.uuid = r.UUID
+	}
+	if servers := d.resolve(host + ":" + r.Port); len(servers) > 0 {
+		d.server = servers[0]
+	}
+	news := make(map[string]*DirectPeer, len(r.Peers))
+	for _, c := range r.Peers {
+		peer, ok := d.peers[c.UUID]
+		if !ok {
+			peer = &DirectPeer{state: models.DirectInit}
+			d.out.Info("DirectWorker.OnRendezvous: new %s", c)
+		}
+		peer.candidate = c
+		peer.endpoints = d.resolve(append([]string{c.Public}, c.Local...)...)
+		news[c.UUID] = peer
+	}
+	d.peers = news
+	d.hwAddrs = make(map[string]*DirectPeer, 1024)
+	for _, peer := range d.peers {
+		for _, addr := range peer.candidate.HwAddr {
+			if hw, err := net.ParseMAC(addr); err == nil {
+				d.hwAddrs[string(hw)] = peer
+			}
+		}
+	}
+}
+
+func (d *DirectWorker) sendPunch(action string, p *models.Punch, addr *net.UDPAddr) {
+	body, err := json.Marshal(p)
+	if err != nil {
+		return
+	}
+	m := libol.NewControlFrame(action, body)
+	if err := d.socket.WriteMsgTo(m, addr); err != nil {
+		d.out.Debug("DirectWorker.sendPunch: %s %s", addr, err)
+	}
+}
+
+// Forward sends unicast frame to peer directly, and returns false
+// if not path connected for it.
+func (d *DirectWorker) Forward(frame *libol.FrameMessage) bool {
+	data := frame.Frame()
+	if frame.Size() < libol.EtherLen {
+		return false
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if _, ok := d.locals[string(data[6:12])]; !ok && len(d.locals) < 64 {
+		d.locals[string(data[6:12])] = time.Now().Unix()
+	}
+	if data[0]&0x01 == 0x01 { // broadcast or multicast.
+		return false
+	}
+	peer, ok := d.hwAddrs[string(data[:6])]
+	if !ok || peer.state != models.DirectConnected || !d.socket.IsOk() {
+		return false
+	}
+	size := int64(frame.Size())
+	// frame already encoded, and dropping it if error.
+	if err := d.socket.WriteMsgTo(frame, peer.remote); err != nil {
+		d.out.Debug("DirectWorker.Forward: %s", err)
+		return true
+	}
+	peer.txBytes += size
+	return true
+}
+
+func (d *DirectWorker) onConnected(peer *DirectPeer, addr *net.UDPAddr) {
+	now := time.Now().Unix()
+	if peer.state != models.DirectConnected {
+		d.out.Info("DirectWorker.onConnected: %s on %s", peer.candidate.UUID, addr)
+		peer.state = models.DirectConnected
+		peer.connTime = now
+	}
+	peer.remote = addr
+	peer.lastTime = now
+}
+
+func (d *DirectWorker) onPunch(addr *net.UDPAddr, action string, data []byte) {
+	recv := &models.Punch{}
+	if err := json.Unmarshal(data, recv); err != nil {
+		d.out.Warn("DirectWorker.onPunch: %s invalid json data.", addr)
+		return
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if action == libol.PunchResp && d.server != nil && addr.String() == d.server.String() {
+		if d.public != recv.Address {
+			d.out.Info("DirectWorker.onPunch: public %s", recv.Address)
+			d.public = recv.Address
+		}
+		return
+	}
+	peer, ok := d.peers[recv.UUID]
+	if !ok || recv.Token != d.token {
+		d.out.Debug("DirectWorker.onPunch: %s from %s dissed", recv.UUID, addr)
+		return
+	}
+	d.onConnected(peer, addr)
+	if action == libol.PunchReq {
+		resp := &models.Punch{
+			UUID:  d.uuid,
+			Token: peer.candidate.Token,
+			Time:  recv.Time,
+		}
+		d.sendPunch(libol.PunchResp, resp, addr)
+	}
+}
+
+func (d *DirectWorker) onFrame(addr *net.UDPAddr, frame *libol.FrameMessage) {
+	d.lock.Lock()
+	var from *DirectPeer
+	for _, peer := range d.peers {
+		if peer.remote != nil && peer.remote.String() == addr.String() {
+			from = peer
+			break
+		}
+	}
+	if from == nil {
+		d.lock.Unlock()
+		d.out.Debug("DirectWorker.onFrame: %s notFound", addr)
+		frame.Release()
+		return
+	}
+	data := frame.Frame()
+	from.lastTime = time.Now().Unix()
+	from.rxBytes += int64(frame.Size())
+	if frame.Size() >= libol.EtherLen {
+		d.hwAddrs[string(data[6:12])] = from
+	}
+	d.lock.Unlock()
+	if d.listener.ReadAt != nil {
+		_ = d.listener.ReadAt(frame)
+	}
+}
+
+func (d *DirectWorker) Read() {
+	for {
+		frame, addr, err := d.socket.ReadMsgFrom()
+		if err != nil {
+			if !d.socket.IsOk() {
+				break
+			}
+			d.out.Debug("DirectWorker.Read: %s", err)
+			continue
+		}
+		if frame.Decode() {
+			action, body := frame.CmdAndParams()
+			d.onPunch(addr, action, body)
+			frame.Release()
+			continue
+		}
+		d.onFrame(addr, frame)
+	}
+	d.out.Info("DirectWorker.Read: exit")
+}
+
+func (d *DirectWorker) doTicker() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.uuid == "" {
+		return
+	}
+	now := time.Now().Unix()
+	if d.server != nil {
+		p := &models.Punch{UUID: d.uuid, Token: d.token, Time: now}
+		d.sendPunch(libol.PunchReq, p, d.server)
+	}
+	for _, peer := range d.peers {
+		if peer.state == models.DirectConnected {
+			if now-peer.lastTime > int64(d.cfg.Timeout) {
+				d.out.Info("DirectWorker.doTicker: %s expired", peer.candidate.UUID)
+				peer.state = models.DirectExpired
+				peer.remote = nil
+			}
+		}
+		p := &models.Punch{UUID: d.uuid, Token: peer.candidate.Token, Time: now}
+		if peer.state == models.DirectConnected {
+			d.sendPunch(libol.PunchReq, p, peer.remote)
+			continue
+		}
+		if peer.state == models.DirectInit {
+			peer.state = models.DirectPunching
+		}
+		for _, addr := range peer.endpoints {
+			d.sendPunch(libol.PunchReq, p, addr)
+		}
+	}
+}
+
+func (d *DirectWorker) Loop() {
+	for {
+		select {
+		case <-d.done:
+			return
+		case <-d.ticker.C:
+			d.doTicker()
+		}
+	}
+}
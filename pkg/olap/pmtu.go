@@ -0,0 +1,77 @@
+package olap
+
+import (
+	"github.com/danieldin95/openlan/pkg/config"
+)
+
+// PathMtu searches the largest ip packet passed to switch by binary
+// search, and a pong of padded ping means its size is passed.
+type PathMtu struct {
+	Min      int
+	Max      int
+	Mtu      int // effective, and zero if not discovered.
+	interval int64
+	low      int // passed.
+	high     int
+	probe    int // size in flight.
+	fails    int
+	doneAt   int64
+}
+
+func NewPathMtu(c *config.Pmtu, max int) *PathMtu {
+	p := &PathMtu{
+		Min:      c.Min,
+		Max:      max,
+		interval: int64(c.Interval),
+	}
+	p.Reset()
+	return p
+}
+
+// Reset starts searching again, and keeps the effective one.
+func (p *PathMtu) Reset() {
+	p.low = p.Min
+	p.high = p.Max
+	p.probe = 0
+	p.fails = 0
+}
+
+// Next returns size to probe, and zero if searching is done.
+func (p *PathMtu) Next(now int64) int {
+	if p.probe > 0 { // no pong in time.
+		p.fails++
+		if p.fails < 2 { // maybe lost, and try again.
+			return p.probe
+		}
+		p.high = p.probe - 1
+		p.probe = 0
+		p.done(now)
+	}
+	if p.low >= p.high {
+		if now-p.doneAt < p.interval {
+			return 0
+		}
+		p.Reset()
+	}
+	p.fails = 0
+	p.probe = (p.low + p.high + 1) / 2
+	return p.probe
+}
+
+// Pong records size passed.
+func (p *PathMtu) Pong(size int, now int64) {
+	if size == 0 || size != p.probe {
+		return
+	}
+	p.low = size
+	p.probe = 0
+	p.done(now)
+}
+
+func (p *PathMtu) done(now int64) {
+	if p.low < p.high {
+		return
+	}
+	p.doneAt = now
+	p.Mtu = p.low
+}
@@ -0,0 +1,65 @@
+package olap
+
+import (
+	"github.com/danieldin95/openlan/pkg/config"
+	"github.com/danieldin95/openlan/pkg/libol"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPathMtu(t *testing.T) {
+	p := NewPathMtu(&config.Pmtu{Min: 1280, Interval: 600}, 1500)
+	now := int64(1000)
+	for i := 0; i < 32; i++ {
+		size := p.Next(now)
+		if size == 0 {
+			break
+		}
+		if size <= 1400 { // path passed.
+			p.Pong(size, now)
+		}
+		now += 2
+	}
+	assert.Equal(t, 1400, p.Mtu, "be the same.")
+	assert.Equal(t, 0, p.Next(now), "be done.")
+
+	// probe again after interval.
+	now += 600
+	assert.Equal(t, 1390, p.Next(now), "be the same.")
+	p.Pong(1200, now)
+	assert.Equal(t, 1390, p.Next(now), "try again.")
+	assert.Equal(t, 1400, p.Mtu, "be kept.")
+}
+
+type fakeFragger struct {
+	libol.SocketClient
+	sets []bool
+}
+
+func (c *fakeFragger) SetDontFrag(enable bool) error {
+	c.sets = append(c.sets, enable)
+	return nil
+}
+
+func TestSocketWorker_DontFrag(t *testing.T) {
+	c := &fakeFragger{}
+	w := &SocketWorker{client: c, out: libol.NewSubLogger("test")}
+	w.setDontFrag(false)
+	w.setDontFrag(true)
+	w.setDontFrag(true)
+	w.setDontFrag(false)
+	assert.Equal(t, []bool{true, false}, c.sets, "be the same.")
+}
+
+func TestPingMsgEncode(t *testing.T) {
+	m := &PingMsg{
+		DateTime: 1634616184000000000,
+		UUID:     "a5bdc7d6e1f0",
+		Alias:    "pc-01",
+		Size:     1400,
+	}
+	body, err := m.Encode(1400)
+	assert.Nil(t, err, "be nil.")
+	frame := libol.NewControlFrame(libol.PingReq, body)
+	assert.Equal(t, 1400+libol.EtherLen, frame.Size(), "be the same.")
+}
@@ -40,6 +40,7 @@ func (p *Point) Initialize() {
 	p.worker.listener.AddRoutes = p.AddRoutes
 	p.worker.listener.DelRoutes = p.DelRoutes
 	p.worker.listener.OnTap = p.OnTap
+	p.worker.listener.SetMtu = p.SetMtu
 	p.MixPoint.Initialize()
 }
 
@@ -138,6 +139,26 @@ func (p *Point) OnTap(w *TapWorker) error {
 	return nil
 }
 
+// SetMtu updates mtu of tap device by path discovered.
+func (p *Point) SetMtu(mtu int) error {
+	p.ipMtu = mtu
+	name := p.IfName()
+	if name == "" {
+		return nil
+	}
+	link, err := netlink.LinkByName(name)
+	if err != nil {
+		p.out.Error("Point.SetMtu: Get %s: %s", name, err)
+		return err
+	}
+	if err := netlink.LinkSetMTU(link, mtu); err != nil {
+		p.out.Error("Point.SetMtu: %s %s", name, err)
+		return err
+	}
+	p.out.Info("Point.SetMtu: %s %d", name, mtu)
+	return nil
+}
+
 func (p *Point) GetRemote() string {
 	conn := p.worker.conWorker
 	if conn == nil {
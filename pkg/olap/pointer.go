@@ -25,6 +25,7 @@ type Pointer interface {
 	Alias() string
 	Config() *config.Point
 	Network() *models.Network
+	Direct() []*models.DirectPath
 }
 
 type MixPoint struct {
@@ -147,3 +148,10 @@ func (p *MixPoint) Network() *models.Network {
 func (p *MixPoint) Protocol() string {
 	return p.config.Protocol
 }
+
+func (p *MixPoint) Direct() []*models.DirectPath {
+	if p.worker.dirWorker == nil {
+		return nil
+	}
+	return p.worker.dirWorker.Paths()
+}
@@ -15,6 +15,7 @@ type SocketWorkerListener struct {
 	OnClose   func(w *SocketWorker) error
 	OnSuccess func(w *SocketWorker) error
 	OnIpAddr  func(w *SocketWorker, n *models.Network) error
+	OnPmtu    func(w *SocketWorker, mtu int) error
 	ReadAt    func(frame *libol.FrameMessage) error
 }
 
@@ -29,6 +30,7 @@ const (
 	rtIpAddr    = "addrAt"   // record last receive ipAddr message after success.
 	rtConnects  = "conns"    // record times of reconnecting
 	rtLatency   = "latency"  // latency by ping.
+	rtPmtu      = "pmtu"     // effective mtu of path.
 )
 
 type SocketWorker struct {
@@ -49,6 +51,13 @@ type SocketWorker struct {
 	record     *libol.SafeStrInt64
 	out        *libol.SubLogger
 	wlFrame    *libol.FrameMessage // Last frame from write.
+	direct     *DirectWorker
+	features   []string // negotiated in login.
+	zip        *libol.Compressor
+	member     bool // as a link of bond, and only forwards frames.
+	pmtu       *PathMtu
+	dontFrag   bool              // DF bit is set while searching mtu.
+	session    *libol.XdpSession // to roam, and renewed in login.
 }
 
 func NewSocketWorker(client libol.SocketClient, c *config.Point) *SocketWorker {
@@ -64,6 +73,7 @@ func NewSocketWorker(client libol.SocketClient, c *config.Point) *SocketWorker {
 		writeQueue: make(chan *libol.FrameMessage, c.Queue.SockWr),
 		jobber:     make([]jobTimer, 0, 32),
 		out:        libol.NewSubLogger(c.Id()),
+		zip:        config.GetCompressor(c.Compress),
 	}
 	t.user = &models.User{
 		Alias:    c.Alias,
@@ -71,14 +81,50 @@ func NewSocketWorker(client libol.SocketClient, c *config.Point) *SocketWorker {
 		Password: c.Password,
 		Network:  c.Network,
 		System:   runtime.GOOS,
+		Version:  libol.ProtoVersion,
+		Features: t.localFeatures(c),
 	}
 	t.keepalive = KeepAlive{
 		Interval: 15,
 		LastTime: time.Now().Unix(),
 	}
+	if c.Pmtu != nil {
+		t.pmtu = NewPathMtu(c.Pmtu, c.Interface.IPMtu)
+	}
 	return t
 }
 
+func (t *SocketWorker) localFeatures(c *config.Point) []string {
+	features := []string{libol.FeatRoute}
+	if crypt := c.Crypt.Feature(); crypt != "" {
+		features = append(features, crypt)
+	}
+	if c.Roaming {
+		features = append(features, libol.FeatRoaming)
+	}
+	if t.zip != nil {
+		features = append(features, t.zip.Feature())
+	}
+	return features
+}
+
+// enableRoaming carries session in datagrams after negotiated.
+func (t *SocketWorker) enableRoaming() {
+	obj, ok := t.client.(libol.Sessioner)
+	if !ok || t.session == nil || !t.Feature(libol.FeatRoaming) {
+		t.out.Warn("SocketWorker.onLogin: roaming notSupport")
+		return
+	}
+	if err := obj.SetSession(t.session); err != nil {
+		t.out.Warn("SocketWorker.onLogin: roaming %s", err)
+	}
+}
+
+// Feature returns whether it's enabled by both sides.
+func (t *SocketWorker) Feature(name string) bool {
+	return libol.HasFeature(t.features, name)
+}
+
 func (t *SocketWorker) sleepNow() int64 {
 	sleeps := t.record.Get(rtSleeps)
 	return sleeps * 5
@@ -219,6 +265,10 @@ func (t *SocketWorker) sendLogin(client libol.SocketClient) error {
 	if client == nil {
 		return libol.NewErr("client is nil")
 	}
+	if t.pinCfg.Roaming {
+		t.session = libol.NewXdpSession()
+		t.user.Session = t.session.String()
+	}
 	body, err := json.Marshal(t.user)
 	if err != nil {
 		return err
@@ -257,6 +307,9 @@ func (t *SocketWorker) sendIpAddr(client libol.SocketClient) error {
 }
 
 func (t *SocketWorker) canReqAddr() bool {
+	if t.member {
+		return false
+	}
 	if t.pinCfg.RequestAddr {
 		return true
 	}
@@ -285,7 +338,24 @@ func (t *SocketWorker) onLogin(resp []byte) error {
 		t.out.Cmd("SocketWorker.onLogin: %s", resp)
 		return nil
 	}
-	if strings.HasPrefix(string(resp), "okay") {
+	reply, err := libol.DecodeLoginReply(resp)
+	if err != nil {
+		return libol.NewErr("SocketWorker.onLogin: invalid json data.")
+	}
+	if reply.Okay() {
+		t.features = libol.Negotiate(reply.Features, t.user.Features)
+		if reply.Version == 0 {
+			t.features = libol.LegacyFeatures
+		}
+		if t.pinCfg.Roaming {
+			t.enableRoaming()
+		}
+		if t.zip != nil && t.Feature(t.zip.Feature()) {
+			t.client.SetCompressor(t.zip)
+		} else {
+			t.client.SetCompressor(nil)
+		}
+		t.keepalive.Interval = 15
 		t.client.SetStatus(libol.ClAuth)
 		if t.listener.OnSuccess != nil {
 			_ = t.listener.OnSuccess(t)
@@ -294,14 +364,28 @@ func (t *SocketWorker) onLogin(resp []byte) error {
 		t.record.Set(rtIpAddr, 0)
 		t.record.Set(rtSuccess, time.Now().Unix())
 		t.eventQueue <- NewEvent(EvSocSuccess, "from login")
-		t.out.Info("SocketWorker.onLogin: success")
+		t.out.Info("SocketWorker.onLogin: success with %v", t.features)
 	} else {
 		t.client.SetStatus(libol.ClUnAuth)
-		t.out.Error("SocketWorker.onLogin: %s", resp)
+		t.out.Error("SocketWorker.onLogin: %d %s", reply.Code, reply.Message)
+		t.onLoginErr(reply)
 	}
 	return nil
 }
 
+// onLoginErr slows down login retry if it can't be fixed soon.
+func (t *SocketWorker) onLoginErr(reply *libol.LoginReply) {
+	switch reply.Code {
+	case libol.LoginWrong, libol.LoginExpired, libol.LoginNotFound, libol.LoginCrypt:
+		t.keepalive.Interval = 120
+	case libol.LoginFull:
+		t.keepalive.Interval = 60
+	default:
+		t.keepalive.Interval = 15
+	}
+	t.out.Event("SocketWorker.onLoginErr: retry in %ds", t.keepalive.Interval)
+}
+
 func (t *SocketWorker) onIpAddr(resp []byte) error {
 	if !t.pinCfg.RequestAddr {
 		t.out.Info("SocketWorker.onIpAddr: notAllowed")
@@ -311,6 +395,9 @@ func (t *SocketWorker) onIpAddr(resp []byte) error {
 	if err := json.Unmarshal(resp, n); err != nil {
 		return libol.NewErr("SocketWorker.onIpAddr: invalid json data.")
 	}
+	if !t.Feature(libol.FeatRoute) {
+		n.Routes = nil
+	}
 	t.network = n
 	if t.listener.OnIpAddr != nil {
 		_ = t.listener.OnIpAddr(t, n)
@@ -318,6 +405,38 @@ func (t *SocketWorker) onIpAddr(resp []byte) error {
 	return nil
 }
 
+func (t *SocketWorker) sendCandidate(client libol.SocketClient) error {
+	if client == nil {
+		return libol.NewErr("client is nil")
+	}
+	if t.direct == nil {
+		return nil
+	}
+	body, err := json.Marshal(t.direct.Candidate())
+	if err != nil {
+		return err
+	}
+	t.out.Cmd("SocketWorker.sendCandidate: %s", body)
+	m := libol.NewControlFrame(libol.CandidateReq, body)
+	if err := client.WriteMsg(m); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *SocketWorker) onCandidate(resp []byte) error {
+	if t.direct == nil {
+		return nil
+	}
+	r := &models.Rendezvous{}
+	if err := json.Unmarshal(resp, r); err != nil {
+		return libol.NewErr("SocketWorker.onCandidate: invalid json data.")
+	}
+	host, _ := libol.GetHostPort(t.client.RemoteAddr())
+	t.direct.OnRendezvous(host, r)
+	return nil
+}
+
 func (t *SocketWorker) onLeft(resp []byte) error {
 	t.out.Info("SocketWorker.onLeft")
 	t.out.Cmd("SocketWorker.onLeft: %s", resp)
@@ -339,9 +458,25 @@ func (t *SocketWorker) onPong(resp []byte) error {
 	}
 	latency := time.Now().UnixNano() - m.DateTime // ns
 	t.record.Set(rtLatency, latency/1e6)          // ms
+	if t.pmtu != nil && m.Size > 0 {
+		t.pmtu.Pong(m.Size, time.Now().Unix())
+		t.onPmtu()
+	}
 	return nil
 }
 
+func (t *SocketWorker) onPmtu() {
+	mtu := int64(t.pmtu.Mtu)
+	if mtu == 0 || mtu == t.record.Get(rtPmtu) {
+		return
+	}
+	t.out.Info("SocketWorker.onPmtu: %d", mtu)
+	t.record.Set(rtPmtu, mtu)
+	if t.listener.OnPmtu != nil {
+		_ = t.listener.OnPmtu(t, int(mtu))
+	}
+}
+
 // handle instruct from virtual switch
 func (t *SocketWorker) onInstruct(frame *libol.FrameMessage) error {
 	if !frame.IsControl() {
@@ -360,6 +495,8 @@ func (t *SocketWorker) onInstruct(frame *libol.FrameMessage) error {
 	case libol.PongResp:
 		t.record.Set(rtLive, time.Now().Unix())
 		return t.onPong(resp)
+	case libol.CandidateResp:
+		return t.onCandidate(resp)
 	case libol.SignReq:
 		return t.onSignIn(resp)
 	case libol.LeftReq:
@@ -376,9 +513,32 @@ type PingMsg struct {
 	Alias      string `json:"alias"`
 	Connection string `json:"connection"`
 	Address    string `json:"address"`
+	Mtu        int    `json:"mtu,omitempty"`  // effective mtu of path.
+	Size       int    `json:"size,omitempty"` // ip packet to probe.
+	Pad        string `json:"pad,omitempty"`  // must be the last.
+}
+
+// Encode pads the ping to length of an ethernet frame carried ip packet
+// by size, and the pad is removed by switch in pong.
+func (m *PingMsg) Encode(size int) ([]byte, error) {
+	body, err := json.Marshal(m)
+	if err != nil || size == 0 {
+		return body, err
+	}
+	want := size + libol.EtherLen - libol.EthDI - len(libol.PingReq)
+	if n := want - len(body) - len(`,"pad":""`); n > 0 {
+		m.Pad = strings.Repeat("0", n)
+		return json.Marshal(m)
+	}
+	return body, nil
 }
 
 func (t *SocketWorker) sendPing(client libol.SocketClient) error {
+	return t.sendProbe(client, 0)
+}
+
+// sendProbe sends a ping padded to size of ip packet if not zero.
+func (t *SocketWorker) sendProbe(client libol.SocketClient, size int) error {
 	if client == nil {
 		return libol.NewErr("client is nil")
 	}
@@ -388,12 +548,20 @@ func (t *SocketWorker) sendPing(client libol.SocketClient) error {
 		Alias:      t.user.Alias,
 		Address:    t.client.LocalAddr(),
 		Connection: t.client.RemoteAddr(),
+		Size:       size,
 	}
-	body, err := json.Marshal(data)
+	if t.pmtu != nil {
+		data.Mtu = t.pmtu.Mtu
+	}
+	body, err := data.Encode(size)
 	if err != nil {
 		return err
 	}
-	t.out.Cmd("SocketWorker.sendPing: ping= %s", body)
+	if size == 0 {
+		t.out.Cmd("SocketWorker.sendPing: ping= %s", body)
+	} else {
+		t.out.Cmd("SocketWorker.sendProbe: %d", size)
+	}
 	m := libol.NewControlFrame(libol.PingReq, body)
 	if err := client.WriteMsg(m); err != nil {
 		return err
@@ -416,6 +584,9 @@ func (t *SocketWorker) keepAlive() {
 		if err := t.sendPing(t.client); err != nil {
 			t.out.Error("SocketWorker.keepAlive: %s", err)
 		}
+		if err := t.sendCandidate(t.client); err != nil {
+			t.out.Error("SocketWorker.keepAlive: %s", err)
+		}
 	} else {
 		if err := t.sendLogin(t.client); err != nil {
 			t.out.Error("SocketWorker.keepAlive: %s", err)
@@ -423,6 +594,36 @@ func (t *SocketWorker) keepAlive() {
 	}
 }
 
+func (t *SocketWorker) probeMtu() {
+	if t.pmtu == nil || !t.client.Have(libol.ClAuth) {
+		return
+	}
+	size := t.pmtu.Next(time.Now().Unix())
+	t.onPmtu()
+	t.setDontFrag(size > 0)
+	if size == 0 {
+		return
+	}
+	if err := t.sendProbe(t.client, size); err != nil {
+		t.out.Debug("SocketWorker.probeMtu: %s", err)
+	}
+}
+
+// setDontFrag sets DF bit only while searching, and restores it after.
+func (t *SocketWorker) setDontFrag(enable bool) {
+	if t.dontFrag == enable {
+		return
+	}
+	if obj, ok := t.client.(libol.DontFragger); ok {
+		if err := obj.SetDontFrag(enable); err != nil {
+			t.out.Warn("SocketWorker.setDontFrag: %s", err)
+			return
+		}
+		t.out.Info("SocketWorker.setDontFrag: %v", enable)
+		t.dontFrag = enable
+	}
+}
+
 func (t *SocketWorker) checkJobber() {
 	// travel jobber and execute it expired.
 	now := time.Now().Unix()
@@ -454,6 +655,7 @@ func (t *SocketWorker) checkAlive() {
 func (t *SocketWorker) doTicker() error {
 	t.checkAlive()  // period to check whether alive.
 	t.keepAlive()   // send ping and wait pong to keep alive.
+	t.probeMtu()    // send padded ping to discover mtu of path.
 	t.checkJobber() // period to check job whether timeout.
 	return nil
 }
@@ -469,8 +671,13 @@ func (t *SocketWorker) dispatch(ev *WorkerEvent) {
 			})
 		}
 	case EvSocSuccess:
+		if t.pmtu != nil {
+			t.pmtu.Reset()
+			t.dontFrag = false // default of new connection.
+		}
 		_ = t.toNetwork(t.client)
 		_ = t.sendPing(t.client)
+		_ = t.sendCandidate(t.client)
 	case EvSocRecon:
 		t.out.Info("SocketWorker.dispatch: %v", ev)
 		t.reconnect()
@@ -488,6 +695,7 @@ func (t *SocketWorker) Loop() {
 			t.lock.Unlock()
 		case d := <-t.writeQueue:
 			_ = t.DoWrite(d)
+			d.Release()
 		case <-t.done:
 			return
 		case c := <-t.ticker.C:
@@ -515,6 +723,7 @@ func (t *SocketWorker) Read(client libol.SocketClient) {
 			t.out.Debug("SocketWorker.Read: %x", data)
 		}
 		if data.Size() <= 0 {
+			data.Release()
 			continue
 		}
 		data.Decode()
@@ -522,6 +731,7 @@ func (t *SocketWorker) Read(client libol.SocketClient) {
 			t.lock.Lock()
 			_ = t.onInstruct(data)
 			t.lock.Unlock()
+			data.Release()
 			continue
 		}
 		t.record.Set(rtLast, time.Now().Unix())
@@ -578,6 +788,18 @@ func (t *SocketWorker) SetAuth(auth string) {
 	}
 }
 
+// SetBond joins this worker into a bond session.
+func (t *SocketWorker) SetBond(id, mode string, member bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.user.Bond = id
+	t.user.BondMode = mode
+	if !libol.HasFeature(t.user.Features, libol.FeatBond) {
+		t.user.Features = append(t.user.Features, libol.FeatBond)
+	}
+	t.member = member
+}
+
 func (t *SocketWorker) SetUUID(v string) {
 	t.lock.Lock()
 	defer t.lock.Unlock()
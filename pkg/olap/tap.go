@@ -8,6 +8,7 @@ import (
 	"net"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -37,6 +38,7 @@ type TapWorker struct {
 	done       chan bool
 	out        *libol.SubLogger
 	eventQueue chan *WorkerEvent
+	mss        int32 // clamp tcp syn if not zero.
 }
 
 func NewTapWorker(devCfg network.TapConfig, pinCfg *config.Point) (a *TapWorker) {
@@ -188,25 +190,49 @@ func (a *TapWorker) onFrame(frame *libol.FrameMessage, data []byte) int {
 		frame.Append(eth.Encode()) // insert ethernet header.
 		size += eth.Len
 	}
+	a.clampMss(data)
 	frame.SetSize(size)
 	return size
 }
 
+func (a *TapWorker) SetMss(value int) {
+	a.out.Info("TapWorker.SetMss: %d", value)
+	atomic.StoreInt32(&a.mss, int32(value))
+}
+
+// clampMss fits tcp segments into mtu of path.
+func (a *TapWorker) clampMss(data []byte) {
+	mss := atomic.LoadInt32(&a.mss)
+	if mss == 0 {
+		return
+	}
+	if !a.IsTun() {
+		eth, err := libol.NewEtherFromFrame(data)
+		if err != nil || !eth.IsIP4() {
+			return
+		}
+		data = data[eth.Len:]
+	}
+	libol.ClampMss(data, int(mss))
+}
+
 func (a *TapWorker) Read(device network.Taper) {
 	for {
-		frame := libol.NewFrameMessage(0)
+		frame := libol.AllocFrame(0)
 		data := frame.Frame()
 		if a.IsTun() {
 			data = data[libol.EtherLen:]
 		}
 		if n, err := device.Read(data); err != nil {
 			a.out.Error("TapWorker.Read: %s", err)
+			frame.Release()
 			break
 		} else {
 			if a.out.Has(libol.DEBUG) {
 				a.out.Debug("TapWorker.Read: %x", data[:n])
 			}
 			if size := a.onFrame(frame, data[:n]); size == 0 {
+				frame.Release()
 				continue
 			}
 			if a.listener.ReadAt != nil {
@@ -239,6 +265,7 @@ func (a *TapWorker) Loop() {
 			return
 		case d := <-a.writeQueue:
 			_ = a.DoWrite(d)
+			d.Release()
 		case ev := <-a.eventQueue:
 			a.lock.Lock()
 			a.dispatch(ev)
@@ -278,6 +305,7 @@ func (a *TapWorker) DoWrite(frame *libol.FrameMessage) error {
 		}
 	}
 	a.lock.Unlock()
+	a.clampMss(data) // syn-ack from peer.
 	if _, err := a.device.Write(data); err != nil {
 		a.out.Error("TapWorker.DoWrite: %s", err)
 		return err
@@ -366,6 +394,8 @@ func (a *TapWorker) Start() {
 }
 
 func (a *TapWorker) isStopped() bool {
+	a.lock.Lock()
+	defer a.lock.Unlock()
 	return a.device == nil
 }
 
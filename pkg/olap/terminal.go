@@ -27,6 +27,7 @@ func NewTerminal(pointer Pointer) *Terminal {
 			readline.PcItem("network"),
 			readline.PcItem("record"),
 			readline.PcItem("statistics"),
+			readline.PcItem("direct"),
 		),
 		readline.PcItem("edit",
 			readline.PcItem("user"),
@@ -82,6 +83,11 @@ func (t *Terminal) CmdShow(args string) {
 		if str, err := libol.Marshal(cfg, true); err == nil {
 			fmt.Printf("%s\n", str)
 		}
+	case "direct":
+		v := t.Pointer.Direct()
+		if str, err := libol.Marshal(v, true); err == nil {
+			fmt.Printf("%s\n", str)
+		}
 	default:
 		v := struct {
 			UUID   string
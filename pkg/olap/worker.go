@@ -71,6 +71,7 @@ type WorkerListener struct {
 	OnTap     func(w *TapWorker) error
 	AddRoutes func(routes []*models.Route) error
 	DelRoutes func(routes []*models.Route) error
+	SetMtu    func(mtu int) error
 }
 
 type PrefixRule struct {
@@ -79,10 +80,19 @@ type PrefixRule struct {
 	NextHop     net.IP
 }
 
+// GetProxy returns upstream proxy of point, and fallback to
+// HTTPS_PROXY from environment.
+func GetProxy(p *config.Point) string {
+	if p.Proxy != "" {
+		return p.Proxy
+	}
+	return libol.GetProxyEnv(p.Connection)
+}
+
 func GetSocketClient(p *config.Point) libol.SocketClient {
 	switch p.Protocol {
 	case "kcp":
-		c := libol.NewKcpConfig()
+		c := config.GetKcp(p.Kcp)
 		c.Block = config.GetBlock(p.Crypt)
 		c.RdQus = p.Queue.SockRd
 		c.WrQus = p.Queue.SockWr
@@ -92,6 +102,7 @@ func GetSocketClient(p *config.Point) libol.SocketClient {
 			Block: config.GetBlock(p.Crypt),
 			RdQus: p.Queue.SockRd,
 			WrQus: p.Queue.SockWr,
+			Proxy: GetProxy(p),
 		}
 		return libol.NewTcpClient(p.Connection, c)
 	case "udp":
@@ -100,6 +111,8 @@ func GetSocketClient(p *config.Point) libol.SocketClient {
 			Timeout: time.Duration(p.Timeout) * time.Second,
 			RdQus:   p.Queue.SockRd,
 			WrQus:   p.Queue.SockWr,
+			Batch:   p.Queue.SockBt,
+			Offload: p.Queue.SockOl,
 		}
 		return libol.NewUdpClient(p.Connection, c)
 	case "ws":
@@ -107,6 +120,7 @@ func GetSocketClient(p *config.Point) libol.SocketClient {
 			Block: config.GetBlock(p.Crypt),
 			RdQus: p.Queue.SockRd,
 			WrQus: p.Queue.SockWr,
+			Proxy: GetProxy(p),
 		}
 		return libol.NewWebClient(p.Connection, c)
 	case "wss":
@@ -114,6 +128,7 @@ func GetSocketClient(p *config.Point) libol.SocketClient {
 			Block: config.GetBlock(p.Crypt),
 			RdQus: p.Queue.SockRd,
 			WrQus: p.Queue.SockWr,
+			Proxy: GetProxy(p),
 		}
 		if p.Cert != nil {
 			c.Cert = &libol.WebCert{
@@ -122,11 +137,19 @@ func GetSocketClient(p *config.Point) libol.SocketClient {
 			}
 		}
 		return libol.NewWebClient(p.Connection, c)
+	case "mem":
+		c := &libol.MemConfig{
+			Block: config.GetBlock(p.Crypt),
+			RdQus: p.Queue.SockRd,
+			WrQus: p.Queue.SockWr,
+		}
+		return libol.NewMemClient(p.Connection, c)
 	default:
 		c := &libol.TcpConfig{
 			Block: config.GetBlock(p.Crypt),
 			RdQus: p.Queue.SockRd,
 			WrQus: p.Queue.SockWr,
+			Proxy: GetProxy(p),
 		}
 		if p.Cert != nil {
 			c.Tls = &tls.Config{
@@ -159,7 +182,12 @@ type Worker struct {
 	ifAddr    string
 	listener  WorkerListener
 	conWorker *SocketWorker
+	bonWorker []*SocketWorker // links of bond.
+	bundle    *libol.Bundle
+	links     map[libol.SocketClient]*SocketWorker
+	bonded    bool
 	tapWorker *TapWorker
+	dirWorker *DirectWorker
 	cfg       *config.Point
 	uuid      string
 	network   *models.Network
@@ -196,14 +224,27 @@ func (w *Worker) Initialize() {
 	// register listener
 	w.tapWorker = NewTapWorker(tapCfg, w.cfg)
 
+	if w.cfg.Direct != nil {
+		w.dirWorker = NewDirectWorker(w.cfg)
+		w.dirWorker.listener = DirectWorkerListener{
+			ReadAt: w.tapWorker.Write,
+		}
+		w.dirWorker.Initialize()
+		w.conWorker.direct = w.dirWorker
+	}
+
 	w.conWorker.SetUUID(w.UUID())
 	w.conWorker.listener = SocketWorkerListener{
 		OnClose:   w.OnClose,
 		OnSuccess: w.OnSuccess,
 		OnIpAddr:  w.OnIpAddr,
+		OnPmtu:    w.OnPmtu,
 		ReadAt:    w.tapWorker.Write,
 	}
 	w.conWorker.Initialize()
+	if w.cfg.Bond != nil {
+		w.newBond()
+	}
 
 	w.tapWorker.listener = TapWorkerListener{
 		OnOpen: func(t *TapWorker) error {
@@ -220,12 +261,51 @@ func (w *Worker) Initialize() {
 			}
 			return nil
 		},
-		ReadAt:   w.conWorker.Write,
+		ReadAt:   w.toSwitch,
 		FindNext: w.FindNext,
 	}
 	w.tapWorker.Initialize()
 }
 
+func (w *Worker) newBond() {
+	bond := w.cfg.Bond
+	id := libol.GenRandom(13)
+	w.out.Info("Worker.newBond: %s with %d links", id, len(bond.Links))
+	w.bundle = libol.NewBundle(bond.Mode)
+	w.links = make(map[libol.SocketClient]*SocketWorker, 4)
+	w.conWorker.SetBond(id, w.bundle.Mode(), false)
+	w.bundle.Add(w.conWorker.client)
+	w.links[w.conWorker.client] = w.conWorker
+	for _, cfg := range bond.Points(w.cfg) {
+		client := GetSocketClient(cfg)
+		s := NewSocketWorker(client, cfg)
+		s.SetUUID(w.UUID())
+		s.SetBond(id, w.bundle.Mode(), true)
+		s.listener = SocketWorkerListener{
+			ReadAt: w.tapWorker.Write,
+		}
+		s.Initialize()
+		w.bundle.Add(client)
+		w.links[client] = s
+		w.bonWorker = append(w.bonWorker, s)
+	}
+}
+
+// startBond starts links once switch supports bond.
+func (w *Worker) startBond(s *SocketWorker) {
+	if w.bundle == nil || w.bonded {
+		return
+	}
+	if !s.Feature(libol.FeatBond) {
+		w.out.Warn("Worker.startBond: notSupport")
+		return
+	}
+	w.bonded = true
+	for _, link := range w.bonWorker {
+		link.Start()
+	}
+}
+
 func (w *Worker) FlushStatus() {
 	file := w.cfg.StatusFile
 	device := w.tapWorker.device
@@ -246,6 +326,7 @@ func (w *Worker) FlushStatus() {
 		User:      strings.SplitN(w.cfg.Username, "@", 2)[0],
 		Remote:    w.cfg.Connection,
 		AliveTime: client.AliveTime(),
+		Mtu:       int(w.conWorker.record.Get(rtPmtu)),
 		UUID:      w.uuid,
 		Alias:     w.cfg.Alias,
 		System:    runtime.GOOS,
@@ -253,6 +334,9 @@ func (w *Worker) FlushStatus() {
 	if w.network != nil {
 		status.Address = models.NewNetworkSchema(w.network)
 	}
+	if w.dirWorker != nil {
+		status.Direct = models.NewDirectSchema(w.dirWorker.Paths())
+	}
 	_ = libol.MarshalSave(status, file, true)
 }
 
@@ -261,6 +345,9 @@ func (w *Worker) Start() {
 	w.FlushStatus()
 	w.tapWorker.Start()
 	w.conWorker.Start()
+	if w.dirWorker != nil {
+		w.dirWorker.Start()
+	}
 	libol.Go(func() {
 		for {
 			select {
@@ -280,11 +367,33 @@ func (w *Worker) Stop() {
 	w.done <- true
 	w.FreeIpAddr()
 	w.conWorker.Stop()
+	if w.bonded {
+		for _, link := range w.bonWorker {
+			link.Stop()
+		}
+	}
 	w.tapWorker.Stop()
+	if w.dirWorker != nil {
+		w.dirWorker.Stop()
+	}
 	w.conWorker = nil
 	w.tapWorker = nil
 }
 
+// send frame to peer directly, and fallback to switch.
+func (w *Worker) toSwitch(frame *libol.FrameMessage) error {
+	if w.dirWorker != nil && w.dirWorker.Forward(frame) {
+		frame.Release()
+		return nil
+	}
+	if w.bundle != nil {
+		if client := w.bundle.Pick(frame); client != nil {
+			return w.links[client].Write(frame)
+		}
+	}
+	return w.conWorker.Write(frame)
+}
+
 func (w *Worker) UpTime() int64 {
 	client := w.conWorker.client
 	if client != nil {
@@ -377,6 +486,24 @@ func (w *Worker) OnSuccess(s *SocketWorker) error {
 	if w.listener.AddAddr != nil {
 		_ = w.listener.AddAddr(w.ifAddr)
 	}
+	w.startBond(s)
+	return nil
+}
+
+// OnPmtu fits frames from tap device into mtu of path.
+func (w *Worker) OnPmtu(s *SocketWorker, mtu int) error {
+	mode := w.cfg.Pmtu.Mode
+	w.out.Info("Worker.OnPmtu: %d by %s", mtu, mode)
+	switch mode {
+	case config.PmtuMtu:
+		if w.listener.SetMtu != nil {
+			return w.listener.SetMtu(mtu)
+		}
+		w.out.Warn("Worker.OnPmtu: mtu notSupport, and clamp mss")
+		w.tapWorker.SetMss(mtu - 40)
+	case config.PmtuMss:
+		w.tapWorker.SetMss(mtu - 40) // ip and tcp header.
+	}
 	return nil
 }
 
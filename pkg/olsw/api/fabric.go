@@ -0,0 +1,20 @@
+package api
+
+import (
+	"github.com/gorilla/mux"
+	"net/http"
+)
+
+type Fabric struct {
+	Switcher Switcher
+}
+
+func (l Fabric) Router(router *mux.Router) {
+	router.HandleFunc("/api/fabric", l.List).Methods("GET")
+	router.HandleFunc("/api/fabric/{id}", l.List).Methods("GET")
+}
+
+func (l Fabric) List(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	ResponseJson(w, l.Switcher.ListFabric(vars["id"]))
+}
@@ -0,0 +1,141 @@
+package api
+
+import (
+	"github.com/danieldin95/openlan/pkg/config"
+	"github.com/danieldin95/openlan/pkg/schema"
+	"github.com/gorilla/mux"
+	"net/http"
+)
+
+type Gre struct {
+	Switcher Switcher
+}
+
+func (l Gre) Router(router *mux.Router) {
+	router.HandleFunc("/api/gre", l.List).Methods("GET")
+	router.HandleFunc("/api/gre/{id}", l.List).Methods("GET")
+	router.HandleFunc("/api/gre/{id}", l.Add).Methods("POST")
+	router.HandleFunc("/api/gre/{id}", l.Del).Methods("DELETE")
+	router.HandleFunc("/api/gre/{id}/member", l.AddMember).Methods("POST")
+	router.HandleFunc("/api/gre/{id}/member", l.DelMember).Methods("DELETE")
+}
+
+func NewGreSchema(c *config.Network) schema.Gre {
+	obj := schema.Gre{
+		Name:    c.Name,
+		Members: make([]schema.GreMember, 0, 32),
+	}
+	if c.Bridge != nil {
+		obj.Bridge = c.Bridge.Name
+	}
+	if spec, ok := c.Specifies.(*config.GreSpecifies); ok {
+		obj.Mode = spec.Mode
+		for _, mem := range spec.Members {
+			obj.Members = append(obj.Members, schema.GreMember{
+				Name:    mem.Name,
+				Local:   mem.Local,
+				Remote:  mem.Remote,
+				Key:     mem.Key,
+				Address: mem.Address,
+				Ttl:     mem.Ttl,
+			})
+		}
+	}
+	return obj
+}
+
+func SchemaToGreMember(s *schema.GreMember) *config.GreMember {
+	return &config.GreMember{
+		Name:    s.Name,
+		Local:   s.Local,
+		Remote:  s.Remote,
+		Key:     s.Key,
+		Address: s.Address,
+		Ttl:     s.Ttl,
+	}
+}
+
+func (l Gre) List(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	name := vars["id"]
+	data := make([]schema.Gre, 0, 32)
+	for _, c := range l.Switcher.Config().Network {
+		if c.Provider != "gre" {
+			continue
+		}
+		if name != "" && c.Name != name {
+			continue
+		}
+		data = append(data, NewGreSchema(c))
+	}
+	ResponseJson(w, data)
+}
+
+func (l Gre) Add(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	data := &schema.Gre{}
+	if err := GetData(r, data); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	spec := &config.GreSpecifies{Mode: data.Mode}
+	for i := range data.Members {
+		spec.Members = append(spec.Members, SchemaToGreMember(&data.Members[i]))
+	}
+	if err := l.Switcher.AddGre(vars["id"], spec); err != nil {
+		ResponseErr(w, err)
+		return
+	}
+	ResponseMsg(w, 0, "")
+}
+
+func (l Gre) Del(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if err := l.Switcher.DelGre(vars["id"], nil); err != nil {
+		ResponseErr(w, err)
+		return
+	}
+	ResponseMsg(w, 0, "")
+}
+
+func (l Gre) AddMember(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	data := &schema.GreMember{}
+	if err := GetData(r, data); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if data.Remote == "" {
+		http.Error(w, "remote is required", http.StatusBadRequest)
+		return
+	}
+	spec := &config.GreSpecifies{
+		Members: []*config.GreMember{SchemaToGreMember(data)},
+	}
+	if err := l.Switcher.AddGre(vars["id"], spec); err != nil {
+		ResponseErr(w, err)
+		return
+	}
+	ResponseMsg(w, 0, "")
+}
+
+func (l Gre) DelMember(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	data := &schema.GreMember{}
+	if err := GetData(r, data); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if data.Remote == "" && data.Name == "" {
+		http.Error(w, "name or remote is required", http.StatusBadRequest)
+		return
+	}
+	spec := &config.GreSpecifies{
+		Members: []*config.GreMember{SchemaToGreMember(data)},
+	}
+	if err := l.Switcher.DelGre(vars["id"], spec); err != nil {
+		ResponseErr(w, err)
+		return
+	}
+	ResponseMsg(w, 0, "")
+}
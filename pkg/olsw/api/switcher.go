@@ -17,8 +17,11 @@ type Switcher interface {
 	DelLink(tenant, addr string)
 	AddEsp(tenant string, c *config.ESPSpecifies)
 	DelEsp(tenant, c *config.ESPSpecifies)
-	AddVxLAN(tenant string, c *config.VxLANSpecifies)
-	DelVxLAN(tenant, c *config.VxLANSpecifies)
+	AddVxLAN(tenant string, c *config.VxLANSpecifies) error
+	DelVxLAN(tenant string, c *config.VxLANSpecifies) error
+	ListFabric(tenant string) []schema.Fabric
+	AddGre(tenant string, c *config.GreSpecifies) error
+	DelGre(tenant string, c *config.GreSpecifies) error
 	Firewall() *network.FireWall
 	Reload()
 	Save()
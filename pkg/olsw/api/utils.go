@@ -2,6 +2,7 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"github.com/danieldin95/openlan/pkg/libol"
 	"github.com/danieldin95/openlan/pkg/schema"
 	"gopkg.in/yaml.v2"
@@ -28,6 +29,22 @@ func ResponseMsg(w http.ResponseWriter, code int, message string) {
 	ResponseJson(w, ret)
 }
 
+var (
+	ErrNotFound = libol.NewErr("notFound")
+	ErrConflict = libol.NewErr("conflict")
+)
+
+// ResponseErr maps error of switcher to status code.
+func ResponseErr(w http.ResponseWriter, err error) {
+	code := http.StatusInternalServerError
+	if errors.Is(err, ErrNotFound) {
+		code = http.StatusNotFound
+	} else if errors.Is(err, ErrConflict) {
+		code = http.StatusConflict
+	}
+	http.Error(w, err.Error(), code)
+}
+
 func ResponseYaml(w http.ResponseWriter, v interface{}) {
 	str, err := yaml.Marshal(v)
 	if err == nil {
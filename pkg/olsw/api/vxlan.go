@@ -1,6 +1,8 @@
 package api
 
 import (
+	"github.com/danieldin95/openlan/pkg/config"
+	"github.com/danieldin95/openlan/pkg/schema"
 	"github.com/gorilla/mux"
 	"net/http"
 )
@@ -12,8 +14,128 @@ type VxLAN struct {
 func (l VxLAN) Router(router *mux.Router) {
 	router.HandleFunc("/api/vxlan", l.List).Methods("GET")
 	router.HandleFunc("/api/vxlan/{id}", l.List).Methods("GET")
+	router.HandleFunc("/api/vxlan/{id}", l.Add).Methods("POST")
+	router.HandleFunc("/api/vxlan/{id}", l.Del).Methods("DELETE")
+	router.HandleFunc("/api/vxlan/{id}/member", l.AddMember).Methods("POST")
+	router.HandleFunc("/api/vxlan/{id}/member", l.DelMember).Methods("DELETE")
+}
+
+func NewVxLANSchema(c *config.Network) schema.VxLAN {
+	obj := schema.VxLAN{
+		Name:    c.Name,
+		Members: make([]schema.VxLANMember, 0, 32),
+	}
+	if c.Bridge != nil {
+		obj.Bridge = c.Bridge.Name
+	}
+	if spec, ok := c.Specifies.(*config.VxLANSpecifies); ok {
+		obj.Mode = spec.Mode
+		for _, mem := range spec.Members {
+			obj.Members = append(obj.Members, schema.VxLANMember{
+				Name:   mem.Name,
+				Vni:    mem.VNI,
+				Local:  mem.Local,
+				Remote: mem.Remote,
+				Port:   mem.Port,
+				Macs:   mem.Macs,
+			})
+		}
+	}
+	return obj
+}
+
+func SchemaToVxLANMember(s *schema.VxLANMember) *config.VxLANMember {
+	return &config.VxLANMember{
+		Name:   s.Name,
+		VNI:    s.Vni,
+		Local:  s.Local,
+		Remote: s.Remote,
+		Port:   s.Port,
+		Macs:   s.Macs,
+	}
 }
 
 func (l VxLAN) List(w http.ResponseWriter, r *http.Request) {
-	ResponseJson(w, nil)
+	vars := mux.Vars(r)
+	name := vars["id"]
+	data := make([]schema.VxLAN, 0, 32)
+	for _, c := range l.Switcher.Config().Network {
+		if c.Provider != "vxlan" {
+			continue
+		}
+		if name != "" && c.Name != name {
+			continue
+		}
+		data = append(data, NewVxLANSchema(c))
+	}
+	ResponseJson(w, data)
+}
+
+func (l VxLAN) Add(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	data := &schema.VxLAN{}
+	if err := GetData(r, data); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	spec := &config.VxLANSpecifies{Mode: data.Mode}
+	for i := range data.Members {
+		spec.Members = append(spec.Members, SchemaToVxLANMember(&data.Members[i]))
+	}
+	if err := l.Switcher.AddVxLAN(vars["id"], spec); err != nil {
+		ResponseErr(w, err)
+		return
+	}
+	ResponseMsg(w, 0, "")
+}
+
+func (l VxLAN) Del(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if err := l.Switcher.DelVxLAN(vars["id"], nil); err != nil {
+		ResponseErr(w, err)
+		return
+	}
+	ResponseMsg(w, 0, "")
+}
+
+func (l VxLAN) AddMember(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	data := &schema.VxLANMember{}
+	if err := GetData(r, data); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if data.Vni == 0 || data.Remote == "" {
+		http.Error(w, "vni and remote are required", http.StatusBadRequest)
+		return
+	}
+	spec := &config.VxLANSpecifies{
+		Members: []*config.VxLANMember{SchemaToVxLANMember(data)},
+	}
+	if err := l.Switcher.AddVxLAN(vars["id"], spec); err != nil {
+		ResponseErr(w, err)
+		return
+	}
+	ResponseMsg(w, 0, "")
+}
+
+func (l VxLAN) DelMember(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	data := &schema.VxLANMember{}
+	if err := GetData(r, data); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if data.Vni == 0 && data.Name == "" {
+		http.Error(w, "name or vni is required", http.StatusBadRequest)
+		return
+	}
+	spec := &config.VxLANSpecifies{
+		Members: []*config.VxLANMember{SchemaToVxLANMember(data)},
+	}
+	if err := l.Switcher.DelVxLAN(vars["id"], spec); err != nil {
+		ResponseErr(w, err)
+		return
+	}
+	ResponseMsg(w, 0, "")
 }
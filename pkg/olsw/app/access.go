@@ -29,15 +29,15 @@ func (p *Access) OnFrame(client libol.SocketClient, frame *libol.FrameMessage) e
 		out.Debug("Access.OnFrame: %s", action)
 		switch action {
 		case libol.LoginReq:
-			if err := p.handleLogin(client, params); err != nil {
+			user, err := p.handleLogin(client, params)
+			if err != nil {
 				out.Error("Access.OnFrame: %s", err)
-				m := libol.NewControlFrame(libol.LoginResp, []byte(err.Error()))
-				_ = client.WriteMsg(m)
+			}
+			p.reply(client, user, err)
+			if err != nil {
 				//client.Close()
 				return err
 			}
-			m := libol.NewControlFrame(libol.LoginResp, []byte("okay"))
-			_ = client.WriteMsg(m)
 		}
 		//If instruct is not login and already auth, continue to process.
 		if client.Have(libol.ClAuth) {
@@ -52,34 +52,117 @@ func (p *Access) OnFrame(client libol.SocketClient, frame *libol.FrameMessage) e
 	return nil
 }
 
-func (p *Access) handleLogin(client libol.SocketClient, data []byte) error {
+// reply to login, and legacy text if client not supports negotiation.
+func (p *Access) reply(client libol.SocketClient, user *models.User, err error) {
 	out := client.Out()
-	out.Debug("Access.handleLogin: %s", data)
-	if client.Have(libol.ClAuth) {
-		out.Warn("Access.handleLogin: already auth")
-		return nil
+	var body []byte
+	if user == nil || user.Version == 0 {
+		if err != nil {
+			body = []byte(err.Error())
+		} else {
+			body = []byte("okay")
+		}
+	} else {
+		features := user.Features
+		if err != nil {
+			features = nil
+		}
+		reply := libol.NewLoginReply(err, features)
+		body, _ = json.Marshal(reply)
 	}
+	out.Cmd("Access.reply: %s", body)
+	m := libol.NewControlFrame(libol.LoginResp, body)
+	_ = client.WriteMsg(m)
+}
+
+func (p *Access) handleLogin(client libol.SocketClient, data []byte) (*models.User, error) {
+	out := client.Out()
+	out.Debug("Access.handleLogin: %s", data)
 	user := &models.User{}
 	if err := json.Unmarshal(data, user); err != nil {
-		return libol.NewErr("Invalid json data.")
+		return nil, libol.NewLoginErr(libol.LoginInvalid, "Invalid json data.")
+	}
+	if user.Version > 0 {
+		local := libol.CryptOf(p.master.Features())
+		if remote := libol.CryptOf(user.Features); remote != local {
+			p.failed++
+			client.SetStatus(libol.ClUnAuth)
+			return user, libol.NewLoginErr(libol.LoginCrypt, "crypt %q not matched", remote)
+		}
+		user.Features = libol.Negotiate(p.master.Features(), user.Features)
+	} else {
+		user.Features = libol.LegacyFeatures
+	}
+	if client.Have(libol.ClAuth) {
+		out.Warn("Access.handleLogin: already auth")
+		if libol.HasFeature(user.Features, libol.FeatRoaming) {
+			p.onRoaming(client, user)
+		}
+		return user, nil
 	}
 	user.Update()
 	out.Info("Access.handleLogin: %s on %s", user.Id(), user.Alias)
-	if now, _ := cache.User.Check(user); now != nil {
-		if now.Role != "admin" && now.Last != nil {
-			// To offline lastly client if guest.
-			p.master.OffClient(now.Last)
+	now, err := cache.User.Check(user)
+	if now == nil {
+		p.failed++
+		client.SetStatus(libol.ClUnAuth)
+		if _, ok := err.(*libol.LoginError); !ok {
+			err = libol.NewLoginErr(libol.LoginWrong, "Auth failed.")
 		}
-		p.success++
+		return user, err
+	}
+	bond := p.findBond(user)
+	if now.Role != "admin" && now.Last != nil && bond == nil {
+		// To offline lastly client if guest.
+		p.master.OffClient(now.Last)
+	}
+	client.SetStatus(libol.ClAuth)
+	if bond != nil {
+		err = p.onJoin(client, user, bond)
+	} else {
+		err = p.onAuth(client, user)
+	}
+	if err != nil {
+		p.failed++
+		client.SetStatus(libol.ClUnAuth)
+		return user, err
+	}
+	if zip := p.master.Compressor(); zip != nil && libol.HasFeature(user.Features, zip.Feature()) {
+		client.SetCompressor(zip)
+	}
+	if libol.HasFeature(user.Features, libol.FeatRoaming) {
+		p.onRoaming(client, user)
+	}
+	p.success++
+	if bond == nil {
 		now.Last = client
-		client.SetStatus(libol.ClAuth)
-		out.Info("Access.handleLogin: success")
-		_ = p.onAuth(client, user)
-		return nil
 	}
-	p.failed++
-	client.SetStatus(libol.ClUnAuth)
-	return libol.NewErr("Auth failed.")
+	out.Info("Access.handleLogin: success with %v", user.Features)
+	return user, nil
+}
+
+// onRoaming binds session of user to client, and roaming is not replied
+// if failed.
+func (p *Access) onRoaming(client libol.SocketClient, user *models.User) {
+	s, err := libol.ParseXdpSession(user.Session)
+	user.Session = "" // key is not kept.
+	if err == nil {
+		if obj, ok := client.(libol.Sessioner); ok {
+			err = obj.SetSession(s)
+		} else {
+			err = libol.NewErr("notSupport")
+		}
+	}
+	if err != nil {
+		client.Out().Warn("Access.onRoaming: %s", err)
+		features := make([]string, 0, len(user.Features))
+		for _, name := range user.Features {
+			if name != libol.FeatRoaming {
+				features = append(features, name)
+			}
+		}
+		user.Features = features
+	}
 }
 
 func (p *Access) onAuth(client libol.SocketClient, user *models.User) error {
@@ -88,23 +171,41 @@ func (p *Access) onAuth(client libol.SocketClient, user *models.User) error {
 		return libol.NewErr("not auth.")
 	}
 	out.Info("Access.onAuth")
+	om := cache.Point.GetByUUID(user.ShortUUID())
+	if om == nil && cache.Point.Full() {
+		return libol.NewLoginErr(libol.LoginFull, "network already full")
+	}
 	dev, err := p.master.NewTap(user.Network)
 	if err != nil {
-		return err
+		return libol.NewLoginErr(libol.LoginNotFound, "%s", err)
 	}
 	out.Info("Access.onAuth: on >>> %s <<<", dev.Name())
 	proto := p.master.Protocol()
 	m := models.NewPoint(client, dev, proto)
 	m.SetUser(user)
 	// free point has same uuid.
-	if om := cache.Point.GetByUUID(m.UUID); om != nil {
-		out.Info("Access.onAuth: OffClient %s", om.Client)
-		p.master.OffClient(om.Client)
+	if om != nil {
+		clients := om.Clients()
+		om.SetBundle(nil)
+		for _, c := range clients {
+			out.Info("Access.onAuth: OffClient %s", c)
+			p.master.OffClient(c)
+		}
+	}
+	var bond *libol.Bundle
+	if user.Bond != "" && libol.HasFeature(user.Features, libol.FeatBond) {
+		bond = libol.NewBundle(user.BondMode)
+		bond.Add(client)
+		m.Bond = user.Bond
+		m.SetBundle(bond)
 	}
 	client.SetPrivate(m)
 	cache.Point.Add(m)
 	libol.Go(func() {
 		p.master.ReadTap(dev, func(f *libol.FrameMessage) error {
+			if bond != nil {
+				return p.writeBond(bond, f)
+			}
 			if err := client.WriteMsg(f); err != nil {
 				p.master.OffClient(client)
 				return err
@@ -115,6 +216,54 @@ func (p *Access) onAuth(client libol.SocketClient, user *models.User) error {
 	return nil
 }
 
+// writeBond drops frame if failed while bond still has members, and
+// tap is closed only if no one left, as point is freed by last member.
+func (p *Access) writeBond(bond *libol.Bundle, f *libol.FrameMessage) error {
+	err := bond.WriteMsg(f)
+	if err != nil && bond.Len() > 0 {
+		libol.Debug("Access.writeBond: drop %s", err)
+		return nil
+	}
+	return err
+}
+
+// findBond returns point if user is a new connection of its bond.
+func (p *Access) findBond(user *models.User) *models.Point {
+	if user.Bond == "" || !libol.HasFeature(user.Features, libol.FeatBond) {
+		return nil
+	}
+	om := cache.Point.GetByUUID(user.ShortUUID())
+	if om == nil || om.Bond != user.Bond || om.Bundle() == nil {
+		return nil
+	}
+	if !ownBond(om, user) {
+		libol.Warn("Access.findBond: %s not owned by %s", om.Bond, user.Id())
+		return nil
+	}
+	return om
+}
+
+// ownBond returns true if point is logged in by the user.
+func ownBond(m *models.Point, user *models.User) bool {
+	return m.User == user.Name && m.Network == user.Network
+}
+
+// onJoin adds client into bond, and frames from it are written to same tap.
+func (p *Access) onJoin(client libol.SocketClient, user *models.User, m *models.Point) error {
+	out := client.Out()
+	if !ownBond(m, user) {
+		return libol.NewLoginErr(libol.LoginWrong, "bond %s not owned", m.Bond)
+	}
+	bond := m.Bundle()
+	if bond == nil {
+		return libol.NewLoginErr(libol.LoginFailed, "bond %s already closed", m.Bond)
+	}
+	client.SetPrivate(m)
+	bond.Add(client)
+	out.Info("Access.onJoin: %s with %d members", m.Bond, bond.Len())
+	return nil
+}
+
 func (p *Access) Stats() (success, failed int) {
 	return p.success, p.failed
 }
@@ -0,0 +1,51 @@
+package app
+
+import (
+	"encoding/json"
+	"github.com/danieldin95/openlan/pkg/libol"
+	"github.com/danieldin95/openlan/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+)
+
+func TestAccess_WriteBond(t *testing.T) {
+	a, b := net.Pipe()
+	defer b.Close()
+	c := libol.NewTcpClientFromConn(a, &libol.TcpConfig{})
+	bond := libol.NewBundle(libol.BondHash)
+	bond.Add(c)
+	p := &Access{}
+	frame := libol.NewFrameMessage(64)
+	// member is not ready, and frame is dropped.
+	assert.Nil(t, p.writeBond(bond, frame), "be nil.")
+	bond.Remove(c)
+	assert.NotNil(t, p.writeBond(bond, frame), "be not nil.")
+}
+
+type fakeMaster struct {
+	Master
+	features []string
+}
+
+func (m *fakeMaster) Features() []string {
+	return m.features
+}
+
+func TestAccess_LoginCrypt(t *testing.T) {
+	a, b := net.Pipe()
+	defer b.Close()
+	c := libol.NewTcpClientFromConn(a, &libol.TcpConfig{})
+	p := NewAccess(&fakeMaster{features: []string{libol.FeatRoute, "crypt:aes-256"}})
+	user := &models.User{
+		Name:     "hi",
+		Version:  libol.ProtoVersion,
+		Features: []string{libol.FeatRoute, "crypt:aes-128"},
+	}
+	data, _ := json.Marshal(user)
+	_, err := p.handleLogin(c, data)
+	e, ok := err.(*libol.LoginError)
+	assert.Equal(t, true, ok, "be the same.")
+	assert.Equal(t, libol.LoginCrypt, e.Code, "be the same.")
+	assert.Equal(t, false, c.Have(libol.ClAuth), "be the same.")
+}
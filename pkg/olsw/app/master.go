@@ -8,6 +8,8 @@ import (
 type Master interface {
 	UUID() string
 	Protocol() string
+	Features() []string
+	Compressor() *libol.Compressor
 	OffClient(client libol.SocketClient)
 	ReadTap(device network.Taper, readAt func(f *libol.FrameMessage) error)
 	NewTap(tenant string) (network.Taper, error)
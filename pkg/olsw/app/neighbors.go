@@ -1,23 +1,40 @@
 package app
 
 import (
+	"bytes"
 	"github.com/danieldin95/openlan/pkg/libol"
 	"github.com/danieldin95/openlan/pkg/models"
 	"github.com/danieldin95/openlan/pkg/olsw/cache"
 	"net"
+	"sync"
 	"time"
 )
 
+const (
+	// neighbor not hit in this seconds is allowed to move to other point,
+	// and not proxied.
+	NeighborStale = 120
+)
+
 type Neighbors struct {
+	lock   sync.Mutex
 	master Master
+	proxy  bool
+	out    *libol.SubLogger
 }
 
 func NewNeighbors(m Master) *Neighbors {
 	return &Neighbors{
 		master: m,
+		out:    libol.NewSubLogger("neighbor"),
 	}
 }
 
+// SetProxy enables to answer arp request for known neighbors by switch.
+func (e *Neighbors) SetProxy(enable bool) {
+	e.proxy = enable
+}
+
 func (e *Neighbors) OnFrame(client libol.SocketClient, frame *libol.FrameMessage) error {
 	if frame.IsControl() {
 		return nil
@@ -38,14 +55,84 @@ func (e *Neighbors) OnFrame(client libol.SocketClient, frame *libol.FrameMessage
 		n := models.NewNeighbor(arp.SHwAddr, arp.SIpAddr, client)
 		e.AddNeighbor(n, client)
 	}
+	if e.proxy && arp.IsIP4() && arp.IsRequest() {
+		if e.Reply(client, arp) {
+			return libol.NewErr("arp proxied")
+		}
+	}
 	return nil
 }
 
+// Reply answers the arp request to client if target is known, and
+// returns true when replied.
+func (e *Neighbors) Reply(client libol.SocketClient, arp *libol.Arp) bool {
+	target := net.IP(arp.TIpAddr)
+	n := cache.Neighbor.Get(target.String())
+	if n == nil {
+		return false
+	}
+	hwAddr, owner, network := n.Owner()
+	if bytes.Equal(hwAddr, arp.SHwAddr) || network != networkOf(client) {
+		return false
+	}
+	// owner is ambiguous, and leaves it to the hosts.
+	if n.InConflict() {
+		return false
+	}
+	// owner may be gone or moved, and not proxied.
+	if n.UpTime() >= NeighborStale || !cache.Point.Online(owner) {
+		return false
+	}
+	eth := libol.NewEtherArp()
+	copy(eth.Dst, arp.SHwAddr)
+	copy(eth.Src, hwAddr)
+	reply := libol.NewArp()
+	reply.OpCode = libol.ArpReply
+	copy(reply.SHwAddr, hwAddr)
+	copy(reply.SIpAddr, arp.TIpAddr)
+	copy(reply.THwAddr, arp.SHwAddr)
+	copy(reply.TIpAddr, arp.SIpAddr)
+
+	frame := libol.NewFrameMessage(0)
+	frame.Append(eth.Encode())
+	frame.Append(reply.Encode())
+	if err := client.WriteMsg(frame); err != nil {
+		libol.Warn("Neighbors.Reply %s %s", client, err)
+		return false
+	}
+	if libol.HasLog(libol.DEBUG) {
+		libol.Debug("Neighbors.Reply %s is-at %s to %s", target, hwAddr, client)
+	}
+	return true
+}
+
+func networkOf(client libol.SocketClient) string {
+	if client == nil {
+		return ""
+	}
+	if point, ok := client.Private().(*models.Point); ok {
+		return point.Network
+	}
+	return ""
+}
+
+// AddNeighbor is called by goroutine of each client, so is serialized.
 func (e *Neighbors) AddNeighbor(new *models.Neighbor, client libol.SocketClient) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
 	if n := cache.Neighbor.Get(new.IpAddr.String()); n != nil {
+		hwAddr, owner, network := n.Owner()
+		if owner != new.Client && !bytes.Equal(hwAddr, new.HwAddr) &&
+			network == new.Network && n.UpTime() < NeighborStale {
+			e.out.Warn("Neighbors.AddNeighbor: %s claimed by %s and %s",
+				new.IpAddr, owner, new.Client)
+			e.out.Event("Neighbors.AddNeighbor: duplicate %s on %s and %s",
+				new.IpAddr, hwAddr, new.HwAddr)
+			n.SetConflict(time.Now().Unix() + NeighborStale)
+			return
+		}
 		libol.Log("Neighbors.AddNeighbor: update %s.", new)
-		n.Update(client)
-		n.HitTime = time.Now().Unix()
+		n.Hit(new.HwAddr, client)
 	} else {
 		libol.Log("Neighbors.AddNeighbor: new %s.", new)
 		cache.Neighbor.Add(new)
@@ -59,7 +146,22 @@ func (e *Neighbors) DelNeighbor(ipAddr net.IP) {
 	}
 }
 
+// OnClientClose deletes neighbors learned from the client.
 func (e *Neighbors) OnClientClose(client libol.SocketClient) {
-	//TODO
 	libol.Info("Neighbors.OnClientClose %s.", client)
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	addr := client.String()
+	deletes := make([]string, 0, 32)
+	for n := range cache.Neighbor.List() {
+		if n == nil {
+			break
+		}
+		if _, owner, _ := n.Owner(); owner == addr {
+			deletes = append(deletes, n.IpAddr.String())
+		}
+	}
+	for _, key := range deletes {
+		cache.Neighbor.Del(key)
+	}
 }
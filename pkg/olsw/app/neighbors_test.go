@@ -0,0 +1,145 @@
+package app
+
+import (
+	"github.com/danieldin95/openlan/pkg/libol"
+	"github.com/danieldin95/openlan/pkg/models"
+	"github.com/danieldin95/openlan/pkg/olsw/cache"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+type fakeClient struct {
+	libol.SocketClient
+	name   string
+	point  *models.Point
+	frames []*libol.FrameMessage
+}
+
+func newFakeClient(name, network string) *fakeClient {
+	return &fakeClient{
+		name:  name,
+		point: &models.Point{Network: network},
+	}
+}
+
+func (c *fakeClient) String() string {
+	return c.name
+}
+
+func (c *fakeClient) Private() interface{} {
+	return c.point
+}
+
+func (c *fakeClient) WriteMsg(frame *libol.FrameMessage) error {
+	c.frames = append(c.frames, frame)
+	return nil
+}
+
+func newArpRequest(hw net.HardwareAddr, from, to string) *libol.Arp {
+	arp := libol.NewArp()
+	copy(arp.SHwAddr, hw)
+	copy(arp.SIpAddr, net.ParseIP(from).To4())
+	copy(arp.TIpAddr, net.ParseIP(to).To4())
+	return arp
+}
+
+func addOnline(client *fakeClient) {
+	cache.Point.Add(&models.Point{UUID: client.name, Client: client})
+}
+
+func TestNeighbors_Reply(t *testing.T) {
+	cache.Neighbor.Init(32)
+	e := NewNeighbors(nil)
+	owner := newFakeClient("owner", "default")
+	closed := newFakeClient("closed", "default")
+	addOnline(owner)
+	hw01, _ := net.ParseMAC("52:54:00:00:00:01")
+	hw02, _ := net.ParseMAC("52:54:00:00:00:02")
+	e.AddNeighbor(models.NewNeighbor(hw01, net.ParseIP("192.168.1.10").To4(), owner), owner)
+	e.AddNeighbor(models.NewNeighbor(hw01, net.ParseIP("192.168.1.11").To4(), owner), owner)
+	e.AddNeighbor(models.NewNeighbor(hw01, net.ParseIP("192.168.1.12").To4(), owner), owner)
+	e.AddNeighbor(models.NewNeighbor(hw01, net.ParseIP("192.168.1.13").To4(), closed), closed)
+	cache.Neighbor.Get("192.168.1.11").Conflict = time.Now().Unix() + NeighborStale
+	cache.Neighbor.Get("192.168.1.12").HitTime = time.Now().Unix() - NeighborStale
+
+	tests := []struct {
+		name    string
+		network string
+		sender  net.HardwareAddr
+		target  string
+		replied bool
+	}{
+		{"known", "default", hw02, "192.168.1.10", true},
+		{"unknown", "default", hw02, "192.168.1.20", false},
+		{"cross network", "other", hw02, "192.168.1.10", false},
+		{"by owner", "default", hw01, "192.168.1.10", false},
+		{"in conflict", "default", hw02, "192.168.1.11", false},
+		{"stale", "default", hw02, "192.168.1.12", false},
+		{"owner closed", "default", hw02, "192.168.1.13", false},
+	}
+	for _, tt := range tests {
+		client := newFakeClient(tt.name, tt.network)
+		arp := newArpRequest(tt.sender, "192.168.1.2", tt.target)
+		assert.Equal(t, tt.replied, e.Reply(client, arp), tt.name)
+		if !tt.replied {
+			assert.Equal(t, 0, len(client.frames), tt.name)
+			continue
+		}
+		assert.Equal(t, 1, len(client.frames), tt.name)
+		proto, err := client.frames[0].Proto()
+		assert.Nil(t, err, "be nil.")
+		assert.Equal(t, uint16(libol.ArpReply), proto.Arp.OpCode, "be the same.")
+		assert.Equal(t, []byte(hw01), proto.Arp.SHwAddr, "be the same.")
+		assert.Equal(t, []byte(tt.sender), proto.Arp.THwAddr, "be the same.")
+	}
+}
+
+func TestNeighbors_AddNeighbor(t *testing.T) {
+	cache.Neighbor.Init(32)
+	e := NewNeighbors(nil)
+	e.SetProxy(true)
+	owner := newFakeClient("owner", "default")
+	other := newFakeClient("other", "default")
+	addOnline(owner)
+	addOnline(other)
+	hw01, _ := net.ParseMAC("52:54:00:00:00:01")
+	hw02, _ := net.ParseMAC("52:54:00:00:00:02")
+	ip := net.ParseIP("192.168.1.10").To4()
+
+	e.AddNeighbor(models.NewNeighbor(hw01, ip, owner), owner)
+	// duplicate claim is refused, and not proxied in conflict.
+	e.AddNeighbor(models.NewNeighbor(hw02, ip, other), other)
+	n := cache.Neighbor.Get(ip.String())
+	assert.Equal(t, hw01, n.HwAddr, "be the same.")
+	assert.Equal(t, "owner", n.Client, "be the same.")
+	assert.Equal(t, true, n.InConflict(), "be the same.")
+	requester := newFakeClient("requester", "default")
+	arp := newArpRequest(hw02, "192.168.1.2", ip.String())
+	assert.Equal(t, false, e.Reply(requester, arp), "be the same.")
+	// moved after stale.
+	n.HitTime = time.Now().Unix() - NeighborStale
+	e.AddNeighbor(models.NewNeighbor(hw02, ip, other), other)
+	n = cache.Neighbor.Get(ip.String())
+	assert.Equal(t, hw02, n.HwAddr, "be the same.")
+	assert.Equal(t, "other", n.Client, "be the same.")
+	assert.Equal(t, false, n.InConflict(), "be the same.")
+	assert.Equal(t, true, e.Reply(requester, newArpRequest(hw01, "192.168.1.2", ip.String())), "be the same.")
+}
+
+func TestNeighbors_OnClientClose(t *testing.T) {
+	cache.Neighbor.Init(32)
+	e := NewNeighbors(nil)
+	owner := newFakeClient("owner", "default")
+	other := newFakeClient("other", "default")
+	hw01, _ := net.ParseMAC("52:54:00:00:00:01")
+	hw02, _ := net.ParseMAC("52:54:00:00:00:02")
+	e.AddNeighbor(models.NewNeighbor(hw01, net.ParseIP("192.168.1.10").To4(), owner), owner)
+	e.AddNeighbor(models.NewNeighbor(hw01, net.ParseIP("192.168.1.11").To4(), owner), owner)
+	e.AddNeighbor(models.NewNeighbor(hw02, net.ParseIP("192.168.1.20").To4(), other), other)
+	e.OnClientClose(owner)
+	assert.Nil(t, cache.Neighbor.Get("192.168.1.10"), "be nil.")
+	assert.Nil(t, cache.Neighbor.Get("192.168.1.11"), "be nil.")
+	assert.NotNil(t, cache.Neighbor.Get("192.168.1.20"), "be not nil.")
+}
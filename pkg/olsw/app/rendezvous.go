@@ -0,0 +1,179 @@
+package app
+
+import (
+	"encoding/json"
+	"github.com/danieldin95/openlan/pkg/config"
+	"github.com/danieldin95/openlan/pkg/libol"
+	"github.com/danieldin95/openlan/pkg/models"
+	"github.com/danieldin95/openlan/pkg/olsw/cache"
+	"net"
+	"time"
+)
+
+type Rendezvous struct {
+	port   string
+	socket *libol.DirectSocket
+	master Master
+	out    *libol.SubLogger
+}
+
+func NewRendezvous(m Master) *Rendezvous {
+	c := config.Manager.Switch
+	_, port := libol.GetHostPort(c.Rendezvous)
+	return &Rendezvous{
+		port:   port,
+		socket: libol.NewDirectSocket(c.Rendezvous, config.GetBlock(c.Crypt)),
+		master: m,
+		out:    libol.NewSubLogger("rendezvous"),
+	}
+}
+
+func (r *Rendezvous) OnFrame(client libol.SocketClient, frame *libol.FrameMessage) error {
+	if frame.IsEthernet() {
+		return nil
+	}
+	action, body := frame.CmdAndParams()
+	if action == libol.CandidateReq {
+		r.onCandidate(client, body)
+	}
+	return nil
+}
+
+func (r *Rendezvous) onCandidate(client libol.SocketClient, data []byte) {
+	out := client.Out()
+	p := cache.Point.Get(client.String())
+	if p == nil {
+		out.Error("Rendezvous.onCandidate: point notFound")
+		return
+	}
+	recv := &models.Candidate{}
+	if err := json.Unmarshal(data, recv); err != nil {
+		out.Error("Rendezvous.onCandidate: invalid json data.")
+		return
+	}
+	recv.UUID = p.UUID
+	recv.Alias = p.Alias
+	recv.Update()
+	p.SetCandidate(recv)
+
+	resp := &models.Rendezvous{
+		UUID:  p.UUID,
+		Port:  r.port,
+		Peers: make([]*models.Candidate, 0, 32),
+	}
+	// frames on direct paths bypass acl of switch, so no peers for it.
+	if r.hasAcl(p.Network) {
+		out.Debug("Rendezvous.onCandidate: %s has acl", p.Network)
+	} else {
+		r.findPeers(p, resp)
+	}
+	if respStr, err := json.Marshal(resp); err == nil {
+		out.Cmd("Rendezvous.onCandidate: resp %s", respStr)
+		m := libol.NewControlFrame(libol.CandidateResp, respStr)
+		_ = client.WriteMsg(m)
+	}
+}
+
+func (r *Rendezvous) hasAcl(network string) bool {
+	for _, nCfg := range config.Manager.Switch.Network {
+		if nCfg.Name == network {
+			return nCfg.Acl != ""
+		}
+	}
+	return false
+}
+
+func (r *Rendezvous) findPeers(p *models.Point, resp *models.Rendezvous) {
+	for obj := range cache.Point.List() {
+		if obj == nil {
+			break
+		}
+		if obj.UUID == p.UUID || obj.Network != p.Network {
+			continue
+		}
+		peer := obj.Candidate()
+		if peer == nil || peer.Public == "" {
+			continue
+		}
+		resp.Peers = append(resp.Peers, &models.Candidate{
+			UUID:   peer.UUID,
+			Alias:  peer.Alias,
+			Token:  peer.Token,
+			HwAddr: peer.HwAddr,
+			Local:  peer.Local,
+			Public: peer.Public,
+		})
+	}
+}
+
+// learn public address of point by punching.
+func (r *Rendezvous) onPunch(addr *net.UDPAddr, data []byte) {
+	recv := &models.Punch{}
+	if err := json.Unmarshal(data, recv); err != nil {
+		r.out.Warn("Rendezvous.onPunch: %s invalid json data.", addr)
+		return
+	}
+	p := cache.Point.GetByUUID(recv.UUID)
+	if p == nil {
+		r.out.Debug("Rendezvous.onPunch: %s notFound", recv.UUID)
+		return
+	}
+	changed, err := p.Punched(recv.Token, addr.String())
+	if err != nil {
+		r.out.Warn("Rendezvous.onPunch: %s from %s %s", recv.UUID, addr, err)
+		return
+	}
+	if changed {
+		r.out.Info("Rendezvous.onPunch: %s on %s", recv.UUID, addr)
+	}
+	resp := &models.Punch{
+		UUID:    r.master.UUID(),
+		Address: addr.String(),
+		Time:    recv.Time,
+	}
+	if respStr, err := json.Marshal(resp); err == nil {
+		m := libol.NewControlFrame(libol.PunchResp, respStr)
+		if err := r.socket.WriteMsgTo(m, addr); err != nil {
+			r.out.Warn("Rendezvous.onPunch: %s", err)
+		}
+	}
+}
+
+func (r *Rendezvous) Start() {
+	promise := libol.Promise{
+		First:  2 * time.Second,
+		MinInt: 5 * time.Second,
+		MaxInt: 30 * time.Second,
+	}
+	promise.Done(func() error {
+		if err := r.socket.Listen(); err != nil {
+			r.out.Warn("Rendezvous.Start: %s", err)
+			return err
+		}
+		return nil
+	})
+	for {
+		frame, addr, err := r.socket.ReadMsgFrom()
+		if err != nil {
+			if !r.socket.IsOk() {
+				break
+			}
+			r.out.Debug("Rendezvous.Start: %s", err)
+			continue
+		}
+		if !frame.Decode() {
+			frame.Release()
+			continue
+		}
+		action, body := frame.CmdAndParams()
+		if action == libol.PunchReq {
+			r.onPunch(addr, body)
+		}
+		frame.Release()
+	}
+	r.out.Info("Rendezvous.Start: exit")
+}
+
+func (r *Rendezvous) Stop() {
+	r.socket.Close()
+}
@@ -38,7 +38,9 @@ func (r *Request) OnFrame(client libol.SocketClient, frame *libol.FrameMessage)
 		r.onIpAddr(client, body)
 	case libol.LeftReq:
 		r.onLeave(client, body)
-	case libol.LoginReq:
+	case libol.PingReq:
+		r.onPing(client, body)
+	case libol.LoginReq, libol.CandidateReq:
 		out.Debug("Request.OnFrame %s: %s", action, body)
 	default:
 		r.onDefault(client, body)
@@ -51,6 +53,27 @@ func (r *Request) onDefault(client libol.SocketClient, data []byte) {
 	_ = client.WriteMsg(m)
 }
 
+// onPing records mtu of path, and replies without pad of probe.
+func (r *Request) onPing(client libol.SocketClient, data []byte) {
+	ping := make(map[string]interface{}, 8)
+	if err := json.Unmarshal(data, &ping); err != nil {
+		r.onDefault(client, data)
+		return
+	}
+	if mtu, ok := ping["mtu"].(float64); ok {
+		if p := cache.Point.Get(client.String()); p != nil {
+			p.Mtu = int(mtu)
+		}
+	}
+	if _, ok := ping["pad"]; ok {
+		delete(ping, "pad")
+		if resp, err := json.Marshal(ping); err == nil {
+			data = resp
+		}
+	}
+	r.onDefault(client, data)
+}
+
 func (r *Request) onNeighbor(client libol.SocketClient, data []byte) {
 	resp := make([]schema.Neighbor, 0, 32)
 	for obj := range cache.Neighbor.List() {
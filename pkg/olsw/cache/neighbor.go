@@ -20,15 +20,6 @@ func (p *neighbor) Add(m *models.Neighbor) {
 	_ = p.Neighbors.Set(m.IpAddr.String(), m)
 }
 
-func (p *neighbor) Update(m *models.Neighbor) *models.Neighbor {
-	if v := p.Neighbors.Get(m.IpAddr.String()); v != nil {
-		n := v.(*models.Neighbor)
-		n.HwAddr = m.HwAddr
-		n.HitTime = m.HitTime
-	}
-	return nil
-}
-
 func (p *neighbor) Get(key string) *models.Neighbor {
 	if v := p.Neighbors.Get(key); v != nil {
 		return v.(*models.Neighbor)
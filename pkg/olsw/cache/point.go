@@ -21,6 +21,10 @@ func (p *point) Add(m *models.Point) {
 	_ = p.Clients.Set(m.Client.String(), m)
 }
 
+func (p *point) Full() bool {
+	return p.Clients.Full()
+}
+
 func (p *point) Get(addr string) *models.Point {
 	if v := p.Clients.Get(addr); v != nil {
 		m := v.(*models.Point)
@@ -30,6 +34,11 @@ func (p *point) Get(addr string) *models.Point {
 	return nil
 }
 
+// Online returns true if point of connection is not closed.
+func (p *point) Online(addr string) bool {
+	return p.Clients.Get(addr) != nil
+}
+
 func (p *point) GetByUUID(uuid string) *models.Point {
 	if addr := p.GetAddr(uuid); addr != "" {
 		return p.Get(addr)
@@ -59,6 +68,15 @@ func (p *point) Del(addr string) {
 	}
 }
 
+// Move changes connection of point without closing its device.
+func (p *point) Move(m *models.Point, client libol.SocketClient) {
+	addr := m.Client.String()
+	p.AddrUUID.Del(addr)
+	p.Clients.Del(addr)
+	m.Client = client
+	p.Add(m)
+}
+
 func (p *point) List() <-chan *models.Point {
 	c := make(chan *models.Point, 128)
 
@@ -176,14 +176,14 @@ func (w *user) Check(obj *models.User) (*models.User, error) {
 				if t1.Year() < 2000 || t1.After(t0) {
 					return u, nil
 				}
-				return nil, libol.NewErr("out of date")
+				return nil, libol.NewLoginErr(libol.LoginExpired, "out of date")
 			}
 		}
 	}
 	if u := w.CheckLdap(obj); u != nil {
 		return u, nil
 	}
-	return nil, libol.NewErr("wrong user or password")
+	return nil, libol.NewLoginErr(libol.LoginWrong, "wrong user or password")
 }
 
 func (w *user) GetLdap() *libol.LDAPService {
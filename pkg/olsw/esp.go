@@ -47,14 +47,16 @@ type EspWorker struct {
 	out      *libol.SubLogger
 	proto    nl.Proto
 	mode     nl.Mode
+	healths  map[int]*models.EspHealth
 }
 
 func NewESPWorker(c *co.Network) *EspWorker {
 	w := &EspWorker{
-		cfg:   c,
-		out:   libol.NewSubLogger(c.Name),
-		proto: nl.XFRM_PROTO_ESP,
-		mode:  nl.XFRM_MODE_TUNNEL,
+		cfg:     c,
+		out:     libol.NewSubLogger(c.Name),
+		proto:   nl.XFRM_PROTO_ESP,
+		mode:    nl.XFRM_MODE_TUNNEL,
+		healths: make(map[int]*models.EspHealth, 32),
 	}
 	w.spec, _ = c.Specifies.(*co.ESPSpecifies)
 	return w
@@ -110,40 +112,35 @@ func (w *EspWorker) newPolicy(args PolicyParameter) *nl.XfrmPolicy {
 }
 
 func (w *EspWorker) addState(mem *co.ESPMember) {
-	spi := mem.Spi
 	ste := mem.State
-
 	w.out.Info("EspWorker.addState %s-%s", ste.LocalIp, ste.RemoteIp)
-	if st := w.newState(StateParameters{
-		spi, ste.LocalIp, ste.RemoteIp, ste.Auth, ste.Crypt,
-	}); st != nil {
-		st.Encap = GetStateEncap(ste.Encap, 0, ste.RemotePort)
-		w.states = append(w.states, st)
-	}
-	if st := w.newState(StateParameters{
-		spi, ste.RemoteIp, ste.LocalIp, ste.Auth, ste.Crypt,
-	}); st != nil {
-		st.Encap = GetStateEncap(ste.Encap, ste.RemotePort, 0)
-		w.states = append(w.states, st)
-	}
-	cache.EspState.Add(&models.EspState{
-		EspState: &schema.EspState{
-			Name:   w.spec.Name,
-			Spi:    spi,
-			Source: ste.LocalIp.String(),
-			Dest:   ste.RemoteIp.String(),
-			Proto:  uint8(w.proto),
-			Mode:   uint8(w.mode),
-		},
-	})
+	out, in := w.newStates(mem, mem.Spi, ste.Auth, ste.Crypt)
+	w.states = append(w.states, out, in)
+	cache.EspState.Add(w.newStateModel(mem, mem.Spi))
 }
 
 func (w *EspWorker) delState(mem *co.ESPMember) {
-	spi := mem.Spi
 	ste := mem.State
-
 	w.out.Info("EspWorker.delState %s-%s", ste.LocalIp, ste.RemoteIp)
-	model := models.EspState{
+	cache.EspState.Del(w.newStateModel(mem, mem.Spi).ID())
+}
+
+func (w *EspWorker) newStates(mem *co.ESPMember, spi int, auth, crypt string) (*nl.XfrmState, *nl.XfrmState) {
+	ste := mem.State
+	out := w.newState(StateParameters{
+		spi, ste.LocalIp, ste.RemoteIp, auth, crypt,
+	})
+	out.Encap = GetStateEncap(ste.Encap, 0, ste.RemotePort)
+	in := w.newState(StateParameters{
+		spi, ste.RemoteIp, ste.LocalIp, auth, crypt,
+	})
+	in.Encap = GetStateEncap(ste.Encap, ste.RemotePort, 0)
+	return out, in
+}
+
+func (w *EspWorker) newStateModel(mem *co.ESPMember, spi int) *models.EspState {
+	ste := mem.State
+	return &models.EspState{
 		EspState: &schema.EspState{
 			Name:   w.spec.Name,
 			Spi:    spi,
@@ -152,13 +149,90 @@ func (w *EspWorker) delState(mem *co.ESPMember) {
 			Proto:  uint8(w.proto),
 			Mode:   uint8(w.mode),
 		},
+		Health: w.getHealth(mem),
+	}
+}
+
+func (w *EspWorker) getHealth(mem *co.ESPMember) *models.EspHealth {
+	if h, ok := w.healths[mem.Spi]; ok {
+		return h
+	}
+	h := &models.EspHealth{}
+	w.healths[mem.Spi] = h
+	return h
+}
+
+// AddSA installs negotiated keys of member.
+func (w *EspWorker) AddSA(mem *co.ESPMember, spi int, auth, crypt []byte) error {
+	w.out.Info("EspWorker.AddSA %d %s-%s", spi, mem.State.LocalIp, mem.State.RemoteIp)
+	out, in := w.newStates(mem, spi, string(auth), string(crypt))
+	if err := nl.XfrmStateAdd(out); err != nil {
+		return err
+	}
+	if err := nl.XfrmStateAdd(in); err != nil {
+		_ = nl.XfrmStateDel(out)
+		return err
+	}
+	cache.EspState.Add(w.newStateModel(mem, spi))
+	return nil
+}
+
+func (w *EspWorker) DelSA(mem *co.ESPMember, spi int) {
+	w.out.Info("EspWorker.DelSA %d %s-%s", spi, mem.State.LocalIp, mem.State.RemoteIp)
+	out, in := w.newStates(mem, spi, "", "")
+	if err := nl.XfrmStateDel(out); err != nil {
+		w.out.Warn("EspWorker.DelSA %d: %s", spi, err)
+	}
+	if err := nl.XfrmStateDel(in); err != nil {
+		w.out.Warn("EspWorker.DelSA %d: %s", spi, err)
+	}
+	cache.EspState.Del(w.newStateModel(mem, spi).ID())
+}
+
+// SwitchSA updates outbound policies of member to spi.
+func (w *EspWorker) SwitchSA(mem *co.ESPMember, spi int) error {
+	ste := mem.State
+	for _, po := range w.policies {
+		if po.Dir != nl.XFRM_DIR_OUT || len(po.Tmpls) == 0 {
+			continue
+		}
+		tmpl := &po.Tmpls[0]
+		if !tmpl.Src.Equal(ste.LocalIp) || !tmpl.Dst.Equal(ste.RemoteIp) {
+			continue
+		}
+		tmpl.Spi = spi
+		if err := nl.XfrmPolicyUpdate(po); err != nil {
+			return err
+		}
+	}
+	w.out.Info("EspWorker.SwitchSA %d %s-%s", spi, ste.LocalIp, ste.RemoteIp)
+	return nil
+}
+
+func (w *EspWorker) SABytes(mem *co.ESPMember, spi int) int64 {
+	out, _ := w.newStates(mem, spi, "", "")
+	if xss, err := nl.XfrmStateGet(out); xss != nil {
+		return int64(xss.Statistics.Bytes)
+	} else {
+		w.out.Debug("EspWorker.SABytes %s", err)
+	}
+	return 0
+}
+
+func (w *EspWorker) OnKeyed(mem *co.ESPMember, spi int, keyTime, nextRekey int64) {
+	if obj := cache.EspState.Get(w.newStateModel(mem, spi).ID()); obj != nil {
+		obj.KeyTime = keyTime
+		obj.NextRekey = nextRekey
 	}
-	cache.EspState.Del(model.ID())
 }
 
 func (w *EspWorker) addPolicy(mem *co.ESPMember, pol *co.ESPPolicy) {
 	spi := mem.Spi
 	ste := mem.State
+	inSpi := spi
+	if ste.Auto {
+		inSpi = 0 // accept any negotiated spi.
+	}
 	w.out.Info("EspWorker.addPolicy %s-%s", pol.Source, pol.Dest)
 	src, err := libol.ParseNet(pol.Source)
 	if err != nil {
@@ -176,12 +250,12 @@ func (w *EspWorker) addPolicy(mem *co.ESPMember, pol *co.ESPPolicy) {
 		w.policies = append(w.policies, po)
 	}
 	if po := w.newPolicy(PolicyParameter{
-		spi, ste.RemoteIp, ste.LocalIp, dst, src, nl.XFRM_DIR_FWD,
+		inSpi, ste.RemoteIp, ste.LocalIp, dst, src, nl.XFRM_DIR_FWD,
 	}); po != nil {
 		w.policies = append(w.policies, po)
 	}
 	if po := w.newPolicy(PolicyParameter{
-		spi, ste.RemoteIp, ste.LocalIp, dst, src, nl.XFRM_DIR_IN,
+		inSpi, ste.RemoteIp, ste.LocalIp, dst, src, nl.XFRM_DIR_IN,
 	}); po != nil {
 		w.policies = append(w.policies, po)
 	}
@@ -218,7 +292,14 @@ func (w *EspWorker) updateXfrm() {
 		if state.LocalIp == nil || state.RemoteIp == nil {
 			continue
 		}
-		w.addState(mem)
+		if state.Auto {
+			if err := state.CheckAuto(); err != nil {
+				w.out.Error("EspWorker.updateXfrm %d: %s", mem.Spi, err)
+				continue
+			}
+		} else {
+			w.addState(mem)
+		}
 		for _, pol := range mem.Policies {
 			if pol == nil {
 				continue
@@ -300,6 +381,23 @@ func (w *EspWorker) addXfrm() {
 			w.out.Error("EspWorker.addXfrm Policy %s", err)
 		}
 	}
+	for _, mem := range w.spec.Members {
+		if w.isAuto(mem) {
+			Ike.Add(mem, w)
+			Ike.Start()
+		}
+	}
+}
+
+func (w *EspWorker) isAuto(mem *co.ESPMember) bool {
+	if mem == nil {
+		return false
+	}
+	ste := mem.State
+	if !ste.Auto || ste.CheckAuto() != nil {
+		return false
+	}
+	return ste.LocalIp != nil && ste.RemoteIp != nil
 }
 
 func (w *EspWorker) Start(v api.Switcher) {
@@ -310,6 +408,7 @@ func (w *EspWorker) Start(v api.Switcher) {
 	w.uuid = v.UUID()
 	w.addXfrm()
 	w.upMember()
+	w.addProbe()
 	cache.Esp.Add(&models.Esp{
 		Name:    w.cfg.Name,
 		Address: w.spec.Address,
@@ -334,6 +433,11 @@ func (w *EspWorker) DownDummy(name string) error {
 }
 
 func (w *EspWorker) delXfrm() {
+	for _, mem := range w.spec.Members {
+		if w.isAuto(mem) {
+			Ike.Del(mem)
+		}
+	}
 	for _, pol := range w.policies {
 		if err := nl.XfrmPolicyDel(pol); err != nil {
 			w.out.Warn("EspWorker.delXfrm Policy %s-%s: %s", pol.Src, pol.Dst, err)
@@ -353,6 +457,7 @@ func (w *EspWorker) Stop() {
 		w.out.Error("EspWorker.Stop spec is nil")
 		return
 	}
+	w.delProbe()
 	w.downMember()
 	w.delXfrm()
 }
@@ -380,10 +485,30 @@ func (w *EspWorker) GetSubnet() string {
 }
 
 func (w *EspWorker) Reload(c *co.Network) {
+	w.delProbe()
 	w.delXfrm()
 	w.updateXfrm()
 	w.addXfrm()
 	w.upMember()
+	w.addProbe()
+}
+
+func (w *EspWorker) addProbe() {
+	for _, mem := range w.spec.Members {
+		if mem == nil || mem.Address == "" {
+			continue
+		}
+		Probe.Add(mem, w.getHealth(mem))
+	}
+}
+
+func (w *EspWorker) delProbe() {
+	for _, mem := range w.spec.Members {
+		if mem == nil {
+			continue
+		}
+		Probe.Del(mem)
+	}
 }
 
 func (w *EspWorker) upMember() {
@@ -406,6 +531,7 @@ func OpenUDP() {
 	libol.Go(func() {
 		args := []string{
 			"-p", strconv.Itoa(UDPPort),
+			"-i", strconv.Itoa(co.EspIke),
 			"-vconsole:emer",
 			"--log-file=/var/openlan/openudp.log",
 		}
@@ -1,13 +1,18 @@
 package olsw
 
 import (
+	"encoding/hex"
 	"fmt"
 	"github.com/danieldin95/go-openvswitch/ovs"
 	co "github.com/danieldin95/openlan/pkg/config"
 	"github.com/danieldin95/openlan/pkg/libol"
 	cn "github.com/danieldin95/openlan/pkg/network"
 	"github.com/danieldin95/openlan/pkg/olsw/api"
+	"github.com/danieldin95/openlan/pkg/schema"
 	"github.com/vishvananda/netlink"
+	"net"
+	"os/exec"
+	"strconv"
 	"strings"
 )
 
@@ -105,9 +110,20 @@ func (o *OvsBridge) dumpPort(name string) *ovs.PortStats {
 	}
 }
 
+func (o *OvsBridge) dumpAggregate(flow *ovs.MatchFlow) *ovs.FlowStats {
+	if stats, err := o.cli.OpenFlow.DumpAggregate(o.name, flow); err != nil {
+		o.out.Warn("OvsBridge.dumpAggregate %s", err)
+		return nil
+	} else {
+		return stats
+	}
+}
+
 const (
 	TLsToTun     = 2  // From a switch include border to tunnels.
 	TTunToLs     = 4  // From tunnels to a switch.
+	TArpLookup   = 6  // Looking up mac by target of arp request.
+	TArpReply    = 8  // Answering arp request locally.
 	TSourceLearn = 10 // Learning source mac.
 	TUcastToTun  = 20 // Forwarding by fdb.
 	TFloodToTun  = 30 // Flooding to tunnels or patch by flags.
@@ -127,6 +143,25 @@ const (
 	NxmRegEthSrc = "NXM_OF_ETH_SRC[]"
 	NxmRegTunId  = "NXM_NX_TUN_ID[0..31]"
 	NxmRegInPort = "NXM_OF_IN_PORT[]"
+	NxmRegTunMd0 = "NXM_NX_TUN_METADATA0[0..31]"
+	MatchTunMd0  = "tun_metadata0"
+)
+
+const (
+	MatchRegArp  = "reg14"
+	NxmRegArpHit = "NXM_NX_REG14[]"
+	NxmRegArpMac = "NXM_NX_XREG6[0..47]"
+	NxmRegArpTmp = "NXM_NX_REG15[]"
+	NxmArpOp     = "NXM_OF_ARP_OP[]"
+	NxmArpSha    = "NXM_NX_ARP_SHA[]"
+	NxmArpTha    = "NXM_NX_ARP_THA[]"
+	NxmArpSpa    = "NXM_OF_ARP_SPA[]"
+	NxmArpTpa    = "NXM_OF_ARP_TPA[]"
+	NxmEthType   = "NXM_OF_ETH_TYPE[]"
+)
+
+const (
+	InterfaceTypeGeneve ovs.InterfaceType = "geneve"
 )
 
 type OvsPort struct {
@@ -172,13 +207,14 @@ func (w *FabricWorker) upTables() {
 	_ = w.ovs.addFlow(&ovs.Flow{
 		Actions: []ovs.Action{ovs.Drop()},
 	})
-	// Table 2: set flags from logical switch.
+	// Table 2: set flags from logical switch, and try to answer arp.
 	_ = w.ovs.addFlow(&ovs.Flow{
 		Table:    TLsToTun,
 		Priority: 1,
 		Actions: []ovs.Action{
 			ovs.Load(libol.Uint2S(FFromLs), NxmRegFlag),
-			ovs.Resubmit(0, TSourceLearn),
+			ovs.Resubmit(0, TArpLookup),
+			ovs.Resubmit(0, TArpReply),
 		},
 	})
 	// Table 4: set flags from tunnels.
@@ -190,6 +226,15 @@ func (w *FabricWorker) upTables() {
 			ovs.Resubmit(0, TSourceLearn),
 		},
 	})
+	// Table 4: learning ip and mac from arp of tunnels.
+	w.addArpLearning()
+	// Table 6: default to miss.
+	_ = w.ovs.addFlow(&ovs.Flow{
+		Table:   TArpLookup,
+		Actions: []ovs.Action{ovs.Drop()},
+	})
+	// Table 8: answer arp request if hit, otherwise to 10.
+	w.addArpReply()
 	// Table 10: source learning
 	w.addLearning()
 	// Table 20: default to flood 30
@@ -216,9 +261,26 @@ func (w *FabricWorker) Initialize() {
 		return
 	}
 	_ = w.ovs.setMode("secure")
+	if w.spec.Driver == co.FabricGeneve {
+		w.upTlvMap()
+	}
 	w.upTables()
 }
 
+// upTlvMap maps option of geneve to tun_metadata0.
+func (w *FabricWorker) upTlvMap() {
+	opt := w.spec.Geneve
+	tlv := fmt.Sprintf("{class=%s,type=%d,len=4}->%s", opt.Class, opt.Type, MatchTunMd0)
+	out, err := exec.Command("ovs-ofctl", "add-tlv-map", w.ovs.name, tlv).CombinedOutput()
+	if err != nil {
+		w.out.Warn("FabricWorker.upTlvMap %s: %s", err, out)
+	}
+}
+
+func (w *FabricWorker) hasMetadata(cfg *co.FabricNetwork) bool {
+	return w.spec.Driver == co.FabricGeneve && cfg.Metadata > 0
+}
+
 func (w *FabricWorker) vni2peer(vni uint32) (string, string) {
 	tunPort := fmt.Sprintf("vb-%08d", vni)
 	brPort := fmt.Sprintf("vt-%08d", vni)
@@ -266,6 +328,10 @@ func (w *FabricWorker) addLearning() {
 		ovs.FieldMatch(NxmRegTunId, NxmRegTunId),
 		ovs.FieldMatch(NxmRegEthDst, NxmRegEthSrc),
 	}
+	if w.spec.Driver == co.FabricGeneve {
+		// and isolated by metadata of tenant.
+		learnSpecs = append(learnSpecs, ovs.FieldMatch(NxmRegTunMd0, NxmRegTunMd0))
+	}
 	learnActions := []ovs.Action{
 		ovs.OutputField(NxmRegInPort),
 	}
@@ -284,28 +350,147 @@ func (w *FabricWorker) addLearning() {
 	})
 }
 
+// arpLearnFlow learns mac of sender from arp request of tunnels, and
+// answers request for it later.
+func (w *FabricWorker) arpLearnFlow() *ovs.Flow {
+	learnSpecs := []ovs.Match{
+		ovs.FieldMatch(NxmEthType, "0x0806"),
+		ovs.FieldMatch(NxmArpOp, "1"),
+		ovs.FieldMatch(NxmRegTunId, NxmRegTunId),
+		ovs.FieldMatch(NxmArpTpa, NxmArpSpa),
+	}
+	if w.spec.Driver == co.FabricGeneve {
+		learnSpecs = append(learnSpecs, ovs.FieldMatch(NxmRegTunMd0, NxmRegTunMd0))
+	}
+	learnActions := []ovs.Action{
+		ovs.Load(NxmArpSha, NxmRegArpMac),
+		ovs.Load("1", NxmRegArpHit),
+	}
+	return &ovs.Flow{
+		Table:    TTunToLs,
+		Priority: 2,
+		Protocol: ovs.ProtocolARP,
+		Actions: []ovs.Action{
+			ovs.Load(libol.Uint2S(FFromTun), NxmRegFlag),
+			ovs.Learn(&ovs.LearnedFlow{
+				Table:       TArpLookup,
+				Matches:     learnSpecs,
+				Priority:    1,
+				HardTimeout: 300,
+				Actions:     learnActions,
+			}),
+			ovs.Resubmit(0, TSourceLearn),
+		},
+	}
+}
+
+func (w *FabricWorker) addArpLearning() {
+	_ = w.ovs.addFlow(w.arpLearnFlow())
+}
+
+func (w *FabricWorker) addArpReply() {
+	_ = w.ovs.addFlow(&ovs.Flow{
+		Table: TArpReply,
+		Actions: []ovs.Action{
+			ovs.Resubmit(0, TSourceLearn),
+		},
+	})
+}
+
+// arpReplyMatch matches arp request of vni which is hit by lookup, and
+// exactly on tunnel id to be aggregated per vni.
+func (w *FabricWorker) arpReplyMatch(vni uint32) *ovs.MatchFlow {
+	return &ovs.MatchFlow{
+		Table:    TArpReply,
+		Protocol: ovs.ProtocolARP,
+		Matches: []ovs.Match{
+			ovs.ARPOperation(1),
+			ovs.TunnelID(uint64(vni)),
+			ovs.FieldMatch(MatchRegArp, "1"),
+		},
+	}
+}
+
+// arpReplyFlow turns request into reply, and sends it back to in port.
+func (w *FabricWorker) arpReplyFlow(vni uint32) *ovs.Flow {
+	match := w.arpReplyMatch(vni)
+	return &ovs.Flow{
+		Table:    match.Table,
+		Priority: 2,
+		Protocol: match.Protocol,
+		Matches:  match.Matches,
+		Actions: []ovs.Action{
+			ovs.Move(NxmRegEthSrc, NxmRegEthDst),
+			ovs.Move(NxmRegArpMac, NxmRegEthSrc),
+			ovs.Load("2", NxmArpOp),
+			ovs.Move(NxmArpSha, NxmArpTha),
+			ovs.Move(NxmRegArpMac, NxmArpSha),
+			ovs.Move(NxmArpTpa, NxmRegArpTmp),
+			ovs.Move(NxmArpSpa, NxmArpTpa),
+			ovs.Move(NxmRegArpTmp, NxmArpSpa),
+			ovs.InPort(),
+		},
+	}
+}
+
+// neighborFlow returns a static entry of arp responder.
+func (w *FabricWorker) neighborFlow(cfg *co.FabricNetwork, neighbor co.FabricNeighbor) *ovs.Flow {
+	ip := net.ParseIP(neighbor.Address)
+	mac, err := net.ParseMAC(neighbor.Mac)
+	if ip == nil || err != nil {
+		w.out.Warn("FabricWorker.neighborFlow invalid %s %s", neighbor.Address, neighbor.Mac)
+		return nil
+	}
+	matches := []ovs.Match{
+		ovs.ARPOperation(1),
+		ovs.FieldMatch(NxmRegTunId, libol.Uint2S(cfg.Vni)),
+		ovs.ARPTargetProtocolAddress(ip.String()),
+	}
+	if w.hasMetadata(cfg) {
+		matches = append(matches, ovs.FieldMatch(MatchTunMd0, libol.Uint2S(cfg.Metadata)))
+	}
+	return &ovs.Flow{
+		Table:    TArpLookup,
+		Priority: 2,
+		Protocol: ovs.ProtocolARP,
+		Matches:  matches,
+		Actions: []ovs.Action{
+			ovs.Load("0x"+hex.EncodeToString(mac), NxmRegArpMac),
+			ovs.Load("1", NxmRegArpHit),
+		},
+	}
+}
+
 func (w *FabricWorker) AddNetwork(cfg *co.FabricNetwork) {
 	libol.Info("Fabric.AddNetwork %d", cfg.Vni)
 	net := w.UpLink(cfg.Bridge, cfg.Vni, cfg.Address)
 	patchPort := net.patch.portId
 	// Table 0: load tunnel id from patch port.
+	actions := []ovs.Action{
+		ovs.Load(libol.Uint2S(cfg.Vni), NxmRegTunId),
+	}
+	if w.hasMetadata(cfg) {
+		actions = append(actions, ovs.Load(libol.Uint2S(cfg.Metadata), NxmRegTunMd0))
+	}
+	actions = append(actions, ovs.Resubmit(0, TLsToTun))
 	_ = w.ovs.addFlow(&ovs.Flow{
 		InPort:   patchPort,
 		Priority: 1,
-		Actions: []ovs.Action{
-			ovs.Load(libol.Uint2S(cfg.Vni), NxmRegTunId),
-			ovs.Resubmit(0, TLsToTun),
-		},
+		Actions:  actions,
 	})
 	// Table 30: flooding to patch from tunnels.
 	w.networks[cfg.Vni] = net
+	matches := []ovs.Match{
+		ovs.FieldMatch(NxmRegTunId, libol.Uint2S(cfg.Vni)),
+		ovs.FieldMatch(MatchRegFlag, libol.Uint2S(FFromTun)),
+	}
+	if w.hasMetadata(cfg) {
+		matches = append(matches, ovs.FieldMatch(MatchTunMd0, libol.Uint2S(cfg.Metadata)))
+	}
 	_ = w.ovs.addFlow(&ovs.Flow{
 		Table:    TFloodToTun,
 		Priority: 2,
-		Matches: []ovs.Match{
-			ovs.FieldMatch(NxmRegTunId, libol.Uint2S(cfg.Vni)),
-			ovs.FieldMatch(MatchRegFlag, libol.Uint2S(FFromTun)),
-		},
+		Matches:  matches,
 		Actions: []ovs.Action{
 			ovs.Output(patchPort),
 			ovs.Resubmit(0, TFloodToBor),
@@ -323,6 +508,14 @@ func (w *FabricWorker) AddNetwork(cfg *co.FabricNetwork) {
 			ovs.Output(patchPort),
 		},
 	})
+	// Table 6: static entries of arp responder.
+	for _, neighbor := range cfg.Neighbors {
+		if flow := w.neighborFlow(cfg, neighbor); flow != nil {
+			_ = w.ovs.addFlow(flow)
+		}
+	}
+	// Table 8: answer arp request of this vni.
+	_ = w.ovs.addFlow(w.arpReplyFlow(cfg.Vni))
 }
 
 func (w *FabricWorker) AddOutput(bridge string, vlan int, output string) {
@@ -400,14 +593,30 @@ func (w *FabricWorker) flood2Border() {
 }
 
 func (w *FabricWorker) tunnelType() ovs.InterfaceType {
-	if w.spec.Driver == "stt" {
+	switch w.spec.Driver {
+	case co.FabricSTT:
 		return ovs.InterfaceTypeSTT
+	case co.FabricGeneve:
+		return InterfaceTypeGeneve
+	case co.FabricGRE, co.FabricGRETap:
+		// gre of ovs is ethernet over gre.
+		return ovs.InterfaceTypeGRE
 	}
 	return ovs.InterfaceTypeVXLAN
 }
 
+func (w *FabricWorker) tunnelPrefix() string {
+	switch w.spec.Driver {
+	case co.FabricGeneve:
+		return "gn-"
+	case co.FabricGRE, co.FabricGRETap:
+		return "gr-"
+	}
+	return "vx-"
+}
+
 func (w *FabricWorker) AddTunnel(cfg *co.FabricTunnel) {
-	name := w.Addr2Port(cfg.Remote, "vx-")
+	name := w.Addr2Port(cfg.Remote, w.tunnelPrefix())
 	options := ovs.InterfaceOptions{
 		Type:      w.tunnelType(),
 		BfdEnable: true,
@@ -460,10 +669,42 @@ func (w *FabricWorker) AddTunnel(cfg *co.FabricTunnel) {
 	}
 }
 
+// inputRules allows tunnels of driver, which are not allowed by default.
+func (w *FabricWorker) inputRules() []cn.IpRule {
+	var rules []cn.IpRule
+	switch w.spec.Driver {
+	case co.FabricGRE, co.FabricGRETap:
+		rules = append(rules, cn.IpRule{
+			Table: cn.TFilter,
+			Chain: cn.OLCInput,
+			Proto: "gre",
+		})
+	case co.FabricGeneve:
+		ports := make(map[uint32]bool, 2)
+		for _, tun := range w.spec.Tunnels {
+			if ports[tun.DstPort] {
+				continue
+			}
+			ports[tun.DstPort] = true
+			rules = append(rules, cn.IpRule{
+				Table:   cn.TFilter,
+				Chain:   cn.OLCInput,
+				Proto:   "udp",
+				Match:   "udp",
+				DstPort: strconv.Itoa(int(tun.DstPort)),
+			})
+		}
+	}
+	return rules
+}
+
 func (w *FabricWorker) Start(v api.Switcher) {
 	w.out.Info("FabricWorker.Start")
 	firewall := v.Firewall()
 	mss := w.spec.Mss
+	for _, rule := range w.inputRules() {
+		firewall.AddRule(rule)
+	}
 	for _, tunnel := range w.spec.Tunnels {
 		w.AddTunnel(tunnel)
 	}
@@ -514,7 +755,7 @@ func (w *FabricWorker) DelNetwork(bridge string, vni uint32) {
 }
 
 func (w *FabricWorker) DelTunnel(remote string) {
-	name := w.Addr2Port(remote, "vx-")
+	name := w.Addr2Port(remote, w.tunnelPrefix())
 	_ = w.ovs.delPort(name)
 }
 
@@ -547,6 +788,56 @@ func (w *FabricWorker) Stop() {
 	}
 }
 
+func NewFabricPortSchema(port *OvsPort, stats *ovs.PortStats) schema.FabricPort {
+	obj := schema.FabricPort{
+		Name:   port.name,
+		Port:   port.portId,
+		Remote: port.options.RemoteIP,
+	}
+	if stats != nil {
+		obj.RxBytes = int64(stats.Received.Bytes)
+		obj.RxPackages = int64(stats.Received.Packets)
+		obj.TxBytes = int64(stats.Transmitted.Bytes)
+		obj.TxPackages = int64(stats.Transmitted.Packets)
+	}
+	return obj
+}
+
+// Stats gathers counters of tunnels from port stats, and of networks
+// from patch port and reply flow of arp responder.
+func (w *FabricWorker) Stats() schema.Fabric {
+	obj := schema.Fabric{
+		Name:     w.cfg.Name,
+		Bridge:   w.ovs.name,
+		Driver:   w.spec.Driver,
+		Tunnels:  make([]schema.FabricPort, 0, len(w.tunnels)+len(w.borders)),
+		Networks: make([]schema.FabricNetwork, 0, len(w.networks)),
+	}
+	for _, tun := range w.tunnels {
+		port := NewFabricPortSchema(tun, w.ovs.dumpPort(tun.name))
+		port.Mode = "tunnel"
+		obj.Tunnels = append(obj.Tunnels, port)
+	}
+	for _, bor := range w.borders {
+		port := NewFabricPortSchema(bor, w.ovs.dumpPort(bor.name))
+		port.Mode = "border"
+		obj.Tunnels = append(obj.Tunnels, port)
+	}
+	for vni, net := range w.networks {
+		patch := NewFabricPortSchema(net.patch, w.ovs.dumpPort(net.patch.name))
+		netObj := schema.FabricNetwork{
+			Vni:    vni,
+			Bridge: net.bridge,
+			Patch:  patch,
+		}
+		if stats := w.ovs.dumpAggregate(w.arpReplyMatch(vni)); stats != nil {
+			netObj.ArpReplies = int64(stats.PacketCount)
+		}
+		obj.Networks = append(obj.Networks, netObj)
+	}
+	return obj
+}
+
 func (w *FabricWorker) String() string {
 	return w.cfg.Name
 }
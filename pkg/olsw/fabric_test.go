@@ -0,0 +1,76 @@
+package olsw
+
+import (
+	co "github.com/danieldin95/openlan/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func newFabric(spec *co.FabricSpecifies) *FabricWorker {
+	c := &co.Network{
+		Name:      "fabric",
+		Provider:  "fabric",
+		Bridge:    &co.Bridge{Name: "br-fabric"},
+		Specifies: spec,
+	}
+	spec.Correct()
+	return NewFabricWorker(c)
+}
+
+func TestFabricWorker_InputRules(t *testing.T) {
+	w := newFabric(&co.FabricSpecifies{
+		Driver: co.FabricGeneve,
+		Tunnels: []*co.FabricTunnel{
+			{Remote: "10.0.0.2"},
+			{Remote: "10.0.0.3", DstPort: 6082},
+			{Remote: "10.0.0.4", DstPort: 6082},
+		},
+	})
+	rules := w.inputRules()
+	assert.Equal(t, 2, len(rules), "be the same.")
+	assert.Equal(t, "6081", rules[0].DstPort, "be the same.")
+	assert.Equal(t, "6082", rules[1].DstPort, "be the same.")
+
+	w = newFabric(&co.FabricSpecifies{Driver: co.FabricGRE})
+	rules = w.inputRules()
+	assert.Equal(t, 1, len(rules), "be the same.")
+	assert.Equal(t, "gre", rules[0].Proto, "be the same.")
+
+	w = newFabric(&co.FabricSpecifies{})
+	assert.Equal(t, 0, len(w.inputRules()), "be the same.")
+}
+
+func TestFabricWorker_ArpFlows(t *testing.T) {
+	w := newFabric(&co.FabricSpecifies{Driver: co.FabricGeneve})
+	learn, err := w.arpLearnFlow().MarshalText()
+	assert.Nil(t, err, "be nil.")
+	assert.Contains(t, string(learn), "table=4,", "be the same.")
+	assert.Contains(t, string(learn), "learn(priority=1,", "be the same.")
+	assert.Contains(t, string(learn), "table=6,", "be the same.")
+	assert.Contains(t, string(learn), "NXM_OF_ARP_TPA[]=NXM_OF_ARP_SPA[]", "by sender.")
+	assert.Contains(t, string(learn), "NXM_NX_TUN_METADATA0[0..31]", "by metadata.")
+	assert.Contains(t, string(learn), "load:NXM_NX_ARP_SHA[]->NXM_NX_XREG6[0..47]", "mac of sender.")
+
+	// stats is aggregated on the exact match of reply flow.
+	reply, err := w.arpReplyFlow(1024).MarshalText()
+	assert.Nil(t, err, "be nil.")
+	match, err := w.arpReplyMatch(1024).MarshalText()
+	assert.Nil(t, err, "be nil.")
+	assert.Contains(t, string(match), "tun_id=0x400", "be the same.")
+	assert.Contains(t, string(match), "table=8", "be the same.")
+	for _, field := range []string{"arp", "arp_op=1", "tun_id=0x400", "reg14=1"} {
+		assert.Contains(t, string(reply), field, "be the same.")
+	}
+	assert.Contains(t, string(reply), "priority=2", "be the same.")
+	assert.Contains(t, string(reply), "load:2->NXM_OF_ARP_OP[]", "turn into reply.")
+	assert.Contains(t, string(reply), "in_port", "send back.")
+
+	cfg := &co.FabricNetwork{Vni: 1024, Metadata: 7}
+	flow := w.neighborFlow(cfg, co.FabricNeighbor{Address: "192.168.1.2", Mac: "52:54:00:01:02:03"})
+	text, err := flow.MarshalText()
+	assert.Nil(t, err, "be nil.")
+	assert.Contains(t, string(text), "arp_tpa=192.168.1.2", "be the same.")
+	assert.Contains(t, string(text), "tun_metadata0=7", "be the same.")
+	assert.Contains(t, string(text), "load:0x525400010203->NXM_NX_XREG6[0..47]", "be the same.")
+	assert.Nil(t, w.neighborFlow(cfg, co.FabricNeighbor{Address: "x", Mac: "y"}), "be nil.")
+}
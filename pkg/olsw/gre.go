@@ -0,0 +1,270 @@
+package olsw
+
+import (
+	co "github.com/danieldin95/openlan/pkg/config"
+	"github.com/danieldin95/openlan/pkg/libol"
+	"github.com/danieldin95/openlan/pkg/network"
+	"github.com/danieldin95/openlan/pkg/olsw/api"
+	nl "github.com/vishvananda/netlink"
+	"net"
+)
+
+type GreWorker struct {
+	uuid    string
+	cfg     *co.Network
+	spec    *co.GreSpecifies
+	out     *libol.SubLogger
+	br      network.Bridger
+	members map[string]co.GreMember // applied members by name.
+	mode    string                  // applied mode.
+	up      func(cfg *co.GreMember) error
+	down    func(cfg *co.GreMember) error
+}
+
+func NewGreWorker(c *co.Network) *GreWorker {
+	w := &GreWorker{
+		cfg:     c,
+		out:     libol.NewSubLogger(c.Name),
+		members: make(map[string]co.GreMember, 32),
+	}
+	w.spec, _ = c.Specifies.(*co.GreSpecifies)
+	w.up = w.UpGre
+	w.down = w.DownGre
+	return w
+}
+
+func (w *GreWorker) Initialize() {
+	if w.spec == nil {
+		return
+	}
+	br := w.cfg.Bridge
+	if br != nil && w.spec.IsL2() {
+		w.br = network.NewBridger(br.Provider, br.Name, br.IPMtu)
+		w.UpBr(br)
+	}
+}
+
+func (w *GreWorker) UpBr(cfg *co.Bridge) {
+	master := w.br
+	// new it and configure address
+	master.Open(cfg.Address)
+	// configure stp
+	if cfg.Stp == "on" {
+		if err := master.Stp(true); err != nil {
+			w.out.Warn("GreWorker.UpBr: Stp %s", err)
+		}
+	} else {
+		_ = master.Stp(false)
+	}
+	// configure forward delay
+	if err := master.Delay(cfg.Delay); err != nil {
+		w.out.Warn("GreWorker.UpBr: Delay %s", err)
+	}
+	if err := master.CallIptables(1); err != nil {
+		w.out.Warn("GreWorker.UpBr: CallIptables %s", err)
+	}
+}
+
+func (w *GreWorker) newLink(cfg *co.GreMember) nl.Link {
+	attrs := nl.LinkAttrs{
+		TxQLen: -1,
+		Name:   cfg.Name,
+	}
+	local := net.ParseIP(cfg.Local)
+	remote := net.ParseIP(cfg.Remote)
+	switch w.mode {
+	case co.GreTun:
+		return &nl.Gretun{
+			LinkAttrs: attrs,
+			Local:     local,
+			Remote:    remote,
+			IKey:      cfg.Key,
+			OKey:      cfg.Key,
+			Ttl:       cfg.Ttl,
+			PMtuDisc:  1,
+		}
+	case co.GreIpip:
+		return &nl.Iptun{
+			LinkAttrs: attrs,
+			Local:     local,
+			Remote:    remote,
+			Ttl:       cfg.Ttl,
+			PMtuDisc:  1,
+		}
+	default:
+		return &nl.Gretap{
+			LinkAttrs: attrs,
+			Local:     local,
+			Remote:    remote,
+			IKey:      cfg.Key,
+			OKey:      cfg.Key,
+			Ttl:       cfg.Ttl,
+			PMtuDisc:  1,
+		}
+	}
+}
+
+func (w *GreWorker) UpGre(cfg *co.GreMember) error {
+	link, _ := nl.LinkByName(cfg.Name)
+	if link == nil {
+		if err := nl.LinkAdd(w.newLink(cfg)); err != nil {
+			return err
+		}
+		link, _ = nl.LinkByName(cfg.Name)
+		if link == nil {
+			return libol.NewErr("%s notFound", cfg.Name)
+		}
+	}
+	if err := nl.LinkSetUp(link); err != nil {
+		w.out.Error("GreWorker.UpGre: %s", err)
+	}
+	if w.spec.IsL2() {
+		return w.setMaster(link)
+	}
+	if cfg.Address == "" {
+		return nil
+	}
+	addr, err := nl.ParseAddr(cfg.Address)
+	if err != nil {
+		return err
+	}
+	if err := nl.AddrReplace(link, addr); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (w *GreWorker) setMaster(link nl.Link) error {
+	br := w.cfg.Bridge
+	if br == nil {
+		return nil
+	}
+	master := &nl.Bridge{LinkAttrs: nl.LinkAttrs{
+		TxQLen: -1,
+		Name:   br.Name,
+	},
+	}
+	return nl.LinkSetMaster(link, master)
+}
+
+func (w *GreWorker) DownGre(cfg *co.GreMember) error {
+	link, _ := nl.LinkByName(cfg.Name)
+	if link == nil {
+		return nil
+	}
+	return nl.LinkDel(link)
+}
+
+// inputRule allows tunnels of mode, which are not allowed by default.
+func (w *GreWorker) inputRule() network.IpRule {
+	proto := "gre"
+	if w.spec.Mode == co.GreIpip {
+		proto = "4" // ipencap, but ipip is 94 in /etc/protocols.
+	}
+	return network.IpRule{
+		Table: network.TFilter,
+		Chain: network.OLCInput,
+		Proto: proto,
+	}
+}
+
+func (w *GreWorker) Start(v api.Switcher) {
+	w.uuid = v.UUID()
+	if w.spec == nil {
+		w.out.Error("GreWorker.Start spec is nil")
+		return
+	}
+	v.Firewall().AddRule(w.inputRule())
+	w.mode = w.spec.Mode
+	for _, mem := range w.spec.Members {
+		if err := w.up(mem); err != nil {
+			w.out.Error("GreWorker.Start %s %s", mem.Name, err)
+			continue
+		}
+		w.members[mem.Name] = *mem
+	}
+}
+
+func (w *GreWorker) Stop() {
+	if w.spec == nil {
+		w.out.Error("GreWorker.Stop spec is nil")
+		return
+	}
+	for name, mem := range w.members {
+		delete(w.members, name)
+		if err := w.down(&mem); err != nil {
+			w.out.Error("GreWorker.Stop %s %s", mem.Name, err)
+		}
+	}
+}
+
+func (w *GreWorker) Close() {
+	if w.br != nil {
+		if err := w.br.Close(); err != nil {
+			w.out.Warn("GreWorker.Close %s", err)
+		}
+	}
+}
+
+func (w *GreWorker) String() string {
+	return w.cfg.Name
+}
+
+func (w *GreWorker) ID() string {
+	return w.uuid
+}
+
+func (w *GreWorker) GetBridge() network.Bridger {
+	return w.br
+}
+
+func (w *GreWorker) GetConfig() *co.Network {
+	return w.cfg
+}
+
+func (w *GreWorker) GetSubnet() string {
+	w.out.Warn("GreWorker.GetSubnet notSupport")
+	return ""
+}
+
+// Reload applies delta of members, and unchanged are kept.
+func (w *GreWorker) Reload(c *co.Network) {
+	if c != nil {
+		w.cfg = c
+		w.spec, _ = c.Specifies.(*co.GreSpecifies)
+	}
+	if w.spec == nil {
+		w.out.Error("GreWorker.Reload spec is nil")
+		return
+	}
+	if w.mode != w.spec.Mode {
+		w.out.Info("GreWorker.Reload mode %s to %s", w.mode, w.spec.Mode)
+		w.Stop()
+		w.mode = w.spec.Mode
+	}
+	wanted := make(map[string]*co.GreMember, len(w.spec.Members))
+	for _, mem := range w.spec.Members {
+		wanted[mem.Name] = mem
+	}
+	for name, mem := range w.members {
+		if obj, ok := wanted[name]; ok && obj.Equal(&mem) {
+			continue
+		}
+		w.out.Info("GreWorker.Reload down %s", name)
+		delete(w.members, name)
+		if err := w.down(&mem); err != nil {
+			w.out.Error("GreWorker.Reload %s %s", name, err)
+		}
+	}
+	for name, mem := range wanted {
+		if _, ok := w.members[name]; ok {
+			continue
+		}
+		w.out.Info("GreWorker.Reload up %s", name)
+		if err := w.up(mem); err != nil {
+			w.out.Error("GreWorker.Reload %s %s", name, err)
+			continue
+		}
+		w.members[name] = *mem
+	}
+}
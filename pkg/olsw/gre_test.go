@@ -0,0 +1,87 @@
+package olsw
+
+import (
+	co "github.com/danieldin95/openlan/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+type fakeGre struct {
+	ups   []string
+	downs []string
+}
+
+func (f *fakeGre) up(cfg *co.GreMember) error {
+	f.ups = append(f.ups, cfg.Name+"@"+cfg.Remote)
+	return nil
+}
+
+func (f *fakeGre) down(cfg *co.GreMember) error {
+	f.downs = append(f.downs, cfg.Name+"@"+cfg.Remote)
+	return nil
+}
+
+func (f *fakeGre) applied() ([]string, []string) {
+	ups, downs := f.ups, f.downs
+	sort.Strings(ups)
+	sort.Strings(downs)
+	f.ups, f.downs = nil, nil
+	return ups, downs
+}
+
+func newFakeGre(t *testing.T, mode string, members ...*co.GreMember) (*GreWorker, *fakeGre) {
+	c := &co.Network{
+		Name:      "gre",
+		Provider:  "gre",
+		File:      filepath.Join(t.TempDir(), "gre.json"),
+		Specifies: &co.GreSpecifies{Mode: mode, Members: members},
+	}
+	c.Correct()
+	w := NewGreWorker(c)
+	f := &fakeGre{}
+	w.up = f.up
+	w.down = f.down
+	return w, f
+}
+
+func TestGreWorker_InputRule(t *testing.T) {
+	w, _ := newFakeGre(t, co.GreIpip)
+	assert.Equal(t, "4", w.inputRule().Proto, "ipencap.")
+	w, _ = newFakeGre(t, co.GreTap)
+	assert.Equal(t, "gre", w.inputRule().Proto, "be the same.")
+}
+
+func TestGreWorker_Reload(t *testing.T) {
+	w, f := newFakeGre(t, co.GreTun,
+		&co.GreMember{Name: "gre-a", Remote: "10.0.0.2"},
+		&co.GreMember{Name: "gre-b", Remote: "10.0.0.3"})
+	w.Reload(nil)
+	ups, downs := f.applied()
+	assert.Equal(t, []string{"gre-a@10.0.0.2", "gre-b@10.0.0.3"}, ups, "be the same.")
+	assert.Equal(t, 0, len(downs), "be the same.")
+
+	// unchanged are kept, and changed is down and up again.
+	err := w.spec.AddMembers(&co.GreSpecifies{
+		Members: []*co.GreMember{
+			{Name: "gre-b", Remote: "10.0.0.4"},
+			{Name: "gre-c", Remote: "10.0.0.5"},
+		},
+	})
+	assert.Nil(t, err, "be nil.")
+	w.Reload(nil)
+	ups, downs = f.applied()
+	assert.Equal(t, []string{"gre-b@10.0.0.4", "gre-c@10.0.0.5"}, ups, "be the same.")
+	assert.Equal(t, []string{"gre-b@10.0.0.3"}, downs, "be the same.")
+
+	err = w.spec.DelMembers(&co.GreSpecifies{
+		Members: []*co.GreMember{{Remote: "10.0.0.2"}},
+	})
+	assert.Nil(t, err, "be nil.")
+	w.Reload(nil)
+	ups, downs = f.applied()
+	assert.Equal(t, 0, len(ups), "be the same.")
+	assert.Equal(t, []string{"gre-a@10.0.0.2"}, downs, "be the same.")
+	assert.Equal(t, 2, len(w.members), "be the same.")
+}
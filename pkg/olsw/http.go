@@ -130,7 +130,9 @@ func (h *Http) LoadRouter() {
 	api.Device{}.Router(router)
 	api.VPNClient{}.Router(router)
 	api.PProf{}.Router(router)
-	api.VxLAN{}.Router(router)
+	api.VxLAN{Switcher: h.switcher}.Router(router)
+	api.Fabric{Switcher: h.switcher}.Router(router)
+	api.Gre{Switcher: h.switcher}.Router(router)
 	api.Esp{}.Router(router)
 	api.EspState{}.Router(router)
 	api.EspPolicy{}.Router(router)
@@ -0,0 +1,564 @@
+package olsw
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	co "github.com/danieldin95/openlan/pkg/config"
+	"github.com/danieldin95/openlan/pkg/libol"
+	"golang.org/x/crypto/hkdf"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	IkeInit    = "init"
+	IkeResp    = "resp"
+	IkeConfirm = "confirm"
+	IkeGrace   = 30  // seconds to keep previous SA.
+	IkeRetry   = 2   // seconds to resend init.
+	IkeWindow  = 300 // seconds of message accepted.
+)
+
+// IkeMagic follows the non-esp marker of eight zeros, so the kernel passes
+// message up to openudp on EspUdp port, and openudp relays it with address
+// of peer between the wire and IkeServer on loopback.
+var IkeMagic = []byte("OLIK")
+
+const ikeHeader = 8 + 4 // marker and magic.
+
+// IkePack returns message to relay with address of peer.
+func IkePack(addr *net.UDPAddr, data []byte) []byte {
+	buf := make([]byte, ikeHeader+6+len(data))
+	copy(buf[8:12], IkeMagic)
+	copy(buf[12:16], addr.IP.To4())
+	binary.BigEndian.PutUint16(buf[16:18], uint16(addr.Port))
+	copy(buf[18:], data)
+	return buf
+}
+
+// IkeUnpack returns address of peer and message from relayed data.
+func IkeUnpack(data []byte) (*net.UDPAddr, []byte, error) {
+	if len(data) < ikeHeader+6 {
+		return nil, nil, libol.NewErr("too short")
+	}
+	if binary.BigEndian.Uint64(data[:8]) != 0 || !bytes.Equal(data[8:12], IkeMagic) {
+		return nil, nil, libol.NewErr("wrong magic")
+	}
+	addr := &net.UDPAddr{
+		IP:   net.IPv4(data[12], data[13], data[14], data[15]),
+		Port: int(binary.BigEndian.Uint16(data[16:18])),
+	}
+	return addr, data[18:], nil
+}
+
+// IkeMessage is relayed between members by openudp on EspUdp port, and id
+// is spi of member configured on both sides. Seq is increased by
+// sender from unix time in nanoseconds to refuse replayed message.
+type IkeMessage struct {
+	Type   string `json:"type"`
+	Id     int    `json:"id"`
+	Seq    uint64 `json:"seq"`
+	Spi    int    `json:"spi"`
+	Public []byte `json:"public,omitempty"`
+	Nonce  []byte `json:"nonce,omitempty"`
+	Mac    []byte `json:"mac,omitempty"`
+}
+
+func (m *IkeMessage) Sum(secret string) []byte {
+	h := hmac.New(sha256.New, []byte(secret))
+	_, _ = fmt.Fprintf(h, "%s:%d:%d:%d:", m.Type, m.Id, m.Seq, m.Spi)
+	_, _ = h.Write(m.Public)
+	_, _ = h.Write(m.Nonce)
+	return h.Sum(nil)
+}
+
+func (m *IkeMessage) Sign(secret string) {
+	m.Mac = m.Sum(secret)
+}
+
+func (m *IkeMessage) Verify(secret string) bool {
+	return hmac.Equal(m.Mac, m.Sum(secret))
+}
+
+type IkeKey struct {
+	private []byte
+	Public  []byte
+	Nonce   []byte
+}
+
+func NewIkeKey() (*IkeKey, error) {
+	curve := elliptic.P256()
+	private, x, y, err := elliptic.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	k := &IkeKey{
+		private: private,
+		Public:  elliptic.Marshal(curve, x, y),
+		Nonce:   make([]byte, 16),
+	}
+	if _, err := rand.Read(k.Nonce); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Derive returns auth and crypt keys from shared secret with peer.
+func (k *IkeKey) Derive(public, nonceI, nonceR []byte, spi int) ([]byte, []byte, error) {
+	curve := elliptic.P256()
+	x, y := elliptic.Unmarshal(curve, public)
+	if x == nil {
+		return nil, nil, libol.NewErr("invalid public key")
+	}
+	sx, _ := curve.ScalarMult(x, y, k.private)
+	shared := sx.FillBytes(make([]byte, 32))
+	salt := append(append([]byte{}, nonceI...), nonceR...)
+	info := fmt.Sprintf("openlan esp %d", spi)
+	reader := hkdf.New(sha256.New, shared, salt, []byte(info))
+	auth := make([]byte, 32)
+	crypt := make([]byte, 32)
+	if _, err := io.ReadFull(reader, auth); err != nil {
+		return nil, nil, err
+	}
+	if _, err := io.ReadFull(reader, crypt); err != nil {
+		return nil, nil, err
+	}
+	return auth, crypt, nil
+}
+
+func NewIkeSpi() int {
+	buf := make([]byte, 4)
+	_, _ = rand.Read(buf)
+	return int(binary.BigEndian.Uint32(buf)&0x7fffffff | 0x100)
+}
+
+// IkeHandler installs negotiated keys of member.
+type IkeHandler interface {
+	AddSA(mem *co.ESPMember, spi int, auth, crypt []byte) error
+	DelSA(mem *co.ESPMember, spi int)
+	SwitchSA(mem *co.ESPMember, spi int) error // outbound to spi.
+	SABytes(mem *co.ESPMember, spi int) int64
+	OnKeyed(mem *co.ESPMember, spi int, keyTime, nextRekey int64)
+}
+
+type IkeSA struct {
+	Spi      int
+	KeyTime  int64
+	DeleteAt int64
+}
+
+type ikePending struct {
+	msg    *IkeMessage
+	key    *IkeKey
+	sentAt int64
+}
+
+type IkeMember struct {
+	mem       *co.ESPMember
+	handler   IkeHandler
+	initiator bool
+	remote    *net.UDPAddr
+	current   *IkeSA
+	previous  *IkeSA
+	switched  bool // outbound switched to current.
+	pending   *ikePending
+	last      *IkeMessage // replied for retransmission.
+	nextRekey int64
+	askedAt   int64
+	seq       uint64 // last sent.
+	peerSeq   uint64 // last received.
+}
+
+func (m *IkeMember) Current() *IkeSA {
+	return m.current
+}
+
+func (m *IkeMember) nextSeq() uint64 {
+	seq := uint64(time.Now().UnixNano())
+	if seq <= m.seq {
+		seq = m.seq + 1
+	}
+	m.seq = seq
+	return seq
+}
+
+// fresh accepts message in window of time and newer than received.
+func (m *IkeMember) fresh(recv *IkeMessage) bool {
+	now := time.Now().UnixNano()
+	diff := now - int64(recv.Seq)
+	if diff > IkeWindow*1e9 || diff < -IkeWindow*1e9 {
+		return false
+	}
+	if recv.Seq <= m.peerSeq {
+		return false
+	}
+	m.peerSeq = recv.Seq
+	return true
+}
+
+// used returns true if spi is installed already.
+func (m *IkeMember) used(spi int) bool {
+	if m.current != nil && m.current.Spi == spi {
+		return true
+	}
+	return m.previous != nil && m.previous.Spi == spi
+}
+
+type IkeServer struct {
+	lock    sync.Mutex
+	listen  string
+	port    int
+	relay   *net.UDPAddr // openudp on loopback.
+	conn    *net.UDPConn
+	members map[int]*IkeMember
+	done    chan bool
+	out     *libol.SubLogger
+}
+
+func NewIkeServer(listen string, port, relay int) *IkeServer {
+	return &IkeServer{
+		listen:  listen,
+		port:    port,
+		relay:   &net.UDPAddr{IP: net.ParseIP(listen), Port: relay},
+		members: make(map[int]*IkeMember, 32),
+		done:    make(chan bool, 2),
+		out:     libol.NewSubLogger("ike"),
+	}
+}
+
+// Ike listens on loopback only, and peers reach it through openudp.
+var Ike = NewIkeServer("127.0.0.1", co.EspIke, co.EspUdp)
+
+func (i *IkeServer) Add(mem *co.ESPMember, handler IkeHandler) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	ste := mem.State
+	m := &IkeMember{
+		mem:       mem,
+		handler:   handler,
+		initiator: bytes.Compare(ste.LocalIp.To16(), ste.RemoteIp.To16()) < 0,
+		remote:    &net.UDPAddr{IP: ste.RemoteIp, Port: ste.RemotePort},
+	}
+	i.out.Info("IkeServer.Add %d %s-%s initiator:%v", mem.Spi, ste.LocalIp, ste.RemoteIp, m.initiator)
+	i.members[mem.Spi] = m
+}
+
+func (i *IkeServer) Del(mem *co.ESPMember) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	m, ok := i.members[mem.Spi]
+	if !ok {
+		return
+	}
+	i.out.Info("IkeServer.Del %d", mem.Spi)
+	if m.previous != nil {
+		m.handler.DelSA(mem, m.previous.Spi)
+	}
+	if m.current != nil {
+		m.handler.DelSA(mem, m.current.Spi)
+	}
+	delete(i.members, mem.Spi)
+}
+
+func (i *IkeServer) Get(id int) *IkeMember {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	return i.members[id]
+}
+
+// send signs message with a new seq, and also for retransmission.
+func (i *IkeServer) send(m *IkeMember, msg *IkeMessage) {
+	if i.conn == nil {
+		return
+	}
+	msg.Seq = m.nextSeq()
+	msg.Sign(m.mem.State.Secret)
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	if _, err := i.conn.WriteToUDP(IkePack(m.remote, data), i.relay); err != nil {
+		i.out.Debug("IkeServer.send %s %s", m.remote, err)
+	}
+}
+
+// rotate makes spi as current, and previous is deleted after grace.
+func (i *IkeServer) rotate(m *IkeMember, spi int) {
+	now := time.Now().Unix()
+	if m.previous != nil {
+		m.handler.DelSA(m.mem, m.previous.Spi)
+	}
+	m.previous = m.current
+	if m.previous != nil {
+		m.previous.DeleteAt = now + IkeGrace
+	}
+	m.current = &IkeSA{Spi: spi, KeyTime: now}
+	m.switched = false
+	m.nextRekey = now + int64(m.mem.State.RekeyTime)
+	m.handler.OnKeyed(m.mem, spi, now, m.nextRekey)
+}
+
+func (i *IkeServer) onInit(m *IkeMember, recv *IkeMessage) {
+	if m.initiator {
+		// responder asks for keys after restarted.
+		if recv.Spi == 0 && m.pending == nil {
+			i.out.Info("IkeServer.onInit %d asked by responder", recv.Id)
+			m.nextRekey = 0
+		}
+		return
+	}
+	if recv.Spi == 0 {
+		return
+	}
+	if m.last != nil && m.last.Spi == recv.Spi {
+		i.send(m, m.last)
+		return
+	}
+	if m.used(recv.Spi) {
+		i.out.Warn("IkeServer.onInit %d spi %d already used", recv.Id, recv.Spi)
+		return
+	}
+	key, err := NewIkeKey()
+	if err != nil {
+		i.out.Error("IkeServer.onInit %s", err)
+		return
+	}
+	auth, crypt, err := key.Derive(recv.Public, recv.Nonce, key.Nonce, recv.Spi)
+	if err != nil {
+		i.out.Warn("IkeServer.onInit %d %s", recv.Id, err)
+		return
+	}
+	if err := m.handler.AddSA(m.mem, recv.Spi, auth, crypt); err != nil {
+		i.out.Error("IkeServer.onInit %d %s", recv.Id, err)
+		return
+	}
+	i.out.Info("IkeServer.onInit %d keyed spi %d", recv.Id, recv.Spi)
+	i.rotate(m, recv.Spi)
+	resp := &IkeMessage{
+		Type:   IkeResp,
+		Id:     recv.Id,
+		Spi:    recv.Spi,
+		Public: key.Public,
+		Nonce:  key.Nonce,
+	}
+	m.last = resp
+	i.send(m, resp)
+}
+
+func (i *IkeServer) onResp(m *IkeMember, recv *IkeMessage) {
+	if !m.initiator {
+		return
+	}
+	if m.pending == nil || m.pending.msg.Spi != recv.Spi {
+		if m.last != nil && m.last.Spi == recv.Spi {
+			i.send(m, m.last)
+		}
+		return
+	}
+	p := m.pending
+	auth, crypt, err := p.key.Derive(recv.Public, p.key.Nonce, recv.Nonce, recv.Spi)
+	if err != nil {
+		i.out.Warn("IkeServer.onResp %d %s", recv.Id, err)
+		return
+	}
+	if err := m.handler.AddSA(m.mem, recv.Spi, auth, crypt); err != nil {
+		i.out.Error("IkeServer.onResp %d %s", recv.Id, err)
+		return
+	}
+	i.out.Info("IkeServer.onResp %d keyed spi %d", recv.Id, recv.Spi)
+	m.pending = nil
+	i.rotate(m, recv.Spi)
+	// peer already has inbound SA, so switch outbound now.
+	if err := m.handler.SwitchSA(m.mem, recv.Spi); err != nil {
+		i.out.Error("IkeServer.onResp %d %s", recv.Id, err)
+	} else {
+		m.switched = true
+	}
+	confirm := &IkeMessage{
+		Type: IkeConfirm,
+		Id:   recv.Id,
+		Spi:  recv.Spi,
+	}
+	m.last = confirm
+	i.send(m, confirm)
+}
+
+func (i *IkeServer) onConfirm(m *IkeMember, recv *IkeMessage) {
+	if m.initiator || m.current == nil || m.current.Spi != recv.Spi || m.switched {
+		return
+	}
+	if err := m.handler.SwitchSA(m.mem, recv.Spi); err != nil {
+		i.out.Error("IkeServer.onConfirm %d %s", recv.Id, err)
+		return
+	}
+	m.switched = true
+}
+
+func (i *IkeServer) onMessage(from *net.UDPAddr, data []byte) {
+	recv := &IkeMessage{}
+	if err := json.Unmarshal(data, recv); err != nil {
+		i.out.Debug("IkeServer.onMessage %s invalid json data", from)
+		return
+	}
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	m, ok := i.members[recv.Id]
+	if !ok || !m.remote.IP.Equal(from.IP) {
+		i.out.Debug("IkeServer.onMessage %d from %s notFound", recv.Id, from)
+		return
+	}
+	if !recv.Verify(m.mem.State.Secret) {
+		i.out.Warn("IkeServer.onMessage %d from %s wrong mac", recv.Id, from)
+		return
+	}
+	if !m.fresh(recv) {
+		i.out.Warn("IkeServer.onMessage %d from %s stale seq %d", recv.Id, from, recv.Seq)
+		return
+	}
+	switch recv.Type {
+	case IkeInit:
+		i.onInit(m, recv)
+	case IkeResp:
+		i.onResp(m, recv)
+	case IkeConfirm:
+		i.onConfirm(m, recv)
+	}
+}
+
+func (i *IkeServer) needRekey(m *IkeMember, now int64) bool {
+	if m.current == nil {
+		return true
+	}
+	if m.previous != nil {
+		return false // wait previous deleted.
+	}
+	if now >= m.nextRekey {
+		return true
+	}
+	limit := m.mem.State.RekeyBytes
+	return limit > 0 && m.handler.SABytes(m.mem, m.current.Spi) >= limit
+}
+
+func (i *IkeServer) doTicker() {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	now := time.Now().Unix()
+	for _, m := range i.members {
+		if p := m.previous; p != nil && now >= p.DeleteAt {
+			if !m.switched {
+				if err := m.handler.SwitchSA(m.mem, m.current.Spi); err == nil {
+					m.switched = true
+				}
+			}
+			m.handler.DelSA(m.mem, p.Spi)
+			m.previous = nil
+		}
+		if !m.initiator {
+			if m.current == nil && now-m.askedAt >= IkeRetry {
+				m.askedAt = now
+				ask := &IkeMessage{Type: IkeInit, Id: m.mem.Spi}
+				i.send(m, ask)
+			}
+			continue
+		}
+		if p := m.pending; p != nil {
+			if now-p.sentAt >= IkeRetry {
+				p.sentAt = now
+				i.send(m, p.msg)
+			}
+			continue
+		}
+		if !i.needRekey(m, now) {
+			continue
+		}
+		key, err := NewIkeKey()
+		if err != nil {
+			i.out.Error("IkeServer.doTicker %s", err)
+			continue
+		}
+		msg := &IkeMessage{
+			Type:   IkeInit,
+			Id:     m.mem.Spi,
+			Spi:    NewIkeSpi(),
+			Public: key.Public,
+			Nonce:  key.Nonce,
+		}
+		m.pending = &ikePending{msg: msg, key: key, sentAt: now}
+		i.out.Info("IkeServer.doTicker %d negotiate spi %d", msg.Id, msg.Spi)
+		i.send(m, msg)
+	}
+}
+
+func (i *IkeServer) Read(conn *net.UDPConn) {
+	buf := make([]byte, 4096)
+	for {
+		n, relay, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			i.out.Info("IkeServer.Read %s", err)
+			return
+		}
+		if !relay.IP.IsLoopback() {
+			i.out.Debug("IkeServer.Read %s not relay", relay)
+			continue
+		}
+		from, data, err := IkeUnpack(buf[:n])
+		if err != nil {
+			i.out.Debug("IkeServer.Read %s %s", relay, err)
+			continue
+		}
+		i.onMessage(from, data)
+	}
+}
+
+func (i *IkeServer) Loop() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-i.done:
+			return
+		case <-ticker.C:
+			i.doTicker()
+		}
+	}
+}
+
+// Start listens once and serves for all esp networks.
+func (i *IkeServer) Start() {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	if i.conn != nil {
+		return
+	}
+	addr := &net.UDPAddr{IP: net.ParseIP(i.listen), Port: i.port}
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		i.out.Error("IkeServer.Start %s", err)
+		return
+	}
+	i.out.Info("IkeServer.Start %s", conn.LocalAddr())
+	i.conn = conn
+	libol.Go(func() {
+		i.Read(conn)
+	})
+	libol.Go(i.Loop)
+}
+
+func (i *IkeServer) Stop() {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	if i.conn == nil {
+		return
+	}
+	i.done <- true
+	_ = i.conn.Close()
+	i.conn = nil
+}
@@ -0,0 +1,182 @@
+package olsw
+
+import (
+	"bytes"
+	co "github.com/danieldin95/openlan/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeSA struct {
+	lock   sync.Mutex
+	keys   map[int][]byte
+	output int
+}
+
+func (f *fakeSA) AddSA(mem *co.ESPMember, spi int, auth, crypt []byte) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.keys[spi] = append(auth, crypt...)
+	return nil
+}
+
+func (f *fakeSA) DelSA(mem *co.ESPMember, spi int) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	delete(f.keys, spi)
+}
+
+func (f *fakeSA) SwitchSA(mem *co.ESPMember, spi int) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.output = spi
+	return nil
+}
+
+func (f *fakeSA) SABytes(mem *co.ESPMember, spi int) int64 {
+	return 0
+}
+
+func (f *fakeSA) OnKeyed(mem *co.ESPMember, spi int, keyTime, nextRekey int64) {
+}
+
+func (f *fakeSA) Output() (int, []byte) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.output, f.keys[f.output]
+}
+
+func TestIkeKey_Derive(t *testing.T) {
+	ki, _ := NewIkeKey()
+	kr, _ := NewIkeKey()
+	ai, ci, err := ki.Derive(kr.Public, ki.Nonce, kr.Nonce, 0x100)
+	assert.Nil(t, err, "be nil.")
+	ar, cr, err := kr.Derive(ki.Public, ki.Nonce, kr.Nonce, 0x100)
+	assert.Nil(t, err, "be nil.")
+	assert.Equal(t, ai, ar, "be the same.")
+	assert.Equal(t, ci, cr, "be the same.")
+	assert.Equal(t, 32, len(ci), "be the same.")
+	_, _, err = ki.Derive([]byte("hi"), ki.Nonce, kr.Nonce, 0x100)
+	assert.NotNil(t, err, "not nil.")
+}
+
+func TestIkeMessage_Verify(t *testing.T) {
+	m := &IkeMessage{Type: IkeInit, Id: 1, Spi: 0x200, Nonce: []byte("nonce")}
+	m.Sign("secret")
+	assert.True(t, m.Verify("secret"), "be true.")
+	assert.False(t, m.Verify("others"), "be false.")
+	m.Spi = 0x201
+	assert.False(t, m.Verify("secret"), "be false.")
+}
+
+// fakeRelay works as openudp, which relays between peers and ike on loopback.
+func fakeRelay(t *testing.T, listen string, port, ike int) *net.UDPConn {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP(listen), Port: port})
+	assert.Nil(t, err, "be nil.")
+	local := &net.UDPAddr{IP: net.ParseIP(listen), Port: ike}
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, from, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			if from.String() == local.String() {
+				addr, data, err := IkeUnpack(buf[:n])
+				if err != nil {
+					continue
+				}
+				wire := append(append(make([]byte, 8), IkeMagic...), data...)
+				_, _ = conn.WriteToUDP(wire, addr)
+			} else if n > ikeHeader {
+				_, _ = conn.WriteToUDP(IkePack(from, buf[ikeHeader:n]), local)
+			}
+		}
+	}()
+	return conn
+}
+
+func TestIkePack(t *testing.T) {
+	addr := &net.UDPAddr{IP: net.ParseIP("192.168.1.2"), Port: 4600}
+	data := IkePack(addr, []byte("{}"))
+	from, body, err := IkeUnpack(data)
+	assert.Nil(t, err, "be nil.")
+	assert.Equal(t, addr.String(), from.String(), "be the same.")
+	assert.Equal(t, "{}", string(body), "be the same.")
+	_, _, err = IkeUnpack(data[:ikeHeader])
+	assert.NotNil(t, err, "too short.")
+	data[0] = 1
+	_, _, err = IkeUnpack(data)
+	assert.NotNil(t, err, "wrong marker.")
+}
+
+func TestIkeServer_Negotiate(t *testing.T) {
+	newMember := func(local, remote string) *co.ESPMember {
+		return &co.ESPMember{
+			Spi: 0x100,
+			State: co.EspState{
+				LocalIp:    net.ParseIP(local),
+				RemoteIp:   net.ParseIP(remote),
+				RemotePort: 14600,
+				Secret:     "secret",
+				RekeyTime:  3600,
+				Auto:       true,
+			},
+		}
+	}
+	ri := fakeRelay(t, "127.0.0.1", 14600, 14601)
+	rr := fakeRelay(t, "127.0.0.2", 14600, 14601)
+	defer ri.Close()
+	defer rr.Close()
+	si := NewIkeServer("127.0.0.1", 14601, 14600)
+	sr := NewIkeServer("127.0.0.2", 14601, 14600)
+	fi := &fakeSA{keys: make(map[int][]byte)}
+	fr := &fakeSA{keys: make(map[int][]byte)}
+	si.Add(newMember("127.0.0.1", "127.0.0.2"), fi)
+	sr.Add(newMember("127.0.0.2", "127.0.0.1"), fr)
+	si.Start()
+	sr.Start()
+	defer si.Stop()
+	defer sr.Stop()
+
+	for i := 0; i < 50; i++ {
+		if spi, _ := fr.Output(); spi != 0 {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	spiI, keyI := fi.Output()
+	spiR, keyR := fr.Output()
+	assert.NotEqual(t, 0, spiI, "not zero.")
+	assert.Equal(t, spiI, spiR, "be the same.")
+	assert.True(t, bytes.Equal(keyI, keyR), "be the same.")
+	assert.Equal(t, spiI, si.Get(0x100).Current().Spi, "be the same.")
+}
+
+func TestIkeMember_Fresh(t *testing.T) {
+	m := &IkeMember{}
+	now := uint64(time.Now().UnixNano())
+	recv := &IkeMessage{Type: IkeInit, Id: 1, Seq: now}
+	assert.True(t, m.fresh(recv), "be true.")
+	assert.False(t, m.fresh(recv), "replayed.")
+	recv.Seq = now - 1
+	assert.False(t, m.fresh(recv), "stale.")
+	recv.Seq = now - uint64(2*IkeWindow*time.Second)
+	m.peerSeq = 0
+	assert.False(t, m.fresh(recv), "out of window.")
+	recv.Seq = now + 1
+	assert.True(t, m.fresh(recv), "be true.")
+}
+
+func TestIkeMember_Used(t *testing.T) {
+	m := &IkeMember{
+		current:  &IkeSA{Spi: 0x200},
+		previous: &IkeSA{Spi: 0x100},
+	}
+	assert.True(t, m.used(0x200), "be true.")
+	assert.True(t, m.used(0x100), "be true.")
+	assert.False(t, m.used(0x300), "be false.")
+}
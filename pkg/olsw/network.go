@@ -29,6 +29,8 @@ func NewNetworker(c *co.Network) Networker {
 		obj = NewVxLANWorker(c)
 	case "fabric":
 		obj = NewFabricWorker(c)
+	case "gre":
+		obj = NewGreWorker(c)
 	default:
 		obj = NewOpenLANWorker(c)
 	}
@@ -40,6 +42,10 @@ func GetWorker(name string) Networker {
 	return workers[name]
 }
 
+func DelWorker(name string) {
+	delete(workers, name)
+}
+
 func ListWorker(call func(w Networker)) {
 	for _, worker := range workers {
 		call(worker)
@@ -212,6 +212,11 @@ func (w *OpenLANWorker) UpBridge(cfg *co.Bridge) {
 	if err := master.Delay(cfg.Delay); err != nil {
 		w.out.Warn("OpenLANWorker.UpBridge: Delay %s", err)
 	}
+	if vir, ok := master.(*network.VirtualBridge); ok {
+		vir.SetFlap(cfg.FlapLimit, cfg.FlapBlock)
+		vir.SetStorm(cfg.Storm)
+		vir.Snooping(cfg.Snooping == "on")
+	}
 	w.connectPeer(cfg)
 	call := 1
 	if w.cfg.Acl == "" {
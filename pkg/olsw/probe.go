@@ -0,0 +1,224 @@
+package olsw
+
+import (
+	"encoding/json"
+	co "github.com/danieldin95/openlan/pkg/config"
+	"github.com/danieldin95/openlan/pkg/libol"
+	"github.com/danieldin95/openlan/pkg/models"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ProbeEcho  = "echo"
+	ProbeReply = "reply"
+	ProbeUp    = "up"
+	ProbeDown  = "down"
+)
+
+// ProbeMessage is sent between tunnel addresses of members, so it
+// passes through xfrm policies.
+type ProbeMessage struct {
+	Type string `json:"type"`
+	Id   int    `json:"id"`
+	Seq  uint64 `json:"seq"`
+	Time int64  `json:"time"` // sent at in nanoseconds.
+}
+
+type ProbeMember struct {
+	mem    *co.ESPMember
+	local  string
+	peer   *net.UDPAddr
+	seq    uint64
+	sentAt int64
+	recvAt int64
+	health *models.EspHealth
+}
+
+type probeSocket struct {
+	conn *net.UDPConn
+	refs int
+}
+
+type EspProber struct {
+	lock    sync.Mutex
+	port    int
+	sockets map[string]*probeSocket
+	members map[int]*ProbeMember
+	done    chan bool
+	running bool
+	out     *libol.SubLogger
+}
+
+func NewEspProber(port int) *EspProber {
+	return &EspProber{
+		port:    port,
+		sockets: make(map[string]*probeSocket, 32),
+		members: make(map[int]*ProbeMember, 32),
+		done:    make(chan bool, 2),
+		out:     libol.NewSubLogger("probe"),
+	}
+}
+
+var Probe = NewEspProber(co.EspProbe)
+
+// Add probes peer of member from its tunnel address.
+func (p *EspProber) Add(mem *co.ESPMember, health *models.EspHealth) {
+	local := strings.SplitN(mem.Address, "/", 2)[0]
+	peer := net.ParseIP(strings.SplitN(mem.Peer, "/", 2)[0])
+	if net.ParseIP(local) == nil || peer == nil {
+		p.out.Warn("EspProber.Add %s invalid address", mem.Name)
+		return
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if _, ok := p.members[mem.Spi]; ok {
+		return
+	}
+	sock, ok := p.sockets[local]
+	if !ok {
+		addr := &net.UDPAddr{IP: net.ParseIP(local), Port: p.port}
+		conn, err := net.ListenUDP("udp", addr)
+		if err != nil {
+			p.out.Error("EspProber.Add %s", err)
+			return
+		}
+		sock = &probeSocket{conn: conn}
+		p.sockets[local] = sock
+		libol.Go(func() {
+			p.Read(conn)
+		})
+	}
+	sock.refs++
+	health.Reset(ProbeDown)
+	p.members[mem.Spi] = &ProbeMember{
+		mem:    mem,
+		local:  local,
+		peer:   &net.UDPAddr{IP: peer, Port: p.port},
+		health: health,
+	}
+	p.out.Info("EspProber.Add %d %s->%s", mem.Spi, local, peer)
+	if !p.running {
+		p.running = true
+		libol.Go(p.Loop)
+	}
+}
+
+func (p *EspProber) Del(mem *co.ESPMember) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	m, ok := p.members[mem.Spi]
+	if !ok {
+		return
+	}
+	p.out.Info("EspProber.Del %d", mem.Spi)
+	delete(p.members, mem.Spi)
+	if sock, ok := p.sockets[m.local]; ok {
+		sock.refs--
+		if sock.refs <= 0 {
+			_ = sock.conn.Close()
+			delete(p.sockets, m.local)
+		}
+	}
+	if len(p.members) == 0 && p.running {
+		p.running = false
+		p.done <- true
+	}
+}
+
+func (p *EspProber) send(m *ProbeMember, msg *ProbeMessage, addr *net.UDPAddr) {
+	sock, ok := p.sockets[m.local]
+	if !ok {
+		return
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	if _, err := sock.conn.WriteToUDP(data, addr); err != nil {
+		p.out.Debug("EspProber.send %s %s", addr, err)
+	}
+}
+
+// change must be called with lock.
+func (p *EspProber) change(m *ProbeMember, status string) {
+	if m.health.SetStatus(status) {
+		p.out.Info("EspProber.change %d %s->%s %s", m.mem.Spi, m.local, m.peer.IP, status)
+	}
+}
+
+func (p *EspProber) onMessage(from *net.UDPAddr, data []byte) {
+	recv := &ProbeMessage{}
+	if err := json.Unmarshal(data, recv); err != nil {
+		p.out.Debug("EspProber.onMessage %s invalid json data", from)
+		return
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	m, ok := p.members[recv.Id]
+	if !ok || !m.peer.IP.Equal(from.IP) {
+		p.out.Debug("EspProber.onMessage %d from %s notFound", recv.Id, from)
+		return
+	}
+	switch recv.Type {
+	case ProbeEcho:
+		recv.Type = ProbeReply
+		p.send(m, recv, from)
+	case ProbeReply:
+		now := time.Now()
+		m.recvAt = now.Unix()
+		m.health.SetRtt((now.UnixNano() - recv.Time) / 1000)
+		p.change(m, ProbeUp)
+	}
+}
+
+func (p *EspProber) doTicker() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	now := time.Now()
+	for _, m := range p.members {
+		ste := m.mem.State
+		interval := int64(ste.Interval)
+		if now.Unix()-m.sentAt >= interval {
+			m.sentAt = now.Unix()
+			m.seq++
+			msg := &ProbeMessage{
+				Type: ProbeEcho,
+				Id:   m.mem.Spi,
+				Seq:  m.seq,
+				Time: now.UnixNano(),
+			}
+			p.send(m, msg, m.peer)
+		}
+		if now.Unix()-m.recvAt > interval*int64(ste.Multiplier) {
+			p.change(m, ProbeDown)
+		}
+	}
+}
+
+func (p *EspProber) Read(conn *net.UDPConn) {
+	buf := make([]byte, 1024)
+	for {
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			p.out.Info("EspProber.Read %s", err)
+			return
+		}
+		p.onMessage(from, buf[:n])
+	}
+}
+
+func (p *EspProber) Loop() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+			p.doTicker()
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package olsw
+
+import (
+	co "github.com/danieldin95/openlan/pkg/config"
+	"github.com/danieldin95/openlan/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestEspProber_Echo(t *testing.T) {
+	newMember := func(address, peer string) *co.ESPMember {
+		return &co.ESPMember{
+			Spi:     0x100,
+			Address: address,
+			Peer:    peer,
+			State: co.EspState{
+				Interval:   1,
+				Multiplier: 3,
+			},
+		}
+	}
+	pa := NewEspProber(14602)
+	pb := NewEspProber(14602)
+	ha := &models.EspHealth{}
+	hb := &models.EspHealth{}
+	ma := newMember("127.0.0.1/32", "127.0.0.2/32")
+	mb := newMember("127.0.0.2/32", "127.0.0.1/32")
+	pa.Add(ma, ha)
+	pb.Add(mb, hb)
+	assert.Equal(t, ProbeDown, ha.Get().Status, "be the same.")
+	for i := 0; i < 30; i++ {
+		if ha.Get().Status == ProbeUp {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	h := ha.Get()
+	assert.Equal(t, ProbeUp, h.Status, "be the same.")
+	assert.Equal(t, 0, h.Flaps, "be the same.")
+	pb.Del(mb)
+	pa.Del(ma)
+}
@@ -6,9 +6,13 @@ import (
 	"github.com/danieldin95/openlan/pkg/libol"
 	"github.com/danieldin95/openlan/pkg/models"
 	"github.com/danieldin95/openlan/pkg/network"
+	"github.com/danieldin95/openlan/pkg/olsw/api"
 	"github.com/danieldin95/openlan/pkg/olsw/app"
 	"github.com/danieldin95/openlan/pkg/olsw/cache"
+	"github.com/danieldin95/openlan/pkg/schema"
 	"net"
+	"os"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -17,22 +21,26 @@ import (
 func GetSocketServer(s *co.Switch) libol.SocketServer {
 	switch s.Protocol {
 	case "kcp":
-		c := libol.NewKcpConfig()
+		c := co.GetKcp(s.Kcp)
 		c.Block = co.GetBlock(s.Crypt)
 		c.Timeout = time.Duration(s.Timeout) * time.Second
 		return libol.NewKcpServer(s.Listen, c)
 	case "tcp":
 		c := &libol.TcpConfig{
-			Block:   co.GetBlock(s.Crypt),
-			Timeout: time.Duration(s.Timeout) * time.Second,
-			RdQus:   s.Queue.SockRd,
-			WrQus:   s.Queue.SockWr,
+			Block:     co.GetBlock(s.Crypt),
+			Timeout:   time.Duration(s.Timeout) * time.Second,
+			RdQus:     s.Queue.SockRd,
+			WrQus:     s.Queue.SockWr,
+			Listeners: s.Listeners,
 		}
 		return libol.NewTcpServer(s.Listen, c)
 	case "udp":
 		c := &libol.UdpConfig{
-			Block:   co.GetBlock(s.Crypt),
-			Timeout: time.Duration(s.Timeout) * time.Second,
+			Block:     co.GetBlock(s.Crypt),
+			Timeout:   time.Duration(s.Timeout) * time.Second,
+			Batch:     s.Queue.SockBt,
+			Offload:   s.Queue.SockOl,
+			Listeners: s.Listeners,
 		}
 		return libol.NewUdpServer(s.Listen, c)
 	case "ws":
@@ -57,13 +65,22 @@ func GetSocketServer(s *co.Switch) libol.SocketServer {
 			}
 		}
 		return libol.NewWebServer(s.Listen, c)
-	default:
-		c := &libol.TcpConfig{
+	case "mem":
+		c := &libol.MemConfig{
 			Block:   co.GetBlock(s.Crypt),
 			Timeout: time.Duration(s.Timeout) * time.Second,
 			RdQus:   s.Queue.SockRd,
 			WrQus:   s.Queue.SockWr,
 		}
+		return libol.NewMemServer(s.Listen, c)
+	default:
+		c := &libol.TcpConfig{
+			Block:     co.GetBlock(s.Crypt),
+			Timeout:   time.Duration(s.Timeout) * time.Second,
+			RdQus:     s.Queue.SockRd,
+			WrQus:     s.Queue.SockWr,
+			Listeners: s.Listeners,
+		}
 		if s.Cert != nil {
 			c.Tls = s.Cert.GetTlsCfg()
 		}
@@ -72,10 +89,11 @@ func GetSocketServer(s *co.Switch) libol.SocketServer {
 }
 
 type Apps struct {
-	Auth     *app.Access
-	Request  *app.Request
-	Neighbor *app.Neighbors
-	OnLines  *app.Online
+	Auth       *app.Access
+	Request    *app.Request
+	Neighbor   *app.Neighbors
+	OnLines    *app.Online
+	Rendezvous *app.Rendezvous
 }
 
 type Hook func(client libol.SocketClient, frame *libol.FrameMessage) error
@@ -94,6 +112,7 @@ type Switch struct {
 	newTime  int64
 	out      *libol.SubLogger
 	confd    *ConfD
+	zip      *libol.Compressor
 }
 
 func NewSwitch(c *co.Switch) *Switch {
@@ -107,6 +126,7 @@ func NewSwitch(c *co.Switch) *Switch {
 		hooks:    make([]Hook, 0, 64),
 		out:      libol.NewSubLogger(c.Alias),
 		confd:    NewConfd(),
+		zip:      co.GetCompressor(c.Compress),
 	}
 	return &v
 }
@@ -118,6 +138,28 @@ func (v *Switch) Protocol() string {
 	return v.cfg.Protocol
 }
 
+// Features returns what supported by this switch in login.
+func (v *Switch) Features() []string {
+	features := []string{libol.FeatRoute}
+	if v.cfg == nil {
+		return features
+	}
+	if crypt := v.cfg.Crypt.Feature(); crypt != "" {
+		features = append(features, crypt)
+	}
+	if v.cfg.Protocol == "udp" {
+		features = append(features, libol.FeatRoaming)
+	}
+	if v.zip != nil {
+		features = append(features, v.zip.Feature())
+	}
+	return features
+}
+
+func (v *Switch) Compressor() *libol.Compressor {
+	return v.zip
+}
+
 func (v *Switch) enablePort(protocol, port string) {
 	v.out.Info("Switch.enablePort %s %s", protocol, port)
 	// allowed forward between source and prefix.
@@ -269,47 +311,51 @@ func (v *Switch) preNetVPN1(bridge, prefix string, vCfg *co.OpenVPN) {
 	}
 }
 
-func (v *Switch) preNets() {
-	for _, nCfg := range v.cfg.Network {
-		name := nCfg.Name
-		w := NewNetworker(nCfg)
-		v.worker[name] = w
-		brCfg := nCfg.Bridge
-		if brCfg == nil {
-			continue
-		}
+func (v *Switch) preNet(w Networker) {
+	nCfg := w.GetConfig()
+	brCfg := nCfg.Bridge
+	if brCfg == nil {
+		return
+	}
 
-		v.preWorker(w)
-		brName := brCfg.Name
-		vCfg := nCfg.OpenVPN
+	v.preWorker(w)
+	brName := brCfg.Name
+	vCfg := nCfg.OpenVPN
 
-		v.enableAcl(nCfg.Acl, brName)
-		v.enableFwd(brName, brName, "", "")
-		ifAddr := strings.SplitN(brCfg.Address, "/", 2)[0]
-		// Enable MASQUERADE for OpenVPN
-		if vCfg != nil {
-			v.preNetVPN0(nCfg, vCfg)
-		}
-		if ifAddr == "" {
+	v.enableAcl(nCfg.Acl, brName)
+	v.enableFwd(brName, brName, "", "")
+	ifAddr := strings.SplitN(brCfg.Address, "/", 2)[0]
+	// Enable MASQUERADE for OpenVPN
+	if vCfg != nil {
+		v.preNetVPN0(nCfg, vCfg)
+	}
+	if ifAddr == "" {
+		return
+	}
+	subnet := w.GetSubnet()
+	// Enable MASQUERADE, and allowed forward.
+	for _, rt := range nCfg.Routes {
+		v.preNetVPN1(brName, rt.Prefix, vCfg)
+		if rt.NextHop != ifAddr {
 			continue
 		}
-		subnet := w.GetSubnet()
-		// Enable MASQUERADE, and allowed forward.
-		for _, rt := range nCfg.Routes {
-			v.preNetVPN1(brName, rt.Prefix, vCfg)
-			if rt.NextHop != ifAddr {
-				continue
-			}
-			v.enableFwd(brName, "", subnet, rt.Prefix)
-			if rt.MultiPath != nil {
-				v.enableSnat(brName, "", ifAddr, rt.Prefix)
-			} else if rt.Mode == "snat" {
-				v.enableMasq(brName, "", subnet, rt.Prefix)
-			}
+		v.enableFwd(brName, "", subnet, rt.Prefix)
+		if rt.MultiPath != nil {
+			v.enableSnat(brName, "", ifAddr, rt.Prefix)
+		} else if rt.Mode == "snat" {
+			v.enableMasq(brName, "", subnet, rt.Prefix)
 		}
 	}
 }
 
+func (v *Switch) preNets() {
+	for _, nCfg := range v.cfg.Network {
+		w := NewNetworker(nCfg)
+		v.worker[nCfg.Name] = w
+		v.preNet(w)
+	}
+}
+
 func (v *Switch) preApps() {
 	// Append accessed auth for point
 	v.apps.Auth = app.NewAccess(v)
@@ -322,9 +368,11 @@ func (v *Switch) preApps() {
 	for _, v := range v.cfg.Inspect {
 		inspect += v
 	}
-	// Check whether inspect neighbor
-	if strings.Contains(inspect, "neighbor") {
+	// Check whether inspect neighbor, and arp proxy requires it.
+	proxy := strings.Contains(inspect, "arpproxy")
+	if strings.Contains(inspect, "neighbor") || proxy {
 		v.apps.Neighbor = app.NewNeighbors(v)
+		v.apps.Neighbor.SetProxy(proxy)
 		v.hooks = append(v.hooks, v.apps.Neighbor.OnFrame)
 	}
 	// Check whether inspect online flow by five-tuple.
@@ -332,6 +380,11 @@ func (v *Switch) preApps() {
 		v.apps.OnLines = app.NewOnline(v)
 		v.hooks = append(v.hooks, v.apps.OnLines.OnFrame)
 	}
+	// Check whether assist points to punch direct path.
+	if v.cfg.Rendezvous != "" {
+		v.apps.Rendezvous = app.NewRendezvous(v)
+		v.hooks = append(v.hooks, v.apps.Rendezvous.OnFrame)
+	}
 	for i, h := range v.hooks {
 		v.out.Debug("Switch.preApps: id %d, func %s", i, libol.FunName(h))
 	}
@@ -388,8 +441,19 @@ func (v *Switch) preAllow() {
 	if v.cfg.Http != nil {
 		TcpPorts = append(TcpPorts, v.GetPort(v.cfg.Http.Listen))
 	}
+	if v.cfg.Rendezvous != "" {
+		UdpPorts = append(UdpPorts, v.GetPort(v.cfg.Rendezvous))
+	}
 	v.enablePort("udp", strings.Join(UdpPorts, ","))
 	v.enablePort("tcp", strings.Join(TcpPorts, ","))
+	// probes of esp are only accepted through tunnel.
+	v.firewall.AddRule(network.IpRule{
+		Table:   network.TFilter,
+		Chain:   network.OLCInput,
+		Proto:   "udp",
+		DstPort: strconv.Itoa(co.EspProbe),
+		Policy:  "ipsec",
+	})
 	for _, nCfg := range v.cfg.Network {
 		if nCfg.OpenVPN == nil {
 			continue
@@ -529,6 +593,20 @@ func (v *Switch) ReadClient(client libol.SocketClient, frame *libol.FrameMessage
 func (v *Switch) OnClose(client libol.SocketClient) error {
 	addr := client.RemoteAddr()
 	v.out.Info("Switch.OnClose: %s", addr)
+	if v.apps.Neighbor != nil {
+		v.apps.Neighbor.OnClientClose(client)
+	}
+	if m, ok := client.Private().(*models.Point); ok {
+		if bond := m.Bundle(); bond != nil && bond.Remove(client) > 0 {
+			// others in bond are still alive.
+			if m.Client == client {
+				next := bond.Members()[0]
+				v.out.Info("Switch.OnClose: %s move to %s", m.Bond, next)
+				cache.Point.Move(m, next)
+			}
+			return nil
+		}
+	}
 	// already not need support free list for device.
 	uuid := cache.Point.GetUUID(addr)
 	if cache.Point.GetAddr(uuid) == addr { // not has newer
@@ -538,11 +616,9 @@ func (v *Switch) OnClose(client libol.SocketClient) error {
 	return nil
 }
 
-func (v *Switch) Start() {
-	v.lock.Lock()
-	defer v.lock.Unlock()
-
-	OpenUDP()
+// open starts networks and server for accessing, and they are all in
+// process without firewall of host.
+func (v *Switch) open() {
 	// firstly, start network.
 	for _, w := range v.worker {
 		w.Start(v)
@@ -555,27 +631,35 @@ func (v *Switch) Start() {
 		ReadAt:   v.ReadClient,
 	}
 	libol.Go(func() { v.server.Loop(call) })
+	if v.apps.Rendezvous != nil {
+		libol.Go(v.apps.Rendezvous.Start)
+	}
 	if v.http != nil {
 		libol.Go(v.http.Start)
 	}
-	libol.Go(v.firewall.Start)
-	libol.Go(v.confd.Start)
 }
 
-func (v *Switch) Stop() {
+func (v *Switch) Start() {
 	v.lock.Lock()
 	defer v.lock.Unlock()
 
-	v.out.Debug("Switch.Stop")
-	v.confd.Stop()
-	// firstly, notify leave to point.
+	OpenUDP()
+	v.open()
+	libol.Go(v.firewall.Start)
+	libol.Go(v.confd.Start)
+}
+
+// close stops what opened, and notifies leave to point firstly.
+func (v *Switch) close() {
 	for p := range cache.Point.List() {
 		if p == nil {
 			break
 		}
 		v.leftClient(p.Client)
 	}
-	v.firewall.Stop()
+	if v.apps.Rendezvous != nil {
+		v.apps.Rendezvous.Stop()
+	}
 	if v.http != nil {
 		v.http.Shutdown()
 		v.http = nil
@@ -587,6 +671,17 @@ func (v *Switch) Stop() {
 	}
 }
 
+func (v *Switch) Stop() {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	v.out.Debug("Switch.Stop")
+	v.confd.Stop()
+	v.close()
+	v.firewall.Stop()
+	Ike.Stop()
+}
+
 func (v *Switch) Alias() string {
 	return v.cfg.Alias
 }
@@ -667,10 +762,11 @@ func (v *Switch) ReadTap(device network.Taper, readAt func(f *libol.FrameMessage
 	queue := make(chan *libol.FrameMessage, v.cfg.Queue.TapWr)
 	libol.Go(func() {
 		for {
-			frame := libol.NewFrameMessage(0)
+			frame := libol.AllocFrame(0)
 			n, err := device.Read(frame.Frame())
 			if err != nil {
 				v.out.Error("Switch.ReadTap: %s", err)
+				frame.Release()
 				done <- true
 				break
 			}
@@ -685,7 +781,9 @@ func (v *Switch) ReadTap(device network.Taper, readAt func(f *libol.FrameMessage
 	for {
 		select {
 		case frame := <-queue:
-			if err := readAt(frame); err != nil {
+			err := readAt(frame)
+			frame.Release()
+			if err != nil {
 				v.out.Error("Switch.ReadTap: readAt %s %s", name, err)
 				return
 			}
@@ -753,12 +851,152 @@ func (v *Switch) DelEsp(tenant, c *co.ESPSpecifies) {
 	//TODO dynamic configure
 }
 
-func (v *Switch) AddVxLAN(tenant string, c *co.VxLANSpecifies) {
-	//TODO dynamic configure
+// ListFabric returns stats of fabric networks, and all if tenant is empty.
+func (v *Switch) ListFabric(tenant string) []schema.Fabric {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	data := make([]schema.Fabric, 0, 32)
+	ListWorker(func(w Networker) {
+		fabric, ok := w.(*FabricWorker)
+		if !ok {
+			return
+		}
+		if tenant != "" && fabric.String() != tenant {
+			return
+		}
+		data = append(data, fabric.Stats())
+	})
+	return data
+}
+
+// addNetwork creates a network at runtime, and saves it into file.
+func (v *Switch) addNetwork(nCfg *co.Network) {
+	nCfg.ConfDir = v.cfg.ConfDir
+	nCfg.File = v.cfg.Dir("network", nCfg.Name+".json")
+	nCfg.Alias = v.cfg.Alias
+	nCfg.Correct()
+	v.cfg.Network = append(v.cfg.Network, nCfg)
+	w := NewNetworker(nCfg)
+	v.worker[nCfg.Name] = w
+	v.preNet(w)
+	w.Initialize()
+	w.Start(v)
+	// install rules of network as firewall is started.
+	v.firewall.Install()
+	nCfg.Save()
+}
+
+// delNetwork stops a network at runtime, and removes its file.
+func (v *Switch) delNetwork(w Networker) {
+	nCfg := w.GetConfig()
+	w.Stop()
+	if obj, ok := w.(interface{ Close() }); ok {
+		obj.Close()
+	}
+	delete(v.worker, nCfg.Name)
+	DelWorker(nCfg.Name)
+	for index, obj := range v.cfg.Network {
+		if obj == nCfg {
+			v.cfg.Network = append(v.cfg.Network[:index], v.cfg.Network[index+1:]...)
+			break
+		}
+	}
+	if err := os.Remove(nCfg.File); err != nil {
+		v.out.Warn("Switch.delNetwork: %s", err)
+	}
 }
 
-func (v *Switch) DelVxLAN(tenant, c *co.VxLANSpecifies) {
-	//TODO dynamic configure
+// addMembers adds or updates members of network by provider, and
+// creates the network as obj if not existed.
+func (v *Switch) addMembers(obj *co.Network, c co.MemberSpecifies) error {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	tenant := obj.Name
+	if w, ok := v.worker[tenant]; ok {
+		nCfg := w.GetConfig()
+		spec, ok := nCfg.Specifies.(co.MemberSpecifies)
+		if !ok || nCfg.Provider != obj.Provider {
+			return libol.NewErr("%s not %s: %w", tenant, obj.Provider, api.ErrConflict)
+		}
+		if err := spec.AddMembers(c); err != nil {
+			return libol.NewErr("%s %s: %w", tenant, err, api.ErrConflict)
+		}
+		nCfg.Correct()
+		w.Reload(nCfg)
+		nCfg.Save()
+		return nil
+	}
+	spec, ok := obj.Specifies.(co.MemberSpecifies)
+	if !ok {
+		return libol.NewErr("%s has no members", obj.Provider)
+	}
+	spec.Correct()
+	if err := spec.AddMembers(c); err != nil {
+		return libol.NewErr("%s %s: %w", tenant, err, api.ErrConflict)
+	}
+	v.out.Info("Switch.addMembers: new %s network %s", obj.Provider, tenant)
+	v.addNetwork(obj)
+	return nil
+}
+
+// delMembers deletes members of network by provider, and deletes the
+// network if no members given.
+func (v *Switch) delMembers(tenant, provider string, c co.MemberSpecifies) error {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	w, ok := v.worker[tenant]
+	if !ok {
+		return libol.NewErr("network %s: %w", tenant, api.ErrNotFound)
+	}
+	nCfg := w.GetConfig()
+	spec, ok := nCfg.Specifies.(co.MemberSpecifies)
+	if !ok || nCfg.Provider != provider {
+		return libol.NewErr("%s not %s: %w", tenant, provider, api.ErrConflict)
+	}
+	if c == nil || c.Empty() {
+		v.out.Info("Switch.delMembers: remove network %s", tenant)
+		v.delNetwork(w)
+		return nil
+	}
+	if err := spec.DelMembers(c); err != nil {
+		return libol.NewErr("%s %s: %w", tenant, err, api.ErrConflict)
+	}
+	w.Reload(nCfg)
+	nCfg.Save()
+	return nil
+}
+
+// AddVxLAN adds or updates members of network, and creates the
+// network if not existed.
+func (v *Switch) AddVxLAN(tenant string, c *co.VxLANSpecifies) error {
+	return v.addMembers(&co.Network{
+		Name:      tenant,
+		Provider:  "vxlan",
+		Bridge:    &co.Bridge{},
+		Specifies: &co.VxLANSpecifies{Mode: c.Mode, Local: c.Local},
+	}, c)
+}
+
+// DelVxLAN deletes members of network, and deletes the network if
+// no members given.
+func (v *Switch) DelVxLAN(tenant string, c *co.VxLANSpecifies) error {
+	return v.delMembers(tenant, "vxlan", c)
+}
+
+// AddGre adds or updates members of network, and creates the
+// network if not existed.
+func (v *Switch) AddGre(tenant string, c *co.GreSpecifies) error {
+	return v.addMembers(&co.Network{
+		Name:      tenant,
+		Provider:  "gre",
+		Specifies: &co.GreSpecifies{Mode: c.Mode, Local: c.Local},
+	}, c)
+}
+
+// DelGre deletes members of network, and deletes the network if
+// no members given.
+func (v *Switch) DelGre(tenant string, c *co.GreSpecifies) error {
+	return v.delMembers(tenant, "gre", c)
 }
 
 func (v *Switch) Firewall() *network.FireWall {
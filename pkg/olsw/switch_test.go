@@ -2,15 +2,26 @@ package olsw
 
 import (
 	"fmt"
+	co "github.com/danieldin95/openlan/pkg/config"
+	"github.com/danieldin95/openlan/pkg/libol"
+	"github.com/danieldin95/openlan/pkg/network"
+	"github.com/danieldin95/openlan/pkg/olap"
 	"github.com/danieldin95/openlan/pkg/olsw/cache"
+	"github.com/danieldin95/openlan/pkg/schema"
 	"github.com/stretchr/testify/assert"
+	"net"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestSwitch_LoadPass(t *testing.T) {
 	sw := &Switch{}
-	sw.LoadPass("../../.password.no")
-	sw.LoadPass("../../packaging/resource/password.example")
+	sw.SetPass("../../.password.no")
+	sw.LoadPass()
+	sw.SetPass("../../dist/resource/password.example")
+	sw.LoadPass()
 	for user := range cache.User.List() {
 		if user == nil {
 			break
@@ -19,3 +30,159 @@ func TestSwitch_LoadPass(t *testing.T) {
 	}
 	assert.Equal(t, 2, cache.User.Users.Len(), "notEqual")
 }
+
+func newMemSwitch(t *testing.T, listen string) *Switch {
+	dir := t.TempDir()
+	assert.Nil(t, os.MkdirAll(filepath.Join(dir, "network"), 0755), "be nil.")
+	data := `{
+    "name": "mem",
+    "bridge": {"provider": "virtual", "name": "br-mem"},
+    "subnet": {"start": "172.32.99.10", "end": "172.32.99.20", "netmask": "255.255.255.0"},
+    "password": [{"username": "hi", "password": "1f4ee82b5eb6"}]
+}`
+	file := filepath.Join(dir, "network", "mem.json")
+	assert.Nil(t, os.WriteFile(file, []byte(data), 0600), "be nil.")
+	c := &co.Switch{
+		ConfDir:  dir,
+		Protocol: "mem",
+		Listen:   listen,
+	}
+	c.Default()
+	sw := NewSwitch(c)
+	sw.Initialize()
+	// listen before points dial, and without firewall of host.
+	assert.Nil(t, sw.server.Listen(), "be nil.")
+	sw.open()
+	return sw
+}
+
+func newMemPoint(alias, connection string) *olap.MixPoint {
+	c := &co.Point{
+		Alias:       alias,
+		Connection:  connection,
+		Protocol:    "mem",
+		Username:    "hi@mem",
+		Password:    "1f4ee82b5eb6",
+		RequestAddr: true,
+		Interface: co.Interface{
+			Provider: network.ProviderVir,
+		},
+	}
+	c.Default()
+	p := olap.NewMixPoint(c)
+	p.Initialize()
+	p.Start()
+	return &p
+}
+
+// recvFrame returns frame sent to kernel by point, and nil if timeout.
+func recvFrame(dev network.Taper, match func(data []byte) bool) []byte {
+	found := make(chan []byte, 1)
+	go func() {
+		buf := make([]byte, 1600)
+		for {
+			n, err := dev.Recv(buf)
+			if err != nil || n == 0 {
+				return
+			}
+			if match(buf[:n]) {
+				found <- append([]byte{}, buf[:n]...)
+				return
+			}
+		}
+	}()
+	select {
+	case data := <-found:
+		return data
+	case <-time.After(5 * time.Second):
+		return nil
+	}
+}
+
+func newArpFrame(op uint16, shw, thw []byte, sip, tip net.IP) []byte {
+	eth := libol.NewEtherArp()
+	eth.Src = shw
+	eth.Dst = libol.EthAll
+	arp := libol.NewArp()
+	arp.OpCode = op
+	copy(arp.SHwAddr, shw)
+	copy(arp.SIpAddr, sip.To4())
+	copy(arp.TIpAddr, tip.To4())
+	if op == libol.ArpReply {
+		eth.Dst = thw
+		copy(arp.THwAddr, thw)
+	}
+	return append(eth.Encode(), arp.Encode()...)
+}
+
+func isArp(op uint16, tip net.IP) func(data []byte) bool {
+	return func(data []byte) bool {
+		eth, err := libol.NewEtherFromFrame(data)
+		if err != nil || !eth.IsArp() {
+			return false
+		}
+		arp, err := libol.NewArpFromFrame(data[eth.Len:])
+		return err == nil && arp.OpCode == op && tip.To4().Equal(arp.TIpAddr)
+	}
+}
+
+func TestSwitch_MemPoint(t *testing.T) {
+	sw := newMemSwitch(t, "0.0.0.0:10112")
+	defer sw.close()
+	pa := newMemPoint("mem-a", "switch:10112")
+	defer pa.Stop()
+	pb := newMemPoint("mem-b", "switch:10112")
+	defer pb.Stop()
+
+	// login and lease of address.
+	var la, lb *schema.Lease
+	for i := 0; i < 50; i++ {
+		la = cache.Network.GetLease(pa.UUID())
+		lb = cache.Network.GetLease(pb.UUID())
+		if la != nil && lb != nil {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if la == nil || lb == nil {
+		t.Fatal("lease timeout")
+	}
+	assert.Equal(t, true, pa.Client().Have(libol.ClAuth), "be the same.")
+	assert.NotEqual(t, la.Address, lb.Address, "be different.")
+	ipA := net.ParseIP(la.Address)
+	ipB := net.ParseIP(lb.Address)
+	hwA, _ := net.ParseMAC("52:54:00:00:99:0a")
+	hwB, _ := net.ParseMAC("52:54:00:00:99:0b")
+	devA := pa.Device()
+	devB := pb.Device()
+
+	// arp request is flooded, and reply is sent back by learned mac.
+	_, err := devA.Send(newArpFrame(libol.ArpRequest, hwA, nil, ipA, ipB))
+	assert.Nil(t, err, "be nil.")
+	data := recvFrame(devB, isArp(libol.ArpRequest, ipB))
+	assert.NotNil(t, data, "arp request.")
+	_, err = devB.Send(newArpFrame(libol.ArpReply, hwB, hwA, ipB, ipA))
+	assert.Nil(t, err, "be nil.")
+	data = recvFrame(devA, isArp(libol.ArpReply, ipA))
+	if assert.NotNil(t, data, "arp reply.") {
+		assert.Equal(t, []byte(hwA), data[:6], "be the same.")
+		assert.Equal(t, []byte(hwB), data[6:12], "be the same.")
+	}
+
+	// forward unicast frame from a to b.
+	eth := libol.NewEtherIP4()
+	eth.Src = hwA
+	eth.Dst = hwB
+	iph := libol.NewIpv4()
+	iph.Protocol = libol.IpUdp
+	iph.TotalLen = libol.Ipv4Len
+	copy(iph.Source, ipA.To4())
+	copy(iph.Destination, ipB.To4())
+	frame := append(eth.Encode(), iph.Encode()...)
+	_, err = devA.Send(frame)
+	assert.Nil(t, err, "be nil.")
+	data = recvFrame(devB, func(data []byte) bool {
+		return len(data) >= len(frame) && string(data[6:12]) == string(hwA)
+	})
+	assert.Equal(t, frame, data, "be the same.")
+}
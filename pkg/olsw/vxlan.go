@@ -7,22 +7,30 @@ import (
 	"github.com/danieldin95/openlan/pkg/olsw/api"
 	nl "github.com/vishvananda/netlink"
 	"net"
+	"syscall"
 )
 
 type VxLANWorker struct {
-	uuid string
-	cfg  *co.Network
-	spec *co.VxLANSpecifies
-	out  *libol.SubLogger
-	br   network.Bridger
+	uuid    string
+	cfg     *co.Network
+	spec    *co.VxLANSpecifies
+	out     *libol.SubLogger
+	br      network.Bridger
+	members map[string]co.VxLANMember // applied members by name.
+	mode    string                    // applied mode.
+	up      func(cfg *co.VxLANMember) error
+	down    func(cfg *co.VxLANMember) error
 }
 
 func NewVxLANWorker(c *co.Network) *VxLANWorker {
 	w := &VxLANWorker{
-		cfg: c,
-		out: libol.NewSubLogger(c.Name),
+		cfg:     c,
+		out:     libol.NewSubLogger(c.Name),
+		members: make(map[string]co.VxLANMember, 32),
 	}
 	w.spec, _ = c.Specifies.(*co.VxLANSpecifies)
+	w.up = w.upMember
+	w.down = w.downMember
 	return w
 }
 
@@ -80,30 +88,146 @@ func (w *VxLANWorker) UpVxLAN(cfg *co.VxLANMember) error {
 	if err := nl.LinkSetUp(link); err != nil {
 		w.out.Error("VxLANWorker.UpVxLAN: %s", err)
 	}
+	return w.setMaster(link)
+}
+
+func (w *VxLANWorker) setMaster(link nl.Link) error {
 	br := w.cfg.Bridge
-	if br != nil {
-		br := &nl.Bridge{LinkAttrs: nl.LinkAttrs{
-			TxQLen: -1,
-			Name:   br.Name,
-		},
+	if br == nil {
+		return nil
+	}
+	master := &nl.Bridge{LinkAttrs: nl.LinkAttrs{
+		TxQLen: -1,
+		Name:   br.Name,
+	},
+	}
+	return nl.LinkSetMaster(link, master)
+}
+
+func (w *VxLANWorker) fdbName(vni int) string {
+	return w.spec.FdbName(vni)
+}
+
+// UpFdb adds all-zero entry to remote for flooding, and static
+// entries of configured macs behind remote. Others are learned by
+// device from received frames.
+func (w *VxLANWorker) UpFdb(cfg *co.VxLANMember) error {
+	name := w.fdbName(cfg.VNI)
+	link, _ := nl.LinkByName(name)
+	if link == nil {
+		port := &nl.Vxlan{
+			LinkAttrs: nl.LinkAttrs{
+				TxQLen: -1,
+				Name:   name,
+			},
+			VxlanId:  cfg.VNI,
+			SrcAddr:  net.ParseIP(cfg.Local),
+			Port:     cfg.Port,
+			Learning: true,
 		}
-		if err := nl.LinkSetMaster(link, br); err != nil {
+		if err := nl.LinkAdd(port); err != nil {
+			return err
+		}
+		link, _ = nl.LinkByName(name)
+		if link == nil {
+			return libol.NewErr("%s notFound", name)
+		}
+		if err := nl.LinkSetUp(link); err != nil {
+			w.out.Error("VxLANWorker.UpFdb: %s", err)
+		}
+		if err := w.setMaster(link); err != nil {
 			return err
 		}
 	}
+	for _, neigh := range w.newFdb(link, cfg) {
+		var err error
+		if neigh.HardwareAddr.String() == zeroMac.String() {
+			err = nl.NeighAppend(neigh)
+		} else {
+			err = nl.NeighSet(neigh)
+		}
+		if err != nil {
+			w.out.Warn("VxLANWorker.UpFdb %s %s: %s", neigh.HardwareAddr, neigh.IP, err)
+		}
+	}
 	return nil
 }
 
+var zeroMac = net.HardwareAddr{0, 0, 0, 0, 0, 0}
+
+func (w *VxLANWorker) newFdb(link nl.Link, cfg *co.VxLANMember) []*nl.Neigh {
+	remote := net.ParseIP(cfg.Remote)
+	macs := []net.HardwareAddr{zeroMac}
+	for _, mac := range cfg.Macs {
+		if hw, err := net.ParseMAC(mac); err == nil {
+			macs = append(macs, hw)
+		} else {
+			w.out.Warn("VxLANWorker.newFdb %s", err)
+		}
+	}
+	neighs := make([]*nl.Neigh, 0, len(macs))
+	for _, hw := range macs {
+		neighs = append(neighs, &nl.Neigh{
+			LinkIndex:    link.Attrs().Index,
+			Family:       syscall.AF_BRIDGE,
+			State:        nl.NUD_PERMANENT | nl.NUD_NOARP,
+			Flags:        nl.NTF_SELF,
+			IP:           remote,
+			HardwareAddr: hw,
+		})
+	}
+	return neighs
+}
+
+// DownFdb deletes entries of remote, and deletes device if no
+// members on this vni.
+func (w *VxLANWorker) DownFdb(cfg *co.VxLANMember) error {
+	name := w.fdbName(cfg.VNI)
+	link, _ := nl.LinkByName(name)
+	if link == nil {
+		return nil
+	}
+	for _, mem := range w.members {
+		if mem.VNI == cfg.VNI {
+			for _, neigh := range w.newFdb(link, cfg) {
+				if err := nl.NeighDel(neigh); err != nil {
+					w.out.Debug("VxLANWorker.DownFdb %s %s: %s", neigh.HardwareAddr, neigh.IP, err)
+				}
+			}
+			return nil
+		}
+	}
+	return nl.LinkDel(link)
+}
+
+func (w *VxLANWorker) upMember(cfg *co.VxLANMember) error {
+	if w.mode == co.VxLANFdb {
+		return w.UpFdb(cfg)
+	}
+	return w.UpVxLAN(cfg)
+}
+
+// downMember must be called after removed from members.
+func (w *VxLANWorker) downMember(cfg *co.VxLANMember) error {
+	if w.mode == co.VxLANFdb {
+		return w.DownFdb(cfg)
+	}
+	return w.DownVxLAN(cfg)
+}
+
 func (w *VxLANWorker) Start(v api.Switcher) {
 	w.uuid = v.UUID()
 	if w.spec == nil {
 		w.out.Error("VxLANWorker.Start spec is nil")
 		return
 	}
+	w.mode = w.spec.Mode
 	for _, mem := range w.spec.Members {
-		if err := w.UpVxLAN(mem); err != nil {
+		if err := w.up(mem); err != nil {
 			w.out.Error("VxLANWorker.Start %s %s", mem.Name, err)
+			continue
 		}
+		w.members[mem.Name] = *mem
 	}
 }
 
@@ -130,13 +254,22 @@ func (w *VxLANWorker) Stop() {
 		w.out.Error("VxLANWorker.Stop spec is nil")
 		return
 	}
-	for _, mem := range w.spec.Members {
-		if err := w.DownVxLAN(mem); err != nil {
+	for name, mem := range w.members {
+		delete(w.members, name)
+		if err := w.down(&mem); err != nil {
 			w.out.Error("VxLANWorker.Stop %s %s", mem.Name, err)
 		}
 	}
 }
 
+func (w *VxLANWorker) Close() {
+	if w.br != nil {
+		if err := w.br.Close(); err != nil {
+			w.out.Warn("VxLANWorker.Close %s", err)
+		}
+	}
+}
+
 func (w *VxLANWorker) String() string {
 	return w.cfg.Name
 }
@@ -159,6 +292,44 @@ func (w *VxLANWorker) GetSubnet() string {
 	return ""
 }
 
+// Reload applies delta of members, and unchanged are kept.
 func (w *VxLANWorker) Reload(c *co.Network) {
-
+	if c != nil {
+		w.cfg = c
+		w.spec, _ = c.Specifies.(*co.VxLANSpecifies)
+	}
+	if w.spec == nil {
+		w.out.Error("VxLANWorker.Reload spec is nil")
+		return
+	}
+	if w.mode != w.spec.Mode {
+		w.out.Info("VxLANWorker.Reload mode %s to %s", w.mode, w.spec.Mode)
+		w.Stop()
+		w.mode = w.spec.Mode
+	}
+	wanted := make(map[string]*co.VxLANMember, len(w.spec.Members))
+	for _, mem := range w.spec.Members {
+		wanted[mem.Name] = mem
+	}
+	for name, mem := range w.members {
+		if obj, ok := wanted[name]; ok && obj.Equal(&mem) {
+			continue
+		}
+		w.out.Info("VxLANWorker.Reload down %s", name)
+		delete(w.members, name)
+		if err := w.down(&mem); err != nil {
+			w.out.Error("VxLANWorker.Reload %s %s", name, err)
+		}
+	}
+	for name, mem := range wanted {
+		if _, ok := w.members[name]; ok {
+			continue
+		}
+		w.out.Info("VxLANWorker.Reload up %s", name)
+		if err := w.up(mem); err != nil {
+			w.out.Error("VxLANWorker.Reload %s %s", name, err)
+			continue
+		}
+		w.members[name] = *mem
+	}
 }
@@ -0,0 +1,148 @@
+package olsw
+
+import (
+	co "github.com/danieldin95/openlan/pkg/config"
+	"github.com/danieldin95/openlan/pkg/libol"
+	"github.com/stretchr/testify/assert"
+	nl "github.com/vishvananda/netlink"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+type fakeMembers struct {
+	ups   []string
+	downs []string
+}
+
+func (f *fakeMembers) up(cfg *co.VxLANMember) error {
+	f.ups = append(f.ups, cfg.Name+"@"+cfg.Remote)
+	return nil
+}
+
+func (f *fakeMembers) down(cfg *co.VxLANMember) error {
+	f.downs = append(f.downs, cfg.Name+"@"+cfg.Remote)
+	return nil
+}
+
+// applied returns and resets sorted names of applied members.
+func (f *fakeMembers) applied() ([]string, []string) {
+	ups, downs := f.ups, f.downs
+	sort.Strings(ups)
+	sort.Strings(downs)
+	f.ups, f.downs = nil, nil
+	return ups, downs
+}
+
+func newFakeVxLAN(t *testing.T, members ...*co.VxLANMember) (*VxLANWorker, *fakeMembers) {
+	c := &co.Network{
+		Name:      "vx",
+		Provider:  "vxlan",
+		File:      filepath.Join(t.TempDir(), "vx.json"),
+		Specifies: &co.VxLANSpecifies{Members: members},
+	}
+	c.Correct()
+	w := NewVxLANWorker(c)
+	f := &fakeMembers{}
+	w.up = f.up
+	w.down = f.down
+	return w, f
+}
+
+func TestVxLANWorker_Reload(t *testing.T) {
+	w, f := newFakeVxLAN(t,
+		&co.VxLANMember{VNI: 10, Remote: "10.0.0.2"},
+		&co.VxLANMember{VNI: 20, Remote: "10.0.0.3"})
+	w.Reload(nil)
+	ups, downs := f.applied()
+	assert.Equal(t, []string{"vni10@10.0.0.2", "vni20@10.0.0.3"}, ups, "be the same.")
+	assert.Equal(t, 0, len(downs), "be the same.")
+
+	// unchanged are kept, and changed is down and up again.
+	err := w.spec.AddMembers(&co.VxLANSpecifies{
+		Members: []*co.VxLANMember{
+			{Name: "vni20", VNI: 20, Remote: "10.0.0.4"},
+			{VNI: 30, Remote: "10.0.0.5"},
+		},
+	})
+	assert.Nil(t, err, "be nil.")
+	w.Reload(nil)
+	ups, downs = f.applied()
+	assert.Equal(t, []string{"vni20@10.0.0.4", "vni30@10.0.0.5"}, ups, "be the same.")
+	assert.Equal(t, []string{"vni20@10.0.0.3"}, downs, "be the same.")
+
+	err = w.spec.DelMembers(&co.VxLANSpecifies{
+		Members: []*co.VxLANMember{{VNI: 10}},
+	})
+	assert.Nil(t, err, "be nil.")
+	w.Reload(nil)
+	ups, downs = f.applied()
+	assert.Equal(t, 0, len(ups), "be the same.")
+	assert.Equal(t, []string{"vni10@10.0.0.2"}, downs, "be the same.")
+	assert.Equal(t, 2, len(w.members), "be the same.")
+
+	// all are down and up again by mode.
+	w.spec.Mode = co.VxLANFdb
+	w.Reload(nil)
+	ups, downs = f.applied()
+	assert.Equal(t, 2, len(ups), "be the same.")
+	assert.Equal(t, 2, len(downs), "be the same.")
+}
+
+func TestSwitch_VxLANMembers(t *testing.T) {
+	w, f := newFakeVxLAN(t, &co.VxLANMember{VNI: 10, Remote: "10.0.0.2"})
+	w.Reload(nil)
+	_, _ = f.applied()
+	sw := &Switch{
+		worker: map[string]Networker{"vx": w},
+		out:    libol.NewSubLogger("switch"),
+	}
+
+	err := sw.AddVxLAN("vx", &co.VxLANSpecifies{
+		Members: []*co.VxLANMember{{VNI: 20, Remote: "10.0.0.3"}},
+	})
+	assert.Nil(t, err, "be nil.")
+	ups, downs := f.applied()
+	assert.Equal(t, []string{"vni20@10.0.0.3"}, ups, "be the same.")
+	assert.Equal(t, 0, len(downs), "be the same.")
+
+	err = sw.AddVxLAN("vx", &co.VxLANSpecifies{
+		Members: []*co.VxLANMember{{VNI: 20, Remote: "10.0.0.4"}},
+	})
+	assert.NotNil(t, err, "be conflict.")
+	ups, downs = f.applied()
+	assert.Equal(t, 0, len(ups)+len(downs), "be the same.")
+
+	err = sw.AddGre("vx", &co.GreSpecifies{})
+	assert.NotNil(t, err, "not gre.")
+
+	err = sw.DelVxLAN("vx", &co.VxLANSpecifies{
+		Members: []*co.VxLANMember{{VNI: 10}},
+	})
+	assert.Nil(t, err, "be nil.")
+	ups, downs = f.applied()
+	assert.Equal(t, 0, len(ups), "be the same.")
+	assert.Equal(t, []string{"vni10@10.0.0.2"}, downs, "be the same.")
+
+	err = sw.DelVxLAN("others", &co.VxLANSpecifies{})
+	assert.NotNil(t, err, "not found.")
+}
+
+func TestVxLANWorker_NewFdb(t *testing.T) {
+	w, _ := newFakeVxLAN(t)
+	link := &nl.Vxlan{LinkAttrs: nl.LinkAttrs{Index: 3}}
+	mem := &co.VxLANMember{
+		VNI:    10,
+		Remote: "10.0.0.2",
+		Macs:   []string{"52:54:00:10:10:10", "invalid"},
+	}
+	neighs := w.newFdb(link, mem)
+	assert.Equal(t, 2, len(neighs), "be the same.")
+	assert.Equal(t, zeroMac.String(), neighs[0].HardwareAddr.String(), "flooding.")
+	assert.Equal(t, "52:54:00:10:10:10", neighs[1].HardwareAddr.String(), "be the same.")
+	for _, neigh := range neighs {
+		assert.Equal(t, 3, neigh.LinkIndex, "be the same.")
+		assert.Equal(t, "10.0.0.2", neigh.IP.String(), "be the same.")
+	}
+	assert.Equal(t, w.spec.FdbName(10), w.fdbName(10), "be the same.")
+}
@@ -16,6 +16,7 @@ type HttpProxy struct {
 	out    *libol.SubLogger
 	server *http.Server
 	cfg    *config.HttpProxy
+	router *Router
 }
 
 var (
@@ -39,10 +40,11 @@ func parseBasicAuth(auth string) (username, password string, ok bool) {
 	return cs[:s], cs[s+1:], true
 }
 
-func NewHttpProxy(cfg *config.HttpProxy) *HttpProxy {
+func NewHttpProxy(cfg *config.HttpProxy, router *Router) *HttpProxy {
 	h := &HttpProxy{
-		out: libol.NewSubLogger(cfg.Listen),
-		cfg: cfg,
+		out:    libol.NewSubLogger(cfg.Listen),
+		cfg:    cfg,
+		router: router,
 	}
 	h.server = &http.Server{
 		Addr:    cfg.Listen,
@@ -100,6 +102,11 @@ func (t *HttpProxy) tunnel(w http.ResponseWriter, conn net.Conn) {
 		return
 	}
 	defer src.Close()
+	defer conn.Close()
+	if _, err := src.Write(connectOkay); err != nil {
+		t.out.Warn("HttpProxy.tunnel %s", err)
+		return
+	}
 	wait := libol.NewWaitOne(2)
 	libol.Go(func() {
 		defer wait.Done()
@@ -115,35 +122,78 @@ func (t *HttpProxy) tunnel(w http.ResponseWriter, conn net.Conn) {
 		if _, err := io.Copy(conn, src); err != nil {
 			t.out.Debug("HttpProxy.tunnel from ws %s", err)
 		}
+		_ = conn.Close() // notify target
 	})
 	libol.Go(func() {
 		defer wait.Done()
 		if _, err := io.Copy(src, conn); err != nil {
 			t.out.Debug("HttpProxy.tunnel from target %s", err)
 		}
+		_ = src.Close()
 	})
 	wait.Wait()
 	t.out.Debug("HttpProxy.tunnel %s exit", conn.RemoteAddr())
 }
 
+func (t *HttpProxy) action(addr string) string {
+	if t.router == nil {
+		return config.ProxyDirect
+	}
+	return t.router.Route(addr)
+}
+
+func (t *HttpProxy) dial(action, addr string) (net.Conn, error) {
+	if t.router == nil {
+		return net.Dial("tcp", addr)
+	}
+	return t.router.DialWith(action, addr)
+}
+
+func (t *HttpProxy) servePac(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/x-ns-proxy-autoconfig")
+	if t.router == nil {
+		_, _ = w.Write([]byte("function FindProxyForURL(url, host) {\n" +
+			"    return \"PROXY " + r.Host + "\";\n}\n"))
+		return
+	}
+	_, _ = w.Write([]byte(t.router.PAC(r.Host)))
+}
+
 func (t *HttpProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	t.out.Debug("HttpProxy.ServeHTTP %v", r)
 	t.out.Debug("HttpProxy.ServeHTTP %v", r.URL.Host)
+	if r.Method == "GET" && r.URL.Host == "" && r.URL.Path == "/proxy.pac" {
+		t.servePac(w, r)
+		return
+	}
 	if !t.CheckAuth(w, r) {
 		t.out.Info("HttpProxy.ServeHTTP Required %v Authentication", r.URL.Host)
 		return
 	}
 	t.out.Info("HttpProxy.ServeHTTP %s %s -> %s", r.Method, r.RemoteAddr, r.URL.Host)
+	addr := r.URL.Host
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "80")
+	}
+	action := t.action(addr)
+	if action == config.ProxyReject {
+		t.out.Info("HttpProxy.ServeHTTP %s rejected", addr)
+		http.Error(w, "Forbidden by rule", http.StatusForbidden)
+		return
+	}
 	if r.Method == "CONNECT" { //RFC-7231 Tunneling TCP based protocols through Web Proxy servers
-		conn, err := net.Dial("tcp", r.URL.Host)
+		conn, err := t.dial(action, addr)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusBadGateway)
 			return
 		}
-		_, _ = w.Write(connectOkay)
 		t.tunnel(w, conn)
 	} else { //RFC 7230 - HTTP/1.1: Message Syntax and Routing
-		transport := &http.Transport{}
+		transport := &http.Transport{
+			Dial: func(network, addr string) (net.Conn, error) {
+				return t.dial(action, addr)
+			},
+		}
 		p, err := transport.RoundTrip(r)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusBadGateway)
@@ -13,6 +13,7 @@ type Proxy struct {
 	socks  map[string]*SocksProxy
 	http   map[string]*HttpProxy
 	shadow map[string]*ss.ShadowSocks
+	router *Router
 }
 
 func NewProxy(cfg *config.Proxy) *Proxy {
@@ -29,8 +30,11 @@ func (p *Proxy) Initialize() {
 	if p.cfg == nil {
 		return
 	}
+	if len(p.cfg.Rules) > 0 {
+		p.router = NewRouter(p.cfg)
+	}
 	for _, c := range p.cfg.Socks {
-		s := NewSocksProxy(c)
+		s := NewSocksProxy(c, p.router)
 		if s == nil {
 			continue
 		}
@@ -43,7 +47,7 @@ func (p *Proxy) Initialize() {
 		if c == nil || c.Listen == "" {
 			continue
 		}
-		h := NewHttpProxy(c)
+		h := NewHttpProxy(c, p.router)
 		p.http[c.Listen] = h
 	}
 	for _, c := range p.cfg.Shadow {
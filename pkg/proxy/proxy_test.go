@@ -0,0 +1,143 @@
+package proxy
+
+import (
+	"github.com/danieldin95/openlan/pkg/config"
+	"github.com/danieldin95/openlan/pkg/libol"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func echoServer(t *testing.T) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err, "listen echo")
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				data := make([]byte, 10)
+				if _, err := io.ReadFull(conn, data); err == nil {
+					_, _ = conn.Write(data)
+				}
+			}()
+		}
+	}()
+	return l
+}
+
+func echoThrough(t *testing.T, proxy, addr string) {
+	conn, err := libol.Dial(proxy, addr)
+	assert.Nil(t, err, "dial through %s", proxy)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte("hi openlan"))
+	assert.Nil(t, err, "write")
+	data := make([]byte, 10)
+	_, err = io.ReadFull(conn, data)
+	assert.Nil(t, err, "read")
+	assert.Equal(t, "hi openlan", string(data), "be the same.")
+}
+
+func TestHttpProxy_Dial(t *testing.T) {
+	echo := echoServer(t)
+	defer echo.Close()
+	cfg := &config.HttpProxy{
+		Auth: config.Password{Username: "hi", Password: "12@3"},
+	}
+	server := httptest.NewServer(NewHttpProxy(cfg, nil))
+	defer server.Close()
+	host := server.Listener.Addr().String()
+
+	echoThrough(t, "http://hi:12%403@"+host, echo.Addr().String())
+	_, err := libol.Dial("http://hi:456@"+host, echo.Addr().String())
+	assert.NotNil(t, err, "wrong password")
+}
+
+func TestSocksProxy_Dial(t *testing.T) {
+	echo := echoServer(t)
+	defer echo.Close()
+	cfg := &config.SocksProxy{
+		Auth: config.Password{Username: "hi", Password: "123"},
+	}
+	s := NewSocksProxy(cfg, nil)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err, "listen socks")
+	defer l.Close()
+	go s.server.Serve(l)
+	host := l.Addr().String()
+
+	echoThrough(t, "socks5://hi:123@"+host, echo.Addr().String())
+	_, port, _ := net.SplitHostPort(echo.Addr().String())
+	echoThrough(t, "socks5://hi:123@"+host, "localhost:"+port)
+	_, err = libol.Dial("socks5://"+host, echo.Addr().String())
+	assert.NotNil(t, err, "without password")
+}
+
+func TestSocksProxy_Associate(t *testing.T) {
+	echo, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	assert.Nil(t, err, "listen udp")
+	defer echo.Close()
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			n, from, err := echo.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			_, _ = echo.WriteToUDP(buf[:n], from)
+		}
+	}()
+	_, port, _ := net.SplitHostPort(echo.LocalAddr().String())
+	cfg := &config.SocksProxy{
+		Users: []config.Password{{Username: "hi", Password: "123"}, {Username: "ok", Password: "456"}},
+		Rules: []*config.SocksRule{{Cidr: []string{"127.0.0.1"}, Port: []int{53}, Action: "deny"}},
+	}
+	cfg.Correct()
+	s := NewSocksProxy(cfg, nil)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err, "listen socks")
+	defer l.Close()
+	go s.server.Serve(l)
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	assert.Nil(t, err, "dial socks")
+	defer conn.Close()
+	// methods, username and UDP ASSOCIATE 0.0.0.0:0
+	_, _ = conn.Write([]byte{0x05, 0x01, 0x02})
+	_, _ = conn.Write([]byte{0x01, 0x02, 'o', 'k', 0x03, '4', '5', '6'})
+	_, _ = conn.Write([]byte{0x05, 0x03, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+	reply := make([]byte, 2+2+10)
+	_, err = io.ReadFull(conn, reply)
+	assert.Nil(t, err, "read reply")
+	assert.Equal(t, byte(0x00), reply[5], "succeeded")
+	relay := &net.UDPAddr{
+		IP:   net.IP(reply[8:12]),
+		Port: int(reply[12])<<8 | int(reply[13]),
+	}
+	udp, err := net.DialUDP("udp", nil, relay)
+	assert.Nil(t, err, "dial relay")
+	defer udp.Close()
+
+	head := []byte{0x00, 0x00, 0x00, 0x01, 127, 0, 0, 1, 0, 0}
+	p, _ := strconv.Atoi(port)
+	head[8], head[9] = byte(p>>8), byte(p)
+	_, _ = udp.Write(append(head, []byte("hi openlan")...))
+	_ = udp.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, 1024)
+	n, err := udp.Read(buf)
+	assert.Nil(t, err, "read relay")
+	assert.Equal(t, append(head, []byte("hi openlan")...), buf[:n], "be the same.")
+
+	assert.False(t, s.Allow("127.0.0.1:53"), "denied")
+	assert.True(t, s.Allow("127.0.0.1:80"), "allowed")
+}
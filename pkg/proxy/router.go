@@ -0,0 +1,240 @@
+package proxy
+
+import (
+	"fmt"
+	"github.com/danieldin95/openlan/pkg/config"
+	"github.com/danieldin95/openlan/pkg/libol"
+	"github.com/danieldin95/openlan/pkg/proxy/ss"
+	"net"
+	"strconv"
+	"strings"
+)
+
+var ErrRejected = libol.NewErr("rejected by rule")
+
+type Rule struct {
+	suffix  []string
+	keyword []string
+	cidr    []*net.IPNet
+	port    []int
+	action  string
+}
+
+func NewRule(cfg *config.ProxyRule) *Rule {
+	r := &Rule{
+		suffix:  cfg.Suffix,
+		keyword: cfg.Keyword,
+		port:    cfg.Port,
+		action:  cfg.Action,
+	}
+	for _, cidr := range cfg.Cidr {
+		if _, inet, err := net.ParseCIDR(cidr); err == nil {
+			r.cidr = append(r.cidr, inet)
+		} else {
+			libol.Warn("NewRule: %s", err)
+		}
+	}
+	return r
+}
+
+func (r *Rule) hasHost() bool {
+	return len(r.suffix) > 0 || len(r.keyword) > 0 || len(r.cidr) > 0
+}
+
+func (r *Rule) matchPort(port int) bool {
+	if len(r.port) == 0 {
+		return true
+	}
+	for _, p := range r.port {
+		if p == port {
+			return true
+		}
+	}
+	return false
+}
+
+// Match returns true if host matched any of suffix, keyword and
+// cidr, and ip is called only if needed to resolve domain.
+func (r *Rule) Match(host string, port int, ip func() net.IP) bool {
+	if !r.matchPort(port) {
+		return false
+	}
+	if !r.hasHost() {
+		return true
+	}
+	host = strings.ToLower(host)
+	for _, suffix := range r.suffix {
+		if host == suffix || strings.HasSuffix(host, "."+suffix) {
+			return true
+		}
+	}
+	for _, keyword := range r.keyword {
+		if strings.Contains(host, keyword) {
+			return true
+		}
+	}
+	if len(r.cidr) == 0 {
+		return false
+	}
+	if addr := ip(); addr != nil {
+		for _, inet := range r.cidr {
+			if inet.Contains(addr) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type Dialer func(addr string) (net.Conn, error)
+
+type Router struct {
+	rules    []*Rule
+	upstream map[string]Dialer
+	out      *libol.SubLogger
+}
+
+func NewRouter(cfg *config.Proxy) *Router {
+	r := &Router{
+		rules:    make([]*Rule, 0, len(cfg.Rules)),
+		upstream: make(map[string]Dialer, len(cfg.Upstream)),
+		out:      libol.NewSubLogger("router"),
+	}
+	for _, up := range cfg.Upstream {
+		if up == nil || up.Name == "" {
+			continue
+		}
+		if up.Shadow != nil {
+			r.upstream[up.Name] = ss.NewShadowSocks(up.Shadow).Dial
+		} else if up.Url != "" {
+			url := up.Url
+			r.upstream[up.Name] = func(addr string) (net.Conn, error) {
+				return libol.Dial(url, addr)
+			}
+		}
+	}
+	for _, rc := range cfg.Rules {
+		if rc == nil {
+			continue
+		}
+		if _, ok := r.upstream[rc.Action]; !ok {
+			if rc.Action != config.ProxyDirect && rc.Action != config.ProxyReject {
+				r.out.Warn("NewRouter: upstream %s notFound", rc.Action)
+				continue
+			}
+		}
+		r.rules = append(r.rules, NewRule(rc))
+	}
+	return r
+}
+
+// MatchRules returns first rule matched addr, and nil if not found.
+func MatchRules(rules []*Rule, addr string) *Rule {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	port, _ := strconv.Atoi(portStr)
+	var ip net.IP
+	resolved := false
+	resolve := func() net.IP {
+		if !resolved {
+			resolved = true
+			if ip = net.ParseIP(host); ip == nil {
+				if ips, err := net.LookupIP(host); err == nil && len(ips) > 0 {
+					ip = ips[0]
+				}
+			}
+		}
+		return ip
+	}
+	for _, rule := range rules {
+		if rule.Match(host, port, resolve) {
+			return rule
+		}
+	}
+	return nil
+}
+
+// Route returns action for addr, and direct if not matched.
+func (r *Router) Route(addr string) string {
+	if rule := MatchRules(r.rules, addr); rule != nil {
+		return rule.action
+	}
+	return config.ProxyDirect
+}
+
+// Dial connects to addr by action of matched rule.
+func (r *Router) Dial(addr string) (net.Conn, error) {
+	return r.DialWith(r.Route(addr), addr)
+}
+
+func (r *Router) DialWith(action, addr string) (net.Conn, error) {
+	r.out.Debug("Router.DialWith: %s via %s", addr, action)
+	switch action {
+	case config.ProxyDirect:
+		return net.Dial("tcp", addr)
+	case config.ProxyReject:
+		return nil, ErrRejected
+	}
+	if dial, ok := r.upstream[action]; ok {
+		return dial(addr)
+	}
+	return nil, libol.NewErr("upstream %s notFound", action)
+}
+
+func pacMask(inet *net.IPNet) string {
+	m := inet.Mask
+	return fmt.Sprintf("%d.%d.%d.%d", m[0], m[1], m[2], m[3])
+}
+
+// PAC generates a proxy auto-config script, and proxy is used
+// for destinations not direct.
+func (r *Router) PAC(proxy string) string {
+	sb := &strings.Builder{}
+	sb.WriteString("function portOf(url) {\n")
+	sb.WriteString("    var m = url.match(/^[a-z]+:\\/\\/[^\\/]*:(\\d+)/i);\n")
+	sb.WriteString("    if (m) return parseInt(m[1]);\n")
+	sb.WriteString("    return url.substring(0, 6) == \"https:\" ? 443 : 80;\n")
+	sb.WriteString("}\n\n")
+	sb.WriteString("function FindProxyForURL(url, host) {\n")
+	sb.WriteString("    host = host.toLowerCase();\n")
+	sb.WriteString("    var port = portOf(url);\n")
+	sb.WriteString("    var ip = null;\n")
+	for _, rule := range r.rules {
+		conds := make([]string, 0, 4)
+		for _, suffix := range rule.suffix {
+			conds = append(conds, fmt.Sprintf("dnsDomainIs(host, %q) || host == %q", "."+suffix, suffix))
+		}
+		for _, keyword := range rule.keyword {
+			conds = append(conds, fmt.Sprintf("host.indexOf(%q) >= 0", keyword))
+		}
+		for _, inet := range rule.cidr {
+			if ip4 := inet.IP.To4(); ip4 != nil && len(inet.Mask) == net.IPv4len {
+				conds = append(conds, fmt.Sprintf("isInNet(ip || (ip = dnsResolve(host)), %q, %q)", ip4, pacMask(inet)))
+			}
+		}
+		if rule.hasHost() && len(conds) == 0 {
+			continue // only ipv6, and not supported.
+		}
+		cond := "true"
+		if len(conds) > 0 {
+			cond = "(" + strings.Join(conds, " || ") + ")"
+		}
+		if len(rule.port) > 0 {
+			ports := make([]string, 0, len(rule.port))
+			for _, p := range rule.port {
+				ports = append(ports, "port == "+strconv.Itoa(p))
+			}
+			cond = "(" + strings.Join(ports, " || ") + ") && " + cond
+		}
+		result := "DIRECT"
+		if rule.action != config.ProxyDirect {
+			result = "PROXY " + proxy
+		}
+		sb.WriteString(fmt.Sprintf("    if (%s) return %q;\n", cond, result))
+	}
+	sb.WriteString("    return \"DIRECT\";\n")
+	sb.WriteString("}\n")
+	return sb.String()
+}
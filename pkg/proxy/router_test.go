@@ -0,0 +1,66 @@
+package proxy
+
+import (
+	"github.com/danieldin95/openlan/pkg/config"
+	"github.com/danieldin95/openlan/pkg/libol"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRouter_Route(t *testing.T) {
+	cfg := &config.Proxy{
+		Upstream: []*config.ProxyUpstream{
+			{Name: "office", Url: "socks5://127.0.0.1:1080"},
+		},
+		Rules: []*config.ProxyRule{
+			{Suffix: []string{".Example.com"}, Action: "office"},
+			{Keyword: []string{"ads"}, Action: "reject"},
+			{Cidr: []string{"10.0.0.0/8", "192.168.1.1"}, Port: []int{22}, Action: "office"},
+			{Suffix: []string{"lan"}, Action: "unknown"},
+		},
+	}
+	cfg.Correct(nil)
+	r := NewRouter(cfg)
+	assert.Equal(t, 3, len(r.rules), "ignore unknown upstream")
+	assert.Equal(t, "office", r.Route("example.com:443"), "be the same.")
+	assert.Equal(t, "office", r.Route("www.example.com:80"), "be the same.")
+	assert.Equal(t, "direct", r.Route("badexample.com:80"), "be the same.")
+	assert.Equal(t, "reject", r.Route("ads.openlan.net:80"), "be the same.")
+	assert.Equal(t, "office", r.Route("10.1.1.1:22"), "be the same.")
+	assert.Equal(t, "office", r.Route("192.168.1.1:22"), "be the same.")
+	assert.Equal(t, "direct", r.Route("10.1.1.1:80"), "be the same.")
+	assert.Equal(t, "direct", r.Route("192.168.1.2:22"), "be the same.")
+
+	pac := r.PAC("proxy.openlan.net:11082")
+	assert.True(t, strings.Contains(pac, `dnsDomainIs(host, ".example.com")`), "suffix")
+	assert.True(t, strings.Contains(pac, `isInNet(ip || (ip = dnsResolve(host)), "10.0.0.0", "255.0.0.0")`), "cidr")
+	assert.True(t, strings.Contains(pac, `return "PROXY proxy.openlan.net:11082"`), "proxy")
+}
+
+func TestRouter_Chain(t *testing.T) {
+	echo := echoServer(t)
+	defer echo.Close()
+	upstream := httptest.NewServer(NewHttpProxy(&config.HttpProxy{}, nil))
+	defer upstream.Close()
+	cfg := &config.Proxy{
+		Upstream: []*config.ProxyUpstream{
+			{Name: "office", Url: "http://" + upstream.Listener.Addr().String()},
+		},
+		Rules: []*config.ProxyRule{
+			{Suffix: []string{"localhost"}, Action: "reject"},
+			{Cidr: []string{"127.0.0.0/8"}, Action: "office"},
+		},
+	}
+	cfg.Correct(nil)
+	server := httptest.NewServer(NewHttpProxy(&config.HttpProxy{}, NewRouter(cfg)))
+	defer server.Close()
+
+	proxy := "http://" + server.Listener.Addr().String()
+	echoThrough(t, proxy, echo.Addr().String())
+	_, port, _ := net.SplitHostPort(echo.Addr().String())
+	_, err := libol.Dial(proxy, "localhost:"+port)
+	assert.NotNil(t, err, "rejected")
+}
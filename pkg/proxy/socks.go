@@ -1,9 +1,16 @@
 package proxy
 
 import (
+	"bufio"
+	"context"
 	"github.com/armon/go-socks5"
 	"github.com/danieldin95/openlan/pkg/config"
 	"github.com/danieldin95/openlan/pkg/libol"
+	"github.com/shadowsocks/go-shadowsocks2/socks"
+	"io"
+	"io/ioutil"
+	"net"
+	"sync"
 	"time"
 )
 
@@ -11,25 +18,106 @@ type SocksProxy struct {
 	server *socks5.Server
 	out    *libol.SubLogger
 	cfg    *config.SocksProxy
+	router *Router
+	rules  []*Rule
 }
 
-func NewSocksProxy(cfg *config.SocksProxy) *SocksProxy {
+// keep domain for routing, and resolved by dialer.
+type socksResolver struct{}
+
+func (r socksResolver) Resolve(ctx context.Context, name string) (context.Context, net.IP, error) {
+	return ctx, nil, nil
+}
+
+// socksRules allows destination of CONNECT by policy and router, and
+// datagrams of UDP ASSOCIATE are checked when relaying.
+type socksRules struct {
+	proxy *SocksProxy
+}
+
+func (r *socksRules) Allow(ctx context.Context, req *socks5.Request) (context.Context, bool) {
+	switch req.Command {
+	case socks5.ConnectCommand:
+		return ctx, r.proxy.Allow(req.DestAddr.Address())
+	case socks5.AssociateCommand:
+		return ctx, true
+	}
+	return ctx, false
+}
+
+var errAssociated = libol.NewErr("udp associate finished")
+
+// socksAuthenticator handles UDP ASSOCIATE after authenticated, which
+// is not supported by go-socks5.
+type socksAuthenticator struct {
+	socks5.Authenticator
+	proxy *SocksProxy
+}
+
+func (a *socksAuthenticator) Authenticate(reader io.Reader, writer io.Writer) (*socks5.AuthContext, error) {
+	ctx, err := a.Authenticator.Authenticate(reader, writer)
+	if err != nil {
+		return ctx, err
+	}
+	bufConn, ok := reader.(*bufio.Reader)
+	if !ok {
+		return ctx, nil
+	}
+	conn, ok := writer.(net.Conn)
+	if !ok {
+		return ctx, nil
+	}
+	// VER, CMD
+	if head, err := bufConn.Peek(2); err != nil || head[1] != socks5.AssociateCommand {
+		return ctx, nil
+	}
+	req, err := socks5.NewRequest(bufConn)
+	if err != nil {
+		return ctx, err
+	}
+	a.proxy.associate(conn, bufConn, req)
+	return nil, errAssociated
+}
+
+func NewSocksProxy(cfg *config.SocksProxy, router *Router) *SocksProxy {
 	s := &SocksProxy{
-		cfg: cfg,
-		out: libol.NewSubLogger(cfg.Listen),
+		cfg:    cfg,
+		out:    libol.NewSubLogger(cfg.Listen),
+		router: router,
+	}
+	for _, rc := range cfg.Rules {
+		s.rules = append(s.rules, NewRule(&config.ProxyRule{
+			Cidr:   rc.Cidr,
+			Port:   rc.Port,
+			Action: rc.Action,
+		}))
 	}
 	// Create a SOCKS5 server
-	auth := cfg.Auth
-	authMethods := make([]socks5.Authenticator, 0, 2)
-	if len(auth.Username) > 0 {
-		author := socks5.UserPassAuthenticator{
-			Credentials: socks5.StaticCredentials{
-				auth.Username: auth.Password,
-			},
+	credentials := socks5.StaticCredentials{}
+	if auth := cfg.Auth; len(auth.Username) > 0 {
+		credentials[auth.Username] = auth.Password
+	}
+	for _, auth := range cfg.Users {
+		if len(auth.Username) > 0 {
+			credentials[auth.Username] = auth.Password
+		}
+	}
+	var author socks5.Authenticator = socks5.NoAuthAuthenticator{}
+	if len(credentials) > 0 {
+		author = socks5.UserPassAuthenticator{Credentials: credentials}
+	}
+	conf := &socks5.Config{
+		AuthMethods: []socks5.Authenticator{
+			&socksAuthenticator{Authenticator: author, proxy: s},
+		},
+		Rules: &socksRules{proxy: s},
+	}
+	if router != nil {
+		conf.Resolver = socksResolver{}
+		conf.Dial = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return router.Dial(addr)
 		}
-		authMethods = append(authMethods, author)
 	}
-	conf := &socks5.Config{AuthMethods: authMethods}
 	server, err := socks5.New(conf)
 	if err != nil {
 		s.out.Error("NewSocksProxy %s", err)
@@ -39,6 +127,115 @@ func NewSocksProxy(cfg *config.SocksProxy) *SocksProxy {
 	return s
 }
 
+// Allow returns false if destination denied by rules or rejected
+// by router.
+func (s *SocksProxy) Allow(addr string) bool {
+	if rule := MatchRules(s.rules, addr); rule != nil && rule.action == config.SocksDeny {
+		s.out.Info("SocksProxy.Allow %s denied", addr)
+		return false
+	}
+	if s.router != nil && s.router.Route(addr) == config.ProxyReject {
+		s.out.Info("SocksProxy.Allow %s rejected", addr)
+		return false
+	}
+	return true
+}
+
+// associate relays datagrams until the tcp connection closed.
+func (s *SocksProxy) associate(conn net.Conn, reader io.Reader, req *socks5.Request) {
+	client, _ := conn.RemoteAddr().(*net.TCPAddr)
+	local, _ := conn.LocalAddr().(*net.TCPAddr)
+	if client == nil || local == nil {
+		return
+	}
+	fail := []byte{0x05, 0x01, 0x00, 0x01, 0, 0, 0, 0, 0, 0}
+	relay, err := net.ListenUDP("udp", &net.UDPAddr{IP: local.IP})
+	if err != nil {
+		s.out.Warn("SocksProxy.associate %s", err)
+		_, _ = conn.Write(fail)
+		return
+	}
+	defer relay.Close()
+	remote, err := net.ListenUDP("udp", nil)
+	if err != nil {
+		s.out.Warn("SocksProxy.associate %s", err)
+		_, _ = conn.Write(fail)
+		return
+	}
+	defer remote.Close()
+	bind := socks.ParseAddr(relay.LocalAddr().String())
+	if _, err := conn.Write(append([]byte{0x05, 0x00, 0x00}, bind...)); err != nil {
+		return
+	}
+	s.out.Info("SocksProxy.associate %s on %s", client, relay.LocalAddr())
+
+	libol.Go(func() {
+		// association terminates when the tcp connection closed.
+		_, _ = io.Copy(ioutil.Discard, reader)
+		_ = relay.Close()
+		_ = remote.Close()
+	})
+
+	var lock sync.Mutex
+	var peer *net.UDPAddr
+	libol.Go(func() {
+		buf := make([]byte, 64*1024)
+		for {
+			n, from, err := remote.ReadFromUDP(buf[socks.MaxAddrLen:])
+			if err != nil {
+				break
+			}
+			lock.Lock()
+			to := peer
+			lock.Unlock()
+			if to == nil || !s.Allow(from.String()) {
+				continue
+			}
+			// RSV, FRAG and source address
+			head := append([]byte{0x00, 0x00, 0x00}, socks.ParseAddr(from.String())...)
+			start := socks.MaxAddrLen - len(head)
+			copy(buf[start:], head)
+			_, _ = relay.WriteToUDP(buf[start:socks.MaxAddrLen+n], to)
+		}
+	})
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, from, err := relay.ReadFromUDP(buf)
+		if err != nil {
+			break
+		}
+		if !from.IP.Equal(client.IP) || n < 3 || buf[2] != 0x00 { // fragment not supported.
+			continue
+		}
+		if port := req.DestAddr.Port; port != 0 && port != from.Port {
+			continue
+		}
+		lock.Lock()
+		if peer == nil {
+			peer = from
+		}
+		lock.Unlock()
+		tgt := socks.SplitAddr(buf[3:n])
+		if tgt == nil {
+			continue
+		}
+		addr := tgt.String()
+		if !s.Allow(addr) {
+			continue
+		}
+		udpAddr, err := net.ResolveUDPAddr("udp", addr)
+		if err != nil {
+			s.out.Debug("SocksProxy.associate %s", err)
+			continue
+		}
+		if _, err := remote.WriteToUDP(buf[3+len(tgt):n], udpAddr); err != nil {
+			s.out.Debug("SocksProxy.associate %s", err)
+		}
+	}
+	s.out.Info("SocksProxy.associate %s exit", client)
+}
+
 func (s *SocksProxy) Start() {
 	if s.server == nil || s.cfg == nil {
 		return
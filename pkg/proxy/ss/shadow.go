@@ -5,7 +5,9 @@ import (
 	c "github.com/danieldin95/openlan/pkg/config"
 	"github.com/danieldin95/openlan/pkg/libol"
 	"github.com/shadowsocks/go-shadowsocks2/core"
+	"github.com/shadowsocks/go-shadowsocks2/socks"
 	"log"
+	"net"
 )
 
 type ShadowSocks struct {
@@ -36,18 +38,20 @@ func NewShadowSocks(cfg *c.ShadowProxy) *ShadowSocks {
 	}
 }
 
-func (s *ShadowSocks) Start() {
+func (s *ShadowSocks) pickCipher() (core.Cipher, error) {
 	var key []byte
 	if s.Key != "" {
 		k, err := base64.URLEncoding.DecodeString(s.Key)
 		if err != nil {
-			log.Fatal(err)
+			return nil, err
 		}
 		key = k
 	}
+	return core.PickCipher(s.Cipher, key, s.Password)
+}
+
+func (s *ShadowSocks) Start() {
 	addr := s.Server
-	cipher := s.Cipher
-	password := s.Password
 	var err error
 
 	udpAddr := addr
@@ -57,7 +61,7 @@ func (s *ShadowSocks) Start() {
 			log.Fatal(err)
 		}
 	}
-	ciph, err := core.PickCipher(cipher, key, password)
+	ciph, err := s.pickCipher()
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -73,3 +77,25 @@ func (s *ShadowSocks) Start() {
 func (s *ShadowSocks) Stop() {
 	killPlugin()
 }
+
+// Dial connects to addr through this shadow server as a client.
+func (s *ShadowSocks) Dial(addr string) (net.Conn, error) {
+	tgt := socks.ParseAddr(addr)
+	if tgt == nil {
+		return nil, libol.NewErr("invalid address %s", addr)
+	}
+	ciph, err := s.pickCipher()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.Dial("tcp", s.Server)
+	if err != nil {
+		return nil, err
+	}
+	rc := ciph.StreamConn(conn)
+	if _, err := rc.Write(tgt); err != nil {
+		_ = rc.Close()
+		return nil, err
+	}
+	return rc, nil
+}
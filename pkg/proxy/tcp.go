@@ -3,56 +3,279 @@ package proxy
 import (
 	"github.com/danieldin95/openlan/pkg/config"
 	"github.com/danieldin95/openlan/pkg/libol"
+	"hash/fnv"
 	"io"
 	"net"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
+type TcpBackend struct {
+	address  string
+	weight   int
+	current  int // for smooth weighted round-robin.
+	alive    bool
+	rise     int
+	fall     int
+	ejectAt  time.Time
+	conns    int64 // active connections.
+	total    int64
+	errors   int64
+	rxBytes  int64
+	txBytes  int64
+	lastStat int64
+}
+
+func (b *TcpBackend) Active() int64 {
+	return atomic.LoadInt64(&b.conns)
+}
+
 type TcpProxy struct {
+	lock     sync.Mutex
 	listen   string
-	target   []string
+	cfg      *config.TcpProxy
+	backends []*TcpBackend
 	listener net.Listener
+	done     chan bool
 	out      *libol.SubLogger
-	rr       uint64
 }
 
 func NewTcpProxy(cfg *config.TcpProxy) *TcpProxy {
-	return &TcpProxy{
-		listen: cfg.Listen,
-		target: cfg.Target,
-		out:    libol.NewSubLogger(cfg.Listen),
+	t := &TcpProxy{
+		listen:   cfg.Listen,
+		cfg:      cfg,
+		backends: make([]*TcpBackend, 0, len(cfg.Target)),
+		done:     make(chan bool, 2),
+		out:      libol.NewSubLogger(cfg.Listen),
 	}
+	for _, addr := range cfg.Target {
+		weight := 1
+		if w, ok := cfg.Weight[addr]; ok && w > 0 {
+			weight = w
+		}
+		t.backends = append(t.backends, &TcpBackend{
+			address: addr,
+			weight:  weight,
+			alive:   true,
+		})
+	}
+	return t
 }
 
-func (t *TcpProxy) tunnel(src net.Conn, dst net.Conn) {
+func (t *TcpProxy) tunnel(src net.Conn, dst net.Conn, b *TcpBackend) {
 	defer dst.Close()
 	defer src.Close()
 	t.out.Info("TcpProxy.tunnel %s -> %s", src.RemoteAddr(), dst.RemoteAddr())
+	atomic.AddInt64(&b.conns, 1)
+	atomic.AddInt64(&b.total, 1)
+	defer atomic.AddInt64(&b.conns, -1)
 	wait := libol.NewWaitOne(2)
 	libol.Go(func() {
 		defer wait.Done()
-		if _, err := io.Copy(dst, src); err != nil {
+		n, err := io.Copy(dst, src)
+		if err != nil {
 			t.out.Debug("TcpProxy.tunnel from ws %s", err)
 		}
+		atomic.AddInt64(&b.txBytes, n)
+		_ = dst.Close()
 	})
 	libol.Go(func() {
 		defer wait.Done()
-		if _, err := io.Copy(src, dst); err != nil {
+		n, err := io.Copy(src, dst)
+		if err != nil {
 			t.out.Debug("TcpProxy.tunnel from target %s", err)
 		}
+		atomic.AddInt64(&b.rxBytes, n)
+		_ = src.Close()
 	})
 	wait.Wait()
 	t.out.Debug("TcpProxy.tunnel %s exit", dst.RemoteAddr())
 }
 
-func (t *TcpProxy) loadBalance(fail int) string {
-	size := len(t.target)
-	if fail < size {
-		i := t.rr % uint64(size)
-		t.rr++
-		return t.target[i]
+// available must be called with lock.
+func (t *TcpProxy) available(b *TcpBackend) bool {
+	if b.alive {
+		return true
+	}
+	// without active check, retry it after ejected.
+	if t.cfg.Check == nil && time.Now().After(b.ejectAt) {
+		b.alive = true
+		b.fall = 0
+		return true
+	}
+	return false
+}
+
+func (t *TcpProxy) roundRobin(backends []*TcpBackend) *TcpBackend {
+	var best *TcpBackend
+	total := 0
+	for _, b := range backends {
+		b.current += b.weight
+		total += b.weight
+		if best == nil || b.current > best.current {
+			best = b
+		}
+	}
+	best.current -= total
+	return best
+}
+
+func (t *TcpProxy) leastConn(backends []*TcpBackend) *TcpBackend {
+	var best *TcpBackend
+	for _, b := range backends {
+		// conns/weight less than best.
+		if best == nil || b.Active()*int64(best.weight) < best.Active()*int64(b.weight) {
+			best = b
+		}
+	}
+	return best
+}
+
+func (t *TcpProxy) sourceHash(backends []*TcpBackend, src net.Addr) *TcpBackend {
+	host := src.String()
+	if addr, ok := src.(*net.TCPAddr); ok {
+		host = addr.IP.String()
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(host))
+	total := 0
+	for _, b := range backends {
+		total += b.weight
+	}
+	index := int(h.Sum32() % uint32(total))
+	for _, b := range backends {
+		if index < b.weight {
+			return b
+		}
+		index -= b.weight
+	}
+	return backends[0]
+}
+
+func (t *TcpProxy) loadBalance(src net.Addr, tried map[*TcpBackend]bool) *TcpBackend {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	backends := make([]*TcpBackend, 0, len(t.backends))
+	for _, b := range t.backends {
+		if !tried[b] && t.available(b) {
+			backends = append(backends, b)
+		}
+	}
+	if len(backends) == 0 {
+		return nil
+	}
+	switch t.cfg.Strategy {
+	case config.TcpLeastConn:
+		return t.leastConn(backends)
+	case config.TcpSourceHash:
+		return t.sourceHash(backends, src)
+	default:
+		return t.roundRobin(backends)
+	}
+}
+
+// onResult updates state by result of health check or dial.
+func (t *TcpProxy) onResult(b *TcpBackend, ok bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	rise, fall := 1, 1
+	if c := t.cfg.Check; c != nil {
+		rise, fall = c.Rise, c.Fall
+	}
+	if ok {
+		b.fall = 0
+		b.rise++
+		if !b.alive && b.rise >= rise {
+			t.out.Info("TcpProxy.onResult: %s up", b.address)
+			b.alive = true
+		}
+		return
+	}
+	b.rise = 0
+	b.fall++
+	if b.alive && b.fall >= fall {
+		t.out.Warn("TcpProxy.onResult: %s down", b.address)
+		b.alive = false
+		b.ejectAt = time.Now().Add(time.Duration(t.cfg.Eject) * time.Second)
+	}
+}
+
+func (t *TcpProxy) onAccept(conn net.Conn) {
+	tried := make(map[*TcpBackend]bool, len(t.backends))
+	for {
+		b := t.loadBalance(conn.RemoteAddr(), tried)
+		if b == nil {
+			t.out.Error("TcpProxy.onAccept: %s no available target", conn.RemoteAddr())
+			_ = conn.Close()
+			break
+		}
+		tried[b] = true
+		target, err := net.Dial("tcp", b.address)
+		if err != nil {
+			t.out.Error("TcpProxy.onAccept %s", err)
+			atomic.AddInt64(&b.errors, 1)
+			t.onResult(b, false)
+			continue
+		}
+		t.tunnel(conn, target, b)
+		break
+	}
+}
+
+func (t *TcpProxy) check() {
+	c := t.cfg.Check
+	timeout := time.Duration(c.Timeout) * time.Second
+	for _, b := range t.backends {
+		conn, err := net.DialTimeout("tcp", b.address, timeout)
+		if err != nil {
+			t.out.Debug("TcpProxy.check %s", err)
+			t.onResult(b, false)
+			continue
+		}
+		_ = conn.Close()
+		t.onResult(b, true)
+	}
+}
+
+func (t *TcpProxy) printStats() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for _, b := range t.backends {
+		total := atomic.LoadInt64(&b.total)
+		errors := atomic.LoadInt64(&b.errors)
+		if total+errors == b.lastStat {
+			continue
+		}
+		b.lastStat = total + errors
+		t.out.Info("TcpProxy.printStats %s alive:%v weight:%d active:%d total:%d errors:%d rx:%d tx:%d",
+			b.address, b.alive, b.weight, b.Active(), total, errors,
+			atomic.LoadInt64(&b.rxBytes), atomic.LoadInt64(&b.txBytes))
+	}
+}
+
+func (t *TcpProxy) Loop() {
+	interval := 60 * time.Second
+	if c := t.cfg.Check; c != nil {
+		interval = time.Duration(c.Interval) * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	stats := time.Now()
+	for {
+		select {
+		case <-t.done:
+			return
+		case <-ticker.C:
+			if t.cfg.Check != nil {
+				t.check()
+			}
+			if time.Since(stats) >= time.Minute {
+				stats = time.Now()
+				t.printStats()
+			}
+		}
 	}
-	return ""
 }
 
 func (t *TcpProxy) Start() {
@@ -71,7 +294,8 @@ func (t *TcpProxy) Start() {
 		return err
 	})
 	t.listener = listen
-	t.out.Info("TcpProxy.Start: %s", t.target)
+	t.out.Info("TcpProxy.Start: %s by %s", t.cfg.Target, t.cfg.Strategy)
+	libol.Go(t.Loop)
 	libol.Go(func() {
 		defer listen.Close()
 		for {
@@ -81,23 +305,9 @@ func (t *TcpProxy) Start() {
 				break
 			}
 			// connect target and pipe it.
-			fail := 0
-			for {
-				backend := t.loadBalance(fail)
-				if backend == "" {
-					break
-				}
-				target, err := net.Dial("tcp", backend)
-				if err != nil {
-					t.out.Error("TcpProxy.Accept %s", err)
-					fail++
-					continue
-				}
-				libol.Go(func() {
-					t.tunnel(conn, target)
-				})
-				break
-			}
+			libol.Go(func() {
+				t.onAccept(conn)
+			})
 		}
 	})
 	return
@@ -107,6 +317,8 @@ func (t *TcpProxy) Stop() {
 	if t.listener != nil {
 		_ = t.listener.Close()
 	}
+	t.done <- true
+	t.printStats()
 	t.out.Info("TcpProxy.Stop")
 	t.listener = nil
 }
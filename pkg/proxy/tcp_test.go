@@ -0,0 +1,74 @@
+package proxy
+
+import (
+	"github.com/danieldin95/openlan/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+)
+
+func TestTcpProxy_LoadBalance(t *testing.T) {
+	cfg := &config.TcpProxy{
+		Target: []string{"192.168.1.1:80", "192.168.1.2:80", "192.168.1.3:80"},
+		Weight: map[string]int{"192.168.1.1:80": 2},
+	}
+	cfg.Correct()
+	p := NewTcpProxy(cfg)
+	src := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 1234}
+
+	count := make(map[string]int, 3)
+	for i := 0; i < 8; i++ {
+		b := p.loadBalance(src, nil)
+		count[b.address]++
+	}
+	assert.Equal(t, 4, count["192.168.1.1:80"], "weight 2")
+	assert.Equal(t, 2, count["192.168.1.2:80"], "weight 1")
+
+	// passive ejection without active check.
+	p.onResult(p.backends[0], false)
+	assert.False(t, p.backends[0].alive, "ejected")
+	for i := 0; i < 4; i++ {
+		b := p.loadBalance(src, nil)
+		assert.NotEqual(t, "192.168.1.1:80", b.address, "not ejected")
+	}
+	tried := map[*TcpBackend]bool{p.backends[1]: true, p.backends[2]: true}
+	assert.Nil(t, p.loadBalance(src, tried), "no available")
+
+	cfg.Strategy = config.TcpLeastConn
+	p.backends[1].conns = 2
+	assert.Equal(t, p.backends[2], p.loadBalance(src, nil), "least")
+
+	cfg.Strategy = config.TcpSourceHash
+	first := p.loadBalance(src, nil)
+	for i := 0; i < 4; i++ {
+		assert.Equal(t, first, p.loadBalance(src, nil), "same source")
+	}
+}
+
+func TestTcpProxy_Check(t *testing.T) {
+	echo := echoServer(t)
+	defer echo.Close()
+	dead, _ := net.Listen("tcp", "127.0.0.1:0")
+	_ = dead.Close()
+	cfg := &config.TcpProxy{
+		Target: []string{echo.Addr().String(), dead.Addr().String()},
+		Check:  &config.TcpCheck{Rise: 2, Fall: 2},
+	}
+	cfg.Correct()
+	p := NewTcpProxy(cfg)
+	p.check()
+	assert.True(t, p.backends[1].alive, "fall 1")
+	p.check()
+	assert.False(t, p.backends[1].alive, "fall 2")
+	assert.True(t, p.backends[0].alive, "echo alive")
+
+	ln, _ := net.Listen("tcp", dead.Addr().String())
+	if ln == nil {
+		return
+	}
+	defer ln.Close()
+	p.check()
+	assert.False(t, p.backends[1].alive, "rise 1")
+	p.check()
+	assert.True(t, p.backends[1].alive, "rise 2")
+}
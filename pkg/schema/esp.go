@@ -20,6 +20,14 @@ type EspState struct {
 	TxPackages int64  `json:"txPackages"`
 	RxBytes    int64  `json:"rxBytes"`
 	RxPackages int64  `json:"rxPackages"`
+	KeyAge     int64  `json:"keyAge,omitempty"`
+	NextRekey  int64  `json:"nextRekey,omitempty"`
+	Replay     int64  `json:"replay"`
+	Failed     int64  `json:"failed"`
+	Status     string `json:"status,omitempty"`
+	Rtt        int64  `json:"rtt,omitempty"` // in microseconds
+	Flaps      int    `json:"flaps,omitempty"`
+	StatusTime int64  `json:"statusTime,omitempty"`
 }
 
 type EspPolicy struct {
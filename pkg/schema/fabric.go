@@ -0,0 +1,27 @@
+package schema
+
+type FabricPort struct {
+	Name       string `json:"name"`
+	Port       int    `json:"port"`
+	Mode       string `json:"mode,omitempty"`
+	Remote     string `json:"remote,omitempty"`
+	TxBytes    int64  `json:"txBytes"`
+	TxPackages int64  `json:"txPackages"`
+	RxBytes    int64  `json:"rxBytes"`
+	RxPackages int64  `json:"rxPackages"`
+}
+
+type FabricNetwork struct {
+	Vni        uint32     `json:"vni"`
+	Bridge     string     `json:"bridge"`
+	Patch      FabricPort `json:"patch"`
+	ArpReplies int64      `json:"arpReplies"`
+}
+
+type Fabric struct {
+	Name     string          `json:"name"`
+	Bridge   string          `json:"bridge"`
+	Driver   string          `json:"driver"`
+	Tunnels  []FabricPort    `json:"tunnels"`
+	Networks []FabricNetwork `json:"networks"`
+}
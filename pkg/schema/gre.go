@@ -0,0 +1,17 @@
+package schema
+
+type Gre struct {
+	Name    string      `json:"name"`
+	Bridge  string      `json:"bridge,omitempty"`
+	Mode    string      `json:"mode,omitempty"`
+	Members []GreMember `json:"members"`
+}
+
+type GreMember struct {
+	Name    string `json:"name,omitempty"`
+	Local   string `json:"local"`
+	Remote  string `json:"remote"`
+	Key     uint32 `json:"key,omitempty"`
+	Address string `json:"address,omitempty"`
+	Ttl     uint8  `json:"ttl,omitempty"`
+}
@@ -1,20 +1,32 @@
 package schema
 
 type Point struct {
-	Uptime    int64   `json:"uptime"`
-	UUID      string  `json:"uuid"`
-	Network   string  `json:"network"`
-	User      string  `json:"user"`
-	Alias     string  `json:"alias"`
-	Protocol  string  `json:"protocol"`
-	Remote    string  `json:"remote"`
-	Switch    string  `json:"switch,omitempty"`
-	Device    string  `json:"device"`
-	RxBytes   int64   `json:"rxBytes"`
-	TxBytes   int64   `json:"txBytes"`
-	ErrPkt    int64   `json:"errors"`
-	State     string  `json:"state"`
-	AliveTime int64   `json:"aliveTime"`
-	System    string  `json:"system"`
-	Address   Network `json:"address"`
+	Uptime    int64        `json:"uptime"`
+	UUID      string       `json:"uuid"`
+	Network   string       `json:"network"`
+	User      string       `json:"user"`
+	Alias     string       `json:"alias"`
+	Protocol  string       `json:"protocol"`
+	Remote    string       `json:"remote"`
+	Switch    string       `json:"switch,omitempty"`
+	Device    string       `json:"device"`
+	RxBytes   int64        `json:"rxBytes"`
+	TxBytes   int64        `json:"txBytes"`
+	ErrPkt    int64        `json:"errors"`
+	State     string       `json:"state"`
+	AliveTime int64        `json:"aliveTime"`
+	Mtu       int          `json:"mtu,omitempty"` // effective mtu of path.
+	System    string       `json:"system"`
+	Address   Network      `json:"address"`
+	Direct    []DirectPath `json:"direct,omitempty"`
+}
+
+type DirectPath struct {
+	Peer      string `json:"peer"`
+	Alias     string `json:"alias"`
+	Endpoint  string `json:"endpoint"`
+	State     string `json:"state"`
+	RxBytes   int64  `json:"rxBytes"`
+	TxBytes   int64  `json:"txBytes"`
+	AliveTime int64  `json:"aliveTime"`
 }
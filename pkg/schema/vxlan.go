@@ -3,11 +3,15 @@ package schema
 type VxLAN struct {
 	Name    string        `json:"name"`
 	Bridge  string        `json:"bridge"`
+	Mode    string        `json:"mode,omitempty"`
 	Members []VxLANMember `json:"members"`
 }
 
 type VxLANMember struct {
-	Vni    int    `json:"vni"`
-	Local  string `json:"local"`
-	Remote string `json:"remote"`
+	Name   string   `json:"name,omitempty"`
+	Vni    int      `json:"vni"`
+	Local  string   `json:"local"`
+	Remote string   `json:"remote"`
+	Port   int      `json:"port,omitempty"`
+	Macs   []string `json:"macs,omitempty"`
 }